@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/ini.v1"
+)
+
+type Account struct {
+	Name  string
+	User  string
+	Token string
+}
+
+type AccountPool struct {
+	mu       sync.Mutex
+	accounts []Account
+	blocked  []bool
+	current  int
+	// privileged is config.ini's [privileged] account. It is kept out of
+	// the rotation and used only for its namespaces and, when
+	// forProtected is set, for documents the other accounts may not edit.
+	privileged   *Account
+	privilegedNS []string
+	forProtected bool
+}
+
+func loadAccounts(cfg *ini.File) *AccountPool {
+	pool := &AccountPool{}
+	if token := cfg.Section("").Key("token").String(); token != "" {
+		pool.accounts = append(pool.accounts, Account{Name: "default", User: cfg.Section("").Key("user").String(), Token: token})
+	}
+	for _, sec := range cfg.Sections() {
+		name, ok := strings.CutPrefix(sec.Name(), "account.")
+		if !ok || name == "" {
+			continue
+		}
+		if token := sec.Key("token").String(); token != "" {
+			pool.accounts = append(pool.accounts, Account{Name: name, User: sec.Key("user").String(), Token: token})
+		}
+	}
+	if sec := cfg.Section("privileged"); sec.Key("token").String() != "" {
+		pool.privileged = &Account{Name: "privileged", User: sec.Key("user").String(), Token: sec.Key("token").String()}
+		pool.privilegedNS = parseList(sec.Key("namespaces").String())
+		pool.forProtected = sec.Key("protected").MustBool(false)
+	}
+	pool.blocked = make([]bool, len(pool.accounts))
+	return pool
+}
+
+func (p *AccountPool) Current() Account {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.accounts) == 0 {
+		return Account{}
+	}
+	return p.accounts[p.current]
+}
+
+// Next switches to the next usable account. When block is true the current
+// account is taken out of the rotation for the rest of the run. It returns
+// false once every account is blocked.
+func (p *AccountPool) Next(block bool) (Account, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.accounts) == 0 {
+		// Anonymous reads can only wait out a rate limit.
+		return Account{}, !block
+	}
+	if block {
+		p.blocked[p.current] = true
+	}
+	for i := 1; i <= len(p.accounts); i++ {
+		idx := (p.current + i) % len(p.accounts)
+		if !p.blocked[idx] {
+			p.current = idx
+			return p.accounts[idx], true
+		}
+	}
+	return Account{}, false
+}
+
+// Anonymous reports whether no account with a token is configured, so the
+// bot can only read, as an anonymous visitor would.
+func (p *AccountPool) Anonymous() bool {
+	return len(p.accounts) == 0
+}
+
+// requireToken stops a command that edits the wiki when the bot would be
+// running anonymously.
+func (b *Bot) requireToken(command string) {
+	if b.Accounts.Anonymous() {
+		warn("token_required", command)
+		os.Exit(2)
+	}
+}
+
+// IsBot reports whether user is one of the pool's own accounts. An
+// account without a user is matched by its [account.<name>] section name,
+// which is usually the wiki user name.
+func (p *AccountPool) IsBot(user string) bool {
+	for _, a := range p.accounts {
+		if user != "" && a.wikiUser() == user {
+			return true
+		}
+	}
+	return p.privileged != nil && p.privileged.User != "" && p.privileged.User == user
+}
+
+// wikiUser is the user name a's edits appear under, or "" when unknown.
+func (a Account) wikiUser() string {
+	if a.User != "" || a.Name == "default" {
+		return a.User
+	}
+	return a.Name
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestIsBot(t *testing.T) {
+	cfg, err := ini.Load([]byte("token = a\n[account.RearAlice]\ntoken = b\n[account.helper]\nuser = RearHelper\ntoken = c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := loadAccounts(cfg)
+	for user, want := range map[string]bool{
+		"RearAlice":  true,
+		"RearHelper": true,
+		"helper":     false,
+		"default":    false,
+		"":           false,
+	} {
+		if got := pool.IsBot(user); got != want {
+			t.Errorf("IsBot(%q) = %v, want %v", user, got, want)
+		}
+	}
+}
+
+func TestAnonymousReads(t *testing.T) {
+	o := newOrchard(t, nil)
+	o.bot.Accounts = loadAccounts(ini.Empty())
+	if !o.bot.Accounts.Anonymous() {
+		t.Fatal("a pool without tokens is not anonymous")
+	}
+
+	docs, _, counts := o.bot.collectJobBacklinks([]*Job{o.job})
+	if len(docs) != 1 || counts["문서"] != 1 {
+		t.Errorf("anonymous backlinks = %v, %v; want 과수원", docs, counts)
+	}
+	if _, ok := o.bot.Accounts.Next(false); !ok {
+		t.Errorf("an anonymous pool gave up on a rate limit")
+	}
+
+	o.srv.Private = true
+	if _, err := getPageContent(context.Background(), o.bot.Domain, "", "과수원"); !errors.Is(err, ErrAnonymousDenied) {
+		t.Errorf("anonymous read of a private wiki = %v, want ErrAnonymousDenied", err)
+	}
+	if _, err := getPageContent(context.Background(), o.bot.Domain, "test", "과수원"); err != nil {
+		t.Errorf("read with a token of a private wiki = %v", err)
+	}
+}
+
+func TestWithPrivilegedBlocked(t *testing.T) {
+	cfg, err := ini.Load([]byte("token = a\n[privileged]\ntoken = p\nnamespaces = 틀\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{Accounts: loadAccounts(cfg)}
+	var tried []string
+	account, err := b.withAccountFor(context.Background(), "틀:과일", func(a Account) error {
+		tried = append(tried, a.Name)
+		return ErrBlocked
+	})
+	if account.Name != "privileged" || !errors.Is(err, ErrBlocked) || len(tried) != 1 {
+		t.Errorf("blocked privileged account: %s, %v after %v; want its block returned", account.Name, err, tried)
+	}
+}
+
+func TestWithPrivilegedRateLimitedCanceled(t *testing.T) {
+	cfg, err := ini.Load([]byte("token = a\n[privileged]\ntoken = p\nnamespaces = 틀\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest[io.Writer](t, &humanOut, testWriter{t})
+	b := &Bot{Accounts: loadAccounts(cfg)}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = b.withAccountFor(ctx, "틀:과일", func(a Account) error { return ErrRateLimited })
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 10*time.Second {
+		t.Errorf("rate limited past the deadline: %v after %v, want the deadline at once", err, time.Since(start))
+	}
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"time"
+)
+
+type Backlink struct {
+	Document string `json:"document"`
+	Flags    string `json:"flags"`
+}
+
+type BacklinkResponse struct {
+	Backlinks []Backlink `json:"backlinks"`
+	Until     string     `json:"until"`
+}
+
+type Contribution struct {
+	Document string `json:"document"`
+	Rev      int    `json:"rev"`
+	Log      string `json:"log"`
+	Date     int64  `json:"date"`
+}
+
+type ContributionResponse struct {
+	Contributions []Contribution `json:"contributions"`
+	Until         string         `json:"until"`
+}
+
+// A Move is one entry of the wiki's move log.
+type Move struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Author string `json:"author"`
+	Log    string `json:"log"`
+	Date   int64  `json:"date"`
+}
+
+type Revision struct {
+	Rev    int    `json:"rev"`
+	Author string `json:"author"`
+	Log    string `json:"log"`
+	Date   int64  `json:"date"`
+}
+
+type ThreadComment struct {
+	ID     int    `json:"id"`
+	Author string `json:"author"`
+	Text   string `json:"text"`
+	Date   int64  `json:"date"`
+}
+
+type Discuss struct {
+	Slug        string `json:"slug"`
+	Topic       string `json:"topic"`
+	UpdatedDate int    `json:"updated_date"`
+	Status      string `json:"status"`
+}
+
+func getBacklinksByNamespace(ctx context.Context, domain, token, title, namespace string) ([]string, error) {
+	var docs []string
+	err := streamBacklinks(ctx, domain, token, title, namespace, func(page []string) error {
+		docs = append(docs, page...)
+		return nil
+	})
+	return docs, err
+}
+
+// streamBacklinks walks the backlink listing one API page at a time and
+// hands each page of linking documents to fn before fetching the next.
+// Only the kinds of backlink a rename of title rewrites are included (see
+// backlinkKinds).
+func streamBacklinks(ctx context.Context, domain, token, title, namespace string, fn func([]string) error) error {
+	kinds := backlinkKinds(title)
+	return listBacklinks(ctx, domain, token, title, namespace, func(page []Backlink) error {
+		var docs []string
+		for _, b := range page {
+			if slices.Contains(kinds, b.Flags) {
+				docs = append(docs, b.Document)
+			}
+		}
+		return fn(docs)
+	})
+}
+
+// listBacklinks is streamBacklinks for every kind of backlink (link, file,
+// include, redirect), not only plain links.
+func listBacklinks(ctx context.Context, domain, token, title, namespace string, fn func([]Backlink) error) error {
+	if backend != nil {
+		return backendBacklinks(ctx, domain, token, title, namespace, fn)
+	}
+	from := ""
+	for {
+		urlStr := api.url(domain, "backlink", url.PathEscape(title)+"?namespace="+url.QueryEscape(namespace))
+		if from != "" {
+			urlStr += "&from=" + url.QueryEscape(from)
+		}
+		resp, err := doRequest(ctx, "backlinks", "GET", urlStr, token, nil)
+		if err != nil {
+			return err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		var res BacklinkResponse
+		api.decode(body, &res)
+		if err := fn(res.Backlinks); err != nil {
+			return err
+		}
+		if res.Until == "" || res.Until == from {
+			return nil
+		}
+		from = res.Until
+	}
+}
+
+type TitleResponse struct {
+	Titles []string `json:"titles"`
+	Until  string   `json:"until"`
+}
+
+// getTitlesByPrefix lists the documents in namespace whose titles (without
+// the namespace prefix) start with prefix.
+func getTitlesByPrefix(ctx context.Context, domain, token, namespace, prefix string) ([]string, error) {
+	if !api.has("titles") {
+		return nil, fmt.Errorf("titles: %w", ErrUnsupported)
+	}
+	var titles []string
+	from := ""
+	for {
+		urlStr := api.url(domain, "titles", "?namespace="+url.QueryEscape(namespace)+"&prefix="+url.QueryEscape(prefix))
+		if from != "" {
+			urlStr += "&from=" + url.QueryEscape(from)
+		}
+		resp, err := doRequest(ctx, "titles", "GET", urlStr, token, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return nil, statusError(resp)
+		}
+		var res TitleResponse
+		if err := api.decode(body, &res); err != nil {
+			return nil, err
+		}
+		titles = append(titles, res.Titles...)
+		if res.Until == "" || res.Until == from {
+			return titles, nil
+		}
+		from = res.Until
+	}
+}
+
+// checkDiscuss reports whether title has an open discussion whose topic
+// matches topic; a nil topic matches every discussion.
+func checkDiscuss(ctx context.Context, domain, token, title string, topic *regexp.Regexp) (bool, error) {
+	if !api.has("discuss") {
+		return false, fmt.Errorf("discussions: %w", ErrUnsupported)
+	}
+	urlStr := api.url(domain, "discuss", url.PathEscape(title))
+	resp, err := doRequest(ctx, "discuss", "GET", urlStr, token, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	var discussList []Discuss
+	body, _ := io.ReadAll(resp.Body)
+	api.decode(body, &discussList)
+
+	for _, d := range discussList {
+		if d.Status == "normal" && (topic == nil || topic.MatchString(d.Topic)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// FilterError reports an edit rejected by the wiki's edit (abuse) filter.
+type FilterError struct {
+	Message string
+}
+
+func (e *FilterError) Error() string {
+	return "rejected by edit filter: " + e.Message
+}
+
+// Is makes every FilterError match ErrFiltered.
+func (e *FilterError) Is(target error) bool { return target == ErrFiltered }
+
+var (
+	ErrPermDenied  = errors.New("API access denied due to insufficient permissions")
+	ErrRateLimited = errors.New("API rate limit exceeded")
+	ErrBlocked     = errors.New("account is blocked")
+	ErrBadToken    = errors.New("API token was rejected")
+	ErrRecentEdit  = errors.New("recently edited by a person")
+	ErrNeedsReview = errors.New("change is too large to save without review")
+	ErrGone        = errors.New("document no longer exists")
+	ErrTooLarge    = errors.New("document is too large to process")
+	ErrUnsupported = errors.New("not supported by the wiki's API")
+	ErrFiltered    = errors.New("rejected by edit filter")
+	// ErrAnonymousDenied is a request without a token that the wiki
+	// refused: it does not allow anonymous reads of that.
+	ErrAnonymousDenied = errors.New("the wiki does not allow this without a token")
+)
+
+// maxPageBytes caps the size of a fetched document, so a single huge page
+// cannot balloon the bot's memory; 0 means no limit. It is data.ini's
+// maxPageBytes.
+var maxPageBytes int64 = 10 << 20
+
+type Page struct {
+	Title string
+	Text  string
+	Token string
+	// Hash is the SHA-256 of Text. The edit API reports no revision ID,
+	// so the hash is what tells whether a page changed since it was read.
+	Hash    string
+	Fetched time.Time
+}
+
+// newPage returns title's page as fetched now, holding text.
+func newPage(title, text string) *Page {
+	return &Page{Title: title, Text: text, Hash: hashText(text), Fetched: time.Now()}
+}
+
+func getPageContent(ctx context.Context, domain, token, title string) (*Page, error) {
+	if backend != nil {
+		return backend.getPage(ctx, domain, token, title)
+	}
+	urlStr := api.url(domain, "edit", url.PathEscape(title))
+	resp, err := doRequest(ctx, "get_content", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, ErrRateLimited
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrBadToken
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrGone
+	}
+	if maxPageBytes > 0 && resp.ContentLength > maxPageBytes {
+		return nil, fmt.Errorf("%w (%d bytes, limit %d)", ErrTooLarge, resp.ContentLength, maxPageBytes)
+	}
+	var body []byte
+	if maxPageBytes > 0 {
+		// Without a Content-Length the limit is checked while reading.
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxPageBytes+1))
+		if int64(len(body)) > maxPageBytes {
+			return nil, fmt.Errorf("%w (over %d bytes)", ErrTooLarge, maxPageBytes)
+		}
+	} else {
+		body, _ = io.ReadAll(resp.Body)
+	}
+	if err := classifyAPIError(body); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+	var r struct {
+		Text  string `json:"text"`
+		Token string `json:"token"`
+	}
+	api.decode(body, &r)
+	page := newPage(title, r.Text)
+	page.Token = r.Token
+	return page, nil
+}
+
+// updatePageContent saves content to title and returns the new revision
+// number.
+func updatePageContent(ctx context.Context, domain, token, title, content, editToken, logMsg string) (int, error) {
+	if backend != nil {
+		return backend.savePage(ctx, domain, token, title, content, editToken, logMsg)
+	}
+	payload := api.encode(map[string]string{"text": content, "log": logMsg, "token": editToken})
+	urlStr := api.url(domain, "edit", url.PathEscape(title))
+	resp, err := doRequest(ctx, "save", "POST", urlStr, token, payload)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return 0, ErrRateLimited
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := classifyAPIError(body); err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= 300 {
+		return 0, statusError(resp)
+	}
+	var r struct {
+		Rev int `json:"rev"`
+	}
+	api.decode(body, &r)
+	return r.Rev, nil
+}
+
+// getContributions returns one page of user's document edits, newest first,
+// and the cursor for the next page ("" on the last page).
+func getContributions(ctx context.Context, domain, token, user, from string) ([]Contribution, string, error) {
+	if !api.has("contribution") {
+		return nil, "", fmt.Errorf("contributions: %w", ErrUnsupported)
+	}
+	urlStr := api.url(domain, "contribution", url.PathEscape(user)+"/document")
+	if from != "" {
+		urlStr += "?from=" + url.QueryEscape(from)
+	}
+	resp, err := doRequest(ctx, "contributions", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var res ContributionResponse
+	if err := api.decode(body, &res); err != nil {
+		return nil, "", err
+	}
+	if res.Until == from {
+		res.Until = ""
+	}
+	return res.Contributions, res.Until, nil
+}
+
+func getRawRevision(ctx context.Context, domain, token, title string, rev int) (string, error) {
+	if !api.has("raw") {
+		return "", fmt.Errorf("raw revisions: %w", ErrUnsupported)
+	}
+	urlStr := api.url(domain, "raw", fmt.Sprintf("%s?rev=%d", url.PathEscape(title), rev))
+	resp, err := doRequest(ctx, "raw", "GET", urlStr, token, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		Text string `json:"text"`
+	}
+	if err := api.decode(body, &r); err != nil {
+		return "", err
+	}
+	return r.Text, nil
+}
+
+// getMoves returns the latest limit entries of the move log, newest first.
+func getMoves(ctx context.Context, domain, token string, limit int) ([]Move, error) {
+	if !api.has("moves") {
+		return nil, fmt.Errorf("move log: %w", ErrUnsupported)
+	}
+	urlStr := api.url(domain, "moves", fmt.Sprintf("?limit=%d", limit))
+	resp, err := doRequest(ctx, "moves", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		Moves []Move `json:"moves"`
+	}
+	if err := api.decode(body, &r); err != nil {
+		return nil, err
+	}
+	return r.Moves, nil
+}
+
+// getHistory returns the latest revisions of title, newest first.
+func getHistory(ctx context.Context, domain, token, title string) ([]Revision, error) {
+	if !api.has("history") {
+		return nil, fmt.Errorf("history: %w", ErrUnsupported)
+	}
+	urlStr := api.url(domain, "history", url.PathEscape(title))
+	resp, err := doRequest(ctx, "history", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		History []Revision `json:"history"`
+	}
+	if err := api.decode(body, &r); err != nil {
+		return nil, err
+	}
+	return r.History, nil
+}
+
+// createThread opens a new discussion thread on title and returns its slug.
+func createThread(ctx context.Context, domain, token, title, topic, text string) (string, error) {
+	if !api.has("discuss") {
+		return "", fmt.Errorf("discussions: %w", ErrUnsupported)
+	}
+	payload := api.encode(map[string]string{"topic": topic, "text": text})
+	urlStr := api.url(domain, "discuss", url.PathEscape(title))
+	resp, err := doRequest(ctx, "create_thread", "POST", urlStr, token, payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return "", ErrRateLimited
+	}
+	if resp.StatusCode >= 300 {
+		return "", statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		Slug string `json:"slug"`
+	}
+	api.decode(body, &r)
+	return r.Slug, nil
+}
+
+func replyThread(ctx context.Context, domain, token, slug, text string) error {
+	payload := api.encode(map[string]string{"text": text})
+	urlStr := api.url(domain, "thread", url.PathEscape(slug))
+	resp, err := doRequest(ctx, "reply_thread", "POST", urlStr, token, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	if resp.StatusCode >= 300 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+func getThreadComments(ctx context.Context, domain, token, slug string) ([]ThreadComment, error) {
+	urlStr := api.url(domain, "thread", url.PathEscape(slug))
+	resp, err := doRequest(ctx, "thread_comments", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, statusError(resp)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		Comments []ThreadComment `json:"comments"`
+	}
+	if err := api.decode(body, &r); err != nil {
+		return nil, err
+	}
+	return r.Comments, nil
+}
+
+// requestIDHeader, when set from config.ini's requestIDHeader, names the
+// header that carries each request's ID to the wiki, so its server logs
+// can be matched with the bot's.
+var requestIDHeader string
+
+type requestIDKey struct{}
+
+// requestID returns the ID doRequest gave the request made with ctx.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// statusError describes an unexpected API status, naming the request ID.
+func statusError(resp *http.Response) error {
+	return fmt.Errorf("status %s (request %s)", resp.Status, requestID(resp.Request.Context()))
+}
+
+// sendOnce makes one try at req, bounded by op's timeout.
+func sendOnce(ctx context.Context, req *http.Request, op string, data []byte) (*http.Response, error) {
+	ctx, cancel := withOpTimeout(ctx, op)
+	start := time.Now()
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+	} else {
+		resp.Body = cancelOnClose{resp.Body, cancel}
+		if err = decodeBody(resp); err != nil {
+			resp.Body.Close()
+			resp = nil
+		}
+	}
+	recordLatency(time.Since(start))
+	tuner.observe(time.Since(start), err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+	logHTTP(requestID(ctx), req.Method, req.URL.String(), data, resp, err, time.Since(start))
+	return resp, err
+}
+
+// doRequest sends one API request with the bot's token, or anonymously
+// when token is empty, JSON-encoding payload as the body when it is not
+// nil. op names the operation in traces and picks its timeout (see
+// opTimeouts); failures other than writeOps' are retried as httpRetry says.
+// A request without a token that the wiki refuses fails with
+// ErrAnonymousDenied. Every request gets a random ID, which appears in
+// traces, the debug log and API errors.
+func doRequest(ctx context.Context, op, method, urlStr, token string, payload any) (*http.Response, error) {
+	id := randomHex(8)
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	var body io.Reader
+	var data []byte
+	if payload != nil {
+		data, _ = json.Marshal(payload)
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if requestIDHeader != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	if compressTransfer {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	ctx, span := startSpan(ctx, op, "http.method", method, "http.url", urlStr, "request.id", id)
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+		resp, err = sendOnce(ctx, req, op, data)
+		wait, again := httpRetry.wait(attempt, resp, err)
+		if !again || writeOps[op] {
+			break
+		}
+		if resp != nil {
+			discard(resp)
+		}
+		say("http_retry", op, id, attempt, wait)
+		if err = sleepCtx(ctx, wait); err != nil {
+			resp = nil
+			break
+		}
+	}
+	if resp != nil {
+		span.set("http.status_code", resp.Status)
+	}
+	if token == "" && resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		discard(resp)
+		resp, err = nil, ErrAnonymousDenied
+	}
+	span.end(err)
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("%s %w after %v (request %s)", op, err, opTimeout(op), id)
+	} else if err != nil {
+		err = fmt.Errorf("%w (request %s)", err, id)
+	}
+	return resp, err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+)
+
+// A knownError recognises one kind of failure the seed engine reports. Newer
+// engine versions send a machine-readable code next to the status message;
+// older ones only send the message, in the instance's UI language, so
+// messages from each known version and language are listed as well.
+type knownError struct {
+	codes    []string
+	messages []string
+	err      error
+}
+
+var knownErrors = []knownError{
+	{
+		codes: []string{"blocked", "user_blocked", "ip_blocked"},
+		messages: []string{
+			"차단된",
+			"you are blocked", "has been blocked", "blocked user", "blocked ip",
+		},
+		err: ErrBlocked,
+	},
+	{
+		codes: []string{"permission_denied", "acl_denied", "no_permission"},
+		messages: []string{
+			"때문에 편집 권한이 부족합니다",
+			"편집 권한이 부족합니다",
+			"권한이 부족합니다",
+			"insufficient permission", "permission denied", "you don't have permission", "not allowed to edit",
+		},
+		err: ErrPermDenied,
+	},
+	{
+		codes: []string{"edit_conflict", "conflict"},
+		messages: []string{
+			"편집 도중에 다른 사용자가 먼저 편집을 했습니다",
+			"edit conflict", "someone else edited",
+		},
+		err: ErrPageChanged,
+	},
+	{
+		codes: []string{"edit_filter", "abuse_filter", "filtered"},
+		messages: []string{
+			"편집 필터에 의해",
+			"blocked by the edit filter", "rejected by edit filter", "edit filter",
+		},
+		// err is nil: filter hits become a *FilterError carrying the message.
+	},
+}
+
+// classifyAPIError maps an API response body to one of the bot's errors,
+// or nil when it reports no known failure.
+func classifyAPIError(body []byte) error {
+	var r struct {
+		Status string `json:"status"`
+		Code   string `json:"code"`
+		Error  string `json:"error"`
+	}
+	api.decode(body, &r)
+	message := r.Status
+	if message == "" {
+		message = r.Error
+	}
+	if message == "success" {
+		return nil
+	}
+	code := strings.ToLower(r.Code)
+	lower := strings.ToLower(message)
+	for _, k := range knownErrors {
+		if !k.matches(code, lower) {
+			continue
+		}
+		if k.err == nil {
+			return &FilterError{Message: message}
+		}
+		return k.err
+	}
+	return nil
+}
+
+func (k knownError) matches(code, message string) bool {
+	for _, c := range k.codes {
+		if code == c {
+			return true
+		}
+	}
+	if code != "" || message == "" {
+		return false
+	}
+	for _, m := range k.messages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+	return false
+}
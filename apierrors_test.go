@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClassifyAPIError(t *testing.T) {
+	for _, c := range []struct {
+		body string
+		want error
+	}{
+		{`{"status": "success"}`, nil},
+		{`{"status": "error", "code": "edit_filter"}`, ErrFiltered},
+		{`{"status": "편집 필터에 의해 차단되었습니다."}`, ErrFiltered},
+		{`{"status": "Your edit was blocked by the edit filter."}`, ErrFiltered},
+		{`{"status": "권한이 부족합니다."}`, ErrPermDenied},
+		// Only the filter's own wording counts, not the word in a title
+		// or another failure.
+		{`{"status": "문서 'Coffee filter'를 찾을 수 없습니다."}`, nil},
+		{`{"status": "Document 'Filter (software)' is locked: permission denied"}`, ErrPermDenied},
+		{`{"status": "invalid filter parameter"}`, nil},
+	} {
+		err := classifyAPIError([]byte(c.body))
+		if c.want == nil && err != nil || c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("classifyAPIError(%s) = %v, want %v", c.body, err, c.want)
+		}
+	}
+}
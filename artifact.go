@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Run artifacts that hold page text or say what was done to which page —
+// checkpoints, fetched pages, plans, reports and their CSV, the manifest,
+// the remaining-mentions log, the skip cache and list, protected-out, the
+// event log and the debug HTTP log — can be encrypted at rest with
+// AES-256-GCM. The key is 32 bytes, hex-encoded, taken from the first of:
+//
+//	MICRO_REARALICE_ARTIFACT_KEY   environment variable
+//	artifactKeyCommand             config.ini; its output, e.g. from secret-tool
+//	artifactKeyFile                config.ini; the file's contents
+//	artifactKey                    config.ini
+//
+// With no key, artifacts are written in the clear as before. Files that
+// were written in the clear stay readable either way.
+var artifactKey []byte
+
+// artifactMagic starts a whole-file artifact; artifactLinePrefix starts
+// each line of a line-sealed one such as the event log.
+const (
+	artifactMagic      = "MRAE1\n"
+	artifactLinePrefix = "mrae1:"
+)
+
+var (
+	ErrArtifactKey     = errors.New("artifact key must be 32 hex-encoded bytes")
+	ErrArtifactNoKey   = errors.New("artifact is encrypted and no artifact key is configured")
+	ErrArtifactCorrupt = errors.New("artifact cannot be decrypted with this key")
+)
+
+// loadArtifactKey sets artifactKey from the environment or config.ini.
+func loadArtifactKey(cfg *ini.File) error {
+	artifactKey = nil
+	sec := cfg.Section("")
+	encoded := os.Getenv("MICRO_REARALICE_ARTIFACT_KEY")
+	switch {
+	case encoded != "":
+	case len(strings.Fields(sec.Key("artifactKeyCommand").String())) > 0:
+		args := strings.Fields(sec.Key("artifactKeyCommand").String())
+		out, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			return fmt.Errorf("artifactKeyCommand: %w", err)
+		}
+		encoded = string(out)
+	case sec.Key("artifactKeyFile").String() != "":
+		data, err := os.ReadFile(sec.Key("artifactKeyFile").String())
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	default:
+		encoded = sec.Key("artifactKey").String()
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return ErrArtifactKey
+	}
+	artifactKey = key
+	return nil
+}
+
+func artifactAEAD() cipher.AEAD {
+	block, _ := aes.NewCipher(artifactKey)
+	aead, _ := cipher.NewGCM(block)
+	return aead
+}
+
+func seal(data []byte) []byte {
+	aead := artifactAEAD()
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return aead.Seal(nonce, nonce, data, nil)
+}
+
+func unseal(data []byte) ([]byte, error) {
+	if artifactKey == nil {
+		return nil, ErrArtifactNoKey
+	}
+	aead := artifactAEAD()
+	if len(data) < aead.NonceSize() {
+		return nil, ErrArtifactCorrupt
+	}
+	out, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrArtifactCorrupt
+	}
+	return out, nil
+}
+
+// sealArtifact returns data as it should be stored: encrypted when a key
+// is configured.
+func sealArtifact(data []byte) []byte {
+	if artifactKey == nil {
+		return data
+	}
+	return append([]byte(artifactMagic), seal(data)...)
+}
+
+// openArtifact undoes sealArtifact, or a line-sealed file's sealing line
+// by line. Data that was never encrypted is returned as it is.
+func openArtifact(data []byte) ([]byte, error) {
+	if rest, ok := bytes.CutPrefix(data, []byte(artifactMagic)); ok {
+		return unseal(rest)
+	}
+	if !bytes.HasPrefix(data, []byte(artifactLinePrefix)) {
+		return data, nil
+	}
+	var out bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		line, ok := strings.CutPrefix(sc.Text(), artifactLinePrefix)
+		if !ok {
+			out.WriteString(sc.Text() + "\n")
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, ErrArtifactCorrupt
+		}
+		plain, err := unseal(raw)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(plain)
+	}
+	return out.Bytes(), sc.Err()
+}
+
+// writeArtifact is os.WriteFile for run artifacts.
+func writeArtifact(path string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(path, sealArtifact(data), perm)
+}
+
+// readArtifact is os.ReadFile for run artifacts.
+func readArtifact(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err = openArtifact(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// lineSealer encrypts each line written to w on its own, so an appended
+// log stays readable up to its last complete line.
+type lineSealer struct {
+	w   io.WriteCloser
+	buf []byte
+}
+
+func newLineSealer(w io.WriteCloser) io.WriteCloser {
+	if artifactKey == nil {
+		return w
+	}
+	return &lineSealer{w: w}
+}
+
+func (s *lineSealer) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := artifactLinePrefix + base64.StdEncoding.EncodeToString(seal(s.buf[:i+1])) + "\n"
+		s.buf = s.buf[i+1:]
+		if _, err := io.WriteString(s.w, line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+func (s *lineSealer) Close() error { return s.w.Close() }
+
+// runDecrypt prints encrypted run artifacts in the clear, or with -in-place
+// rewrites them so, for instance, fetched pages can be edited.
+func runDecrypt(fs *flag.FlagSet) func() {
+	inPlace := fs.Bool("in-place", false, "rewrite the files decrypted instead of printing them")
+	return func() {
+		if fs.NArg() == 0 {
+			warn("decrypt_usage")
+			os.Exit(2)
+		}
+		cfg, err := ini.Load("config.ini")
+		if err != nil {
+			cfg = ini.Empty()
+		}
+		if err := loadArtifactKey(cfg); err != nil {
+			warn("artifact_key_failed", err)
+			os.Exit(1)
+		}
+		failed := false
+		for _, path := range fs.Args() {
+			data, err := readArtifact(path)
+			if err == nil && *inPlace {
+				err = os.WriteFile(path, data, 0o600)
+			} else if err == nil {
+				_, err = stdout.Write(data)
+			}
+			if err != nil {
+				warn("decrypt_failed", err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
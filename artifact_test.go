@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestEncryptedArtifacts(t *testing.T) {
+	o := newOrchard(t, nil)
+	o.bot.data.Section("").Key("runsDir").SetValue(t.TempDir())
+	cfg := ini.Empty()
+	cfg.Section("").Key("artifactKey").SetValue(strings.Repeat("ab", 32))
+	setForTest(t, &artifactKey, nil)
+	if err := loadArtifactKey(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := o.bot.runPath("checkpoint.json")
+	o.bot.checkpoint = newCheckpoint(checkpoint, o.bot.RunID, o.bot.Namespaces, nil)
+	sink, err := newFileSink(o.bot.runPath("events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addEventSink(sink)
+	debugLog, err := os.Create(o.bot.runPath("http.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &httpDebug.bodies, true)
+	httpDebug.out = debugLog
+	sealHTTPDebug()
+	o.bot.remaining = newRemainingLog(o.bot.runPath("remaining.csv"))
+	o.bot.skipList = loadSkipList(o.bot.runPath("skiplist.json"), time.Hour)
+	o.bot.skipList.note("보호 문서", ErrPermDenied)
+	o.run()
+	closeEventSinks()
+	closeHTTPDebug()
+	o.bot.remaining.note("과수원", []*Job{newJob("과수원", "농장", false, "")}, "과수원")
+	o.bot.remaining.finish()
+	o.bot.skipList.save()
+	o.bot.report.writeCSV(o.bot.runPath("report.csv"))
+	o.bot.writeManifest(o.bot.runPath("manifest.json"), flag.NewFlagSet("edit", flag.ContinueOnError), []*Job{o.job}, docSelection{Only: []string{"과수원"}})
+	o.bot.protectedOut = o.bot.runPath("protected.txt")
+	o.srv.Protect("과수원")
+	o.bot.splitProtected([]string{"과수원"})
+
+	want := map[string]string{
+		"checkpoint.json": "과수원",
+		"events.jsonl":    "과수원",
+		"http.log":        "과수원",
+		"remaining.csv":   "과수원",
+		"skiplist.json":   "보호 문서",
+		"report.csv":      "과수원",
+		"manifest.json":   "과수원",
+		"protected.txt":   "과수원",
+	}
+	for name, text := range want {
+		raw, _ := os.ReadFile(o.bot.runPath(name))
+		if len(raw) == 0 || strings.Contains(string(raw), text) {
+			t.Errorf("%s is empty or in the clear: %q", name, raw)
+		}
+		data, err := readArtifact(o.bot.runPath(name))
+		if err != nil || !strings.Contains(string(data), text) {
+			t.Errorf("readArtifact(%s) = %q, %v; want it to mention %s", name, data, err, text)
+		}
+	}
+	if docs, err := readDocList(o.bot.runPath("protected.txt")); err != nil || !slices.Equal(docs, []string{"과수원"}) {
+		t.Errorf("readDocList of the encrypted protected list = %q, %v", docs, err)
+	}
+
+	if cp, err := loadCheckpoint(checkpoint); err != nil || !slices.Contains(cp.Done, "과수원") {
+		t.Errorf("loadCheckpoint = %+v, %v; want 과수원 done", cp, err)
+	}
+
+	artifactKey = nil
+	if _, err := readArtifact(checkpoint); !errors.Is(err, ErrArtifactNoKey) {
+		t.Errorf("readArtifact without a key = %v, want ErrArtifactNoKey", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// An autoTuner adjusts the backlink workers and the edit rate to how the
+// wiki is coping, from the latency and failures of the latest API calls:
+// when the window's 95th percentile latency passes the target or too many
+// calls fail (errors, 429s, 5xx), it halves both; otherwise it adds one
+// worker and a tenth of the rate, never leaving the configured bounds.
+type autoTuner struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	failed  int
+	limiter *Limiter
+	workers *workerGate
+
+	target            time.Duration
+	errorBudget       float64
+	minRate, maxRate  float64
+	rate              float64
+	maxWorkers, every int
+}
+
+// tuner is the run's autoTuner, nil unless data.ini's autoTune is on.
+var tuner *autoTuner
+
+// loadAutoTuner reads the autoTune settings from data.ini. The configured
+// editsPerMinute is where tuning starts and, unless maxEditsPerMinute is
+// higher, also the fastest it goes.
+func loadAutoTuner(sec *ini.Section, limiter *Limiter, workers *workerGate) *autoTuner {
+	if !sec.Key("autoTune").MustBool(false) {
+		return nil
+	}
+	t := &autoTuner{
+		limiter:     limiter,
+		workers:     workers,
+		target:      sec.Key("latencyTarget").MustDuration(2 * time.Second),
+		errorBudget: sec.Key("errorBudget").MustFloat64(0.05),
+		maxWorkers:  sec.Key("maxWorkers").MustInt(2 * backlinkParallelism),
+		every:       sec.Key("autoTuneWindow").MustInt(20),
+	}
+	t.setBase(sec, sec.Key("editsPerMinute").MustFloat64(60))
+	return t
+}
+
+// setBase restarts tuning from rate, as when a job template sets its own
+// editsPerMinute, with the bounds data.ini's sec gives around it. The
+// bounds are read without MustFloat64, which would store its default in
+// sec and pin them to the first rate.
+func (t *autoTuner) setBase(sec *ini.Section, rate float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rate, t.minRate, t.maxRate = rate, rate/4, rate
+	if v, err := sec.Key("minEditsPerMinute").Float64(); err == nil {
+		t.minRate = v
+	}
+	if v, err := sec.Key("maxEditsPerMinute").Float64(); err == nil {
+		t.maxRate = max(rate, v)
+	}
+}
+
+// observe records one API call and retunes once the window is full.
+func (t *autoTuner) observe(d time.Duration, failed bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, d)
+	if failed {
+		t.failed++
+	}
+	if len(t.samples) < t.every {
+		return
+	}
+	slices.Sort(t.samples)
+	p95 := t.samples[len(t.samples)*95/100]
+	errRate := float64(t.failed) / float64(len(t.samples))
+	t.samples, t.failed = t.samples[:0], 0
+
+	workers, rate := t.workers.size(), t.rate
+	if p95 > t.target || errRate > t.errorBudget {
+		workers, rate = max(1, workers/2), max(t.minRate, rate/2)
+	} else {
+		workers, rate = min(t.maxWorkers, workers+1), min(t.maxRate, rate*1.1)
+	}
+	if workers == t.workers.size() && rate == t.rate {
+		return
+	}
+	t.workers.resize(workers)
+	t.rate = rate
+	t.limiter.SetBase(time.Duration(float64(time.Minute) / rate))
+	say("autotune_adjusted", workers, rate, p95.Round(time.Millisecond), errRate*100)
+}
+
+// A workerGate lets at most its size of goroutines work at once; unlike a
+// channel semaphore it can be resized while they run.
+type workerGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newWorkerGate(n int) *workerGate {
+	g := &workerGate{limit: n}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *workerGate) acquire() {
+	g.mu.Lock()
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.active++
+	g.mu.Unlock()
+}
+
+func (g *workerGate) release() {
+	g.mu.Lock()
+	g.active--
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+func (g *workerGate) size() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+func (g *workerGate) resize(n int) {
+	g.mu.Lock()
+	g.limit = n
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestAutoTuner(t *testing.T) {
+	sec := ini.Empty().Section("")
+	sec.Key("autoTune").SetValue("true")
+	sec.Key("editsPerMinute").SetValue("60")
+	sec.Key("maxEditsPerMinute").SetValue("120")
+	sec.Key("autoTuneWindow").SetValue("10")
+	limiter := newLimiter(60, 1, 0)
+	workers := newWorkerGate(backlinkParallelism)
+	at := loadAutoTuner(sec, limiter, workers)
+
+	for i := 0; i < 10; i++ {
+		at.observe(100*time.Millisecond, false)
+	}
+	if workers.size() != backlinkParallelism+1 || at.rate <= 60 {
+		t.Errorf("after a healthy window: %d workers at %.1f/min, want more", workers.size(), at.rate)
+	}
+	for i := 0; i < 10; i++ {
+		at.observe(100*time.Millisecond, i < 3)
+	}
+	if workers.size() != (backlinkParallelism+1)/2 || at.rate >= 60 {
+		t.Errorf("after a failing window: %d workers at %.1f/min, want fewer", workers.size(), at.rate)
+	}
+	for i := 0; i < 100; i++ {
+		at.observe(10*time.Second, false)
+	}
+	if workers.size() != 1 || at.rate != 15 {
+		t.Errorf("after slow windows: %d workers at %.1f/min, want 1 at the 15/min floor", workers.size(), at.rate)
+	}
+}
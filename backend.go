@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// A wikiBackend serves an engine whose API is not shaped like the seed
+// engine's at all, so no apiSchema can describe it. getPageContent,
+// updatePageContent and listBacklinks hand over to it when config.ini's
+// engine names one; everything else (history, discussions, titles) is
+// unsupported on such wikis.
+type wikiBackend interface {
+	getPage(ctx context.Context, domain, token, title string) (*Page, error)
+	savePage(ctx context.Context, domain, token, title, text, editToken, summary string) (int, error)
+	// backlinks lists the documents linking to title in one call; they
+	// all count as plain links.
+	backlinks(ctx context.Context, domain, token, title string) ([]string, error)
+}
+
+// backend is the configured engine's backend, nil for the seed engine and
+// its forks.
+var backend wikiBackend
+
+// wikiBackends are the engines with their own backend, keyed by the name
+// config.ini's engine uses, with the link syntax their pages are in.
+var wikiBackends = map[string]struct {
+	backend wikiBackend
+	syntax  Syntax
+}{
+	"dokuwiki": {dokuWiki{}, namumark{}},
+	"xwiki":    {xWiki{wiki: "xwiki"}, xwikiSyntax{}},
+}
+
+// backendBacklinks adapts a backend's backlink list to listBacklinks,
+// keeping the documents in namespace. The backends know nothing of the
+// seed engine's namespaces, so a document's namespace is the part of its
+// name before ":", as everywhere else in the bot.
+func backendBacklinks(ctx context.Context, domain, token, title, namespace string, fn func([]Backlink) error) error {
+	docs, err := backend.backlinks(ctx, domain, token, title)
+	if err != nil {
+		return err
+	}
+	var page []Backlink
+	for _, doc := range docs {
+		if namespaceOf(doc) == namespace {
+			page = append(page, Backlink{Document: doc, Flags: "link"})
+		}
+	}
+	return fn(page)
+}
+
+// dokuWiki talks to DokuWiki's JSON-RPC API (lib/exe/jsonrpc.php), which
+// accepts the bot's token as a bearer token.
+type dokuWiki struct{}
+
+// call invokes method; op names it for traces and timeouts.
+func (dokuWiki) call(ctx context.Context, domain, token, op, method string, params map[string]any, result any) error {
+	urlStr := fmt.Sprintf("https://%s/lib/exe/jsonrpc.php/%s", domain, method)
+	resp, err := doRequest(ctx, op, "POST", urlStr, token, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized:
+		return ErrBadToken
+	case http.StatusForbidden:
+		return ErrPermDenied
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return statusError(resp)
+	}
+	if r.Error.Code != 0 {
+		// 121 is "the requested page does not exist"; 111 and 131 refuse
+		// reading or writing the page.
+		switch r.Error.Code {
+		case 121:
+			return ErrGone
+		case 111, 131:
+			return ErrPermDenied
+		}
+		return fmt.Errorf("%s: %s (code %d)", method, r.Error.Message, r.Error.Code)
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+func (d dokuWiki) getPage(ctx context.Context, domain, token, title string) (*Page, error) {
+	var text string
+	if err := d.call(ctx, domain, token, "get_content", "core.getPage", map[string]any{"page": title}, &text); err != nil {
+		return nil, err
+	}
+	return newPage(title, text), nil
+}
+
+func (d dokuWiki) savePage(ctx context.Context, domain, token, title, text, editToken, summary string) (int, error) {
+	var ok bool
+	err := d.call(ctx, domain, token, "save", "core.savePage", map[string]any{"page": title, "text": text, "summary": summary, "isminor": false}, &ok)
+	if err == nil && !ok {
+		err = fmt.Errorf("core.savePage refused %s", title)
+	}
+	return 0, err
+}
+
+func (d dokuWiki) backlinks(ctx context.Context, domain, token, title string) ([]string, error) {
+	var docs []string
+	err := d.call(ctx, domain, token, "backlinks", "core.getPageBackLinks", map[string]any{"page": title}, &docs)
+	return docs, err
+}
+
+// xWiki talks to XWiki's REST API. A title is a page reference such as
+// Space.Sub.Page, the last part being the page and the rest nested spaces.
+type xWiki struct {
+	wiki string
+}
+
+func (x xWiki) pageURL(domain, title string) string {
+	parts := strings.Split(title, ".")
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "https://%s/rest/wikis/%s", domain, url.PathEscape(x.wiki))
+	for _, space := range parts[:max(1, len(parts)-1)] {
+		sb.WriteString("/spaces/" + url.PathEscape(space))
+	}
+	page := "WebHome"
+	if len(parts) > 1 {
+		page = parts[len(parts)-1]
+	}
+	sb.WriteString("/pages/" + url.PathEscape(page))
+	return sb.String()
+}
+
+func (x xWiki) getPage(ctx context.Context, domain, token, title string) (*Page, error) {
+	resp, err := doRequest(ctx, "get_content", "GET", x.pageURL(domain, title)+"?media=json", token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := xwikiStatus(resp); err != nil {
+		return nil, err
+	}
+	var r struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	return newPage(title, r.Content), nil
+}
+
+func (x xWiki) savePage(ctx context.Context, domain, token, title, text, editToken, summary string) (int, error) {
+	payload := map[string]string{"content": text, "comment": summary}
+	resp, err := doRequest(ctx, "save", "PUT", x.pageURL(domain, title)+"?media=json", token, payload)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return 0, xwikiStatus(resp)
+}
+
+// backlinks queries XWiki's link table, which holds one row per link
+// between documents.
+func (x xWiki) backlinks(ctx context.Context, domain, token, title string) ([]string, error) {
+	q := fmt.Sprintf("where doc.fullName in (select link.fullName from XWikiLink as link where link.link = '%s')", strings.ReplaceAll(title, "'", "''"))
+	urlStr := fmt.Sprintf("https://%s/rest/wikis/%s/query?type=hql&media=json&number=-1&q=%s", domain, url.PathEscape(x.wiki), url.QueryEscape(q))
+	resp, err := doRequest(ctx, "backlinks", "GET", urlStr, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := xwikiStatus(resp); err != nil {
+		return nil, err
+	}
+	var r struct {
+		SearchResults []struct {
+			PageFullName string `json:"pageFullName"`
+		} `json:"searchResults"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	var docs []string
+	for _, res := range r.SearchResults {
+		docs = append(docs, res.PageFullName)
+	}
+	return docs, nil
+}
+
+func xwikiStatus(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrBadToken
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrPermDenied
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrGone
+	case resp.StatusCode >= 300:
+		return statusError(resp)
+	}
+	return nil
+}
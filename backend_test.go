@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+
+	"micro-rearalice/internal/fakeseed"
+)
+
+// useEngine configures engine as config.ini would for the rest of the test.
+func useEngine(t *testing.T, engine string) {
+	t.Helper()
+	setForTest(t, &backend, backend)
+	setForTest(t, &linkSyntax, linkSyntax)
+	cfg := ini.Empty()
+	cfg.Section("").Key("engine").SetValue(engine)
+	schema, err := loadEngine(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setForTest(t, &api, schema)
+}
+
+func TestDokuWikiBackend(t *testing.T) {
+	useEngine(t, "dokuwiki")
+	srv := fakeseed.NewDokuWiki(map[string]string{
+		"사과":      "사과는 과일이다.",
+		"과수원":     "[[사과]]를 기른다. [[사과|능금]]도.",
+		"보호 문서":   "[[사과]]",
+		"wiki:농장": "[[사과]]",
+	})
+	defer srv.Close()
+	srv.Protect("보호 문서")
+	o := orchard{srv: srv, bot: newTestBot(t, srv)}
+	o.bot.Namespaces = []string{"문서"}
+	o.job = newJob("사과", "사과(과일)", false, o.bot.LogTemplate)
+	var stderrBuf strings.Builder
+	setForTest[io.Writer](t, &stderr, &stderrBuf)
+
+	o.run()
+	o.checkCounts(t, map[string]int{statusEdited: 1, statusSkipped: 1})
+	o.checkPages(t, map[string]string{
+		"과수원":     "[[사과(과일)]]를 기른다. [[사과(과일)|능금]]도.",
+		"wiki:농장": "[[사과]]",
+	})
+	if want := msg("backend_ns_skipped", 1, "사과", "wiki"); !strings.Contains(stderrBuf.String(), want) {
+		t.Errorf("stderr = %q, want %q", stderrBuf.String(), want)
+	}
+	if e := srv.Edits(); len(e) != 1 || e[0].Log != "사과 → 사과(과일)" {
+		t.Errorf("edits = %+v, want one save of 과수원 with the job's summary", e)
+	}
+	if _, err := backend.getPage(context.Background(), srv.Domain(), "test", "배"); !errors.Is(err, ErrGone) {
+		t.Errorf("fetching a missing page: %v, want ErrGone", err)
+	}
+}
+
+func TestXWikiBackend(t *testing.T) {
+	useEngine(t, "xwiki")
+	srv := fakeseed.NewXWiki(map[string]string{
+		"Main.Apple":       "사과는 과일이다.",
+		"Main.Orchard":     "[[Main.Apple]]을 기른다.\n{{include reference=\"Main.Apple\"/}}",
+		"Garden.Beds.Farm": "[[능금>>doc:Main.Apple||target=\"_blank\"]]",
+		"Main.Protected":   "[[Main.Apple]]",
+		"Main.Unrelated":   "[[Main.Apples]]",
+	})
+	defer srv.Close()
+	srv.Protect("Main.Protected")
+	o := orchard{srv: srv, bot: newTestBot(t, srv)}
+	o.job = newJob("Main.Apple", "Main.Fruit", false, o.bot.LogTemplate)
+
+	o.run()
+	o.checkCounts(t, map[string]int{statusEdited: 2, statusSkipped: 1})
+	o.checkPages(t, map[string]string{
+		"Main.Orchard":     "[[Main.Fruit]]을 기른다.\n{{include reference=\"Main.Fruit\"/}}",
+		"Garden.Beds.Farm": "[[능금>>doc:Main.Fruit||target=\"_blank\"]]",
+		"Main.Unrelated":   "[[Main.Apples]]",
+	})
+	if _, err := backend.getPage(context.Background(), srv.Domain(), "test", "Main.Pear"); !errors.Is(err, ErrGone) {
+		t.Errorf("fetching a missing page: %v, want ErrGone", err)
+	}
+}
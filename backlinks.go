@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// runBacklinks prints a title's backlinks grouped by namespace and kind,
+// to show how much a rename would touch before running it.
+func runBacklinks(fs *flag.FlagSet) func() {
+	namespaces := fs.String("namespace", "", "comma-separated namespaces to list (defaults to data.ini's namespaces)")
+	list := fs.Bool("list", false, "also print every linking document")
+	return func() {
+		if fs.NArg() != 1 {
+			warn("backlinks_usage")
+			os.Exit(2)
+		}
+		title := fs.Arg(0)
+
+		bot := loadBot()
+		nsList := bot.Namespaces
+		if *namespaces != "" {
+			nsList = parseList(*namespaces)
+		}
+		total := 0
+		for _, ns := range nsList {
+			byFlag := make(map[string][]string)
+			err := listBacklinks(context.Background(), bot.Domain, bot.Accounts.Current().Token, title, ns, func(page []Backlink) error {
+				for _, b := range page {
+					byFlag[b.Flags] = append(byFlag[b.Flags], b.Document)
+				}
+				return nil
+			})
+			if err != nil {
+				say("backlink_fetch_failed", ns, err)
+				continue
+			}
+			n := 0
+			for _, docs := range byFlag {
+				n += len(docs)
+			}
+			total += n
+			say("backlinks_namespace", ns, n)
+			flags := make([]string, 0, len(byFlag))
+			for f := range byFlag {
+				flags = append(flags, f)
+			}
+			sort.Strings(flags)
+			for _, f := range flags {
+				say("backlinks_flag", f, len(byFlag[f]))
+				if *list {
+					for _, doc := range byFlag[f] {
+						fmt.Fprintf(humanOut, "    %s\n", doc)
+					}
+				}
+			}
+		}
+		say("backlinks_total", title, total)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runCanaries edits the -canary documents found in queue before anything
+// else and holds the run until they are verified: by the operator, or with
+// data.ini's canaryCheck = auto by checking after canaryWait that every
+// canary still holds the bot's revision (nobody reverted it) and that the
+// new titles exist. It returns the queue without the canaries and whether
+// the run may go on; a failed check stops the run with the rest unedited.
+func (b *Bot) runCanaries(queue []string, docJobs map[string][]*Job, sandbox string, edited map[*Job]int) ([]string, bool) {
+	var canaries []string
+	for _, doc := range b.canaries {
+		if slices.Contains(queue, doc) && !slices.Contains(canaries, doc) {
+			canaries = append(canaries, doc)
+		}
+	}
+	if len(canaries) == 0 || sandbox != "" {
+		return queue, true
+	}
+	queue = slices.DeleteFunc(slices.Clone(queue), func(doc string) bool { return slices.Contains(canaries, doc) })
+	say("canary_start", len(canaries))
+	var saved []string
+	var failed error
+	for i, doc := range canaries {
+		changed, err := b.editDocument(doc, docJobs[doc], sandbox, fmt.Sprintf("canary %d/%d", i+1, len(canaries)))
+		switch {
+		case err == nil:
+			saved = append(saved, doc)
+			for _, job := range changed {
+				edited[job]++
+			}
+		case !errors.Is(err, errUnchanged) && failed == nil:
+			failed = fmt.Errorf("%s: %w", doc, err)
+		}
+		if b.checkpoint != nil {
+			b.checkpoint.markDone(doc)
+		}
+	}
+	if failed == nil {
+		failed = b.checkCanaries(saved, docJobs)
+	}
+	if failed != nil {
+		b.stoppedEarly = true
+		b.stopReason = msg("canary_failed", failed, len(queue))
+		say("canary_failed", failed, len(queue))
+		b.notify("abort", msg("canary_failed", failed, len(queue)))
+		if b.checkpoint != nil {
+			b.checkpoint.save(queue, true)
+			say("resume_hint", b.checkpoint.path)
+		}
+		return queue, false
+	}
+	say("canary_passed", len(saved))
+	return queue, true
+}
+
+// checkCanaries verifies the saved canaries as data.ini's canaryCheck says.
+func (b *Bot) checkCanaries(saved []string, docJobs map[string][]*Job) error {
+	sec := b.data.Section("")
+	if sec.Key("canaryCheck").In("manual", []string{"manual", "auto"}) == "manual" {
+		if strings.ToLower(prompt(msg("prompt_canary", strings.Join(saved, ", ")))) != "y" {
+			return errors.New(msg("canary_rejected"))
+		}
+		return nil
+	}
+	time.Sleep(sec.Key("canaryWait").MustDuration(0))
+	ctx := context.Background()
+	token := b.Accounts.Current().Token
+	checked := make(map[string]bool)
+	for _, doc := range saved {
+		res, _ := b.report.result(doc)
+		history, err := getHistory(ctx, b.Domain, token, doc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", doc, err)
+		}
+		if len(history) == 0 || res.Rev != 0 && history[0].Rev != res.Rev {
+			return fmt.Errorf("%s: %w", doc, ErrOverwritten)
+		}
+		for _, job := range docJobs[doc] {
+			if checked[job.NewTitle] {
+				continue
+			}
+			checked[job.NewTitle] = true
+			// The engine serves a missing document as an empty one.
+			page, err := getPageContent(ctx, b.Domain, token, job.NewTitle)
+			if err == nil && page.Text == "" {
+				err = ErrGone
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", job.NewTitle, err)
+			}
+		}
+	}
+	return nil
+}
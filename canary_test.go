@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestEditQueueCanaries(t *testing.T) {
+	for _, newExists := range []bool{true, false} {
+		t.Run(map[bool]string{true: "verified", false: "failed"}[newExists], func(t *testing.T) {
+			pages := map[string]string{"과수원": "[[사과]]", "농장": "[[사과]]", "시장": "[[사과]]"}
+			if newExists {
+				pages["사과(과일)"] = "과일"
+			}
+			o := newOrchard(t, pages)
+			o.bot.data.Section("").Key("canaryCheck").SetValue("auto")
+			o.bot.canaries = []string{"농장"}
+
+			o.run()
+			edits := o.srv.Edits()
+			if len(edits) == 0 || edits[0].Title != "농장" {
+				t.Fatalf("edits = %v, want the canary 농장 first", edits)
+			}
+			if want := map[bool]int{true: 3, false: 1}[newExists]; len(edits) != want {
+				t.Errorf("got %d edits, want %d", len(edits), want)
+			}
+			if o.bot.stoppedEarly == newExists {
+				t.Errorf("stoppedEarly = %v", o.bot.stoppedEarly)
+			}
+		})
+	}
+}
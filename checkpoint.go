@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Checkpoint records a queued run's progress in a file so an interrupted
+// run can be resumed with -resume. It is rewritten as documents finish.
+type Checkpoint struct {
+	RunID      string          `json:"run_id"`
+	Updated    time.Time       `json:"updated"`
+	Namespaces []string        `json:"namespaces"`
+	Jobs       []CheckpointJob `json:"jobs"`
+	Pending    []string        `json:"pending"`
+	Done       []string        `json:"done"`
+
+	path    string
+	resumed bool
+	mu      sync.Mutex
+	done    map[string]bool
+	saved   time.Time
+}
+
+type CheckpointJob struct {
+	Old      string     `json:"old"`
+	New      string     `json:"new"`
+	KeepText bool       `json:"keep_text,omitempty"`
+	Via      string     `json:"via,omitempty"`
+	Sections []string   `json:"sections,omitempty"`
+	When     Conditions `json:"when"`
+}
+
+// checkpointInterval is the least time between checkpoint writes while
+// documents are being processed.
+const checkpointInterval = 5 * time.Second
+
+func newCheckpoint(path, runID string, namespaces []string, jobs []*Job) *Checkpoint {
+	return &Checkpoint{RunID: runID, Namespaces: namespaces, Jobs: checkpointJobs(jobs), path: path, done: make(map[string]bool)}
+}
+
+func checkpointJobs(jobs []*Job) []CheckpointJob {
+	var out []CheckpointJob
+	for _, job := range jobs {
+		out = append(out, CheckpointJob{Old: job.OldTitle, New: job.NewTitle, KeepText: job.KeepText, Via: job.Via, Sections: job.Sections, When: job.When})
+	}
+	return out
+}
+
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := readArtifact(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpoint{path: path, resumed: true, done: make(map[string]bool)}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	for _, doc := range c.Done {
+		c.done[doc] = true
+	}
+	return c, nil
+}
+
+// resume continues the run cp recorded: the bot takes over its run ID and
+// namespaces and gets back its jobs.
+func (b *Bot) resume(cp *Checkpoint) []*Job {
+	b.useRunID(cp.RunID)
+	b.Namespaces = cp.Namespaces
+	b.checkpoint = cp
+	return cp.jobs(b.LogTemplate)
+}
+
+// jobs rebuilds the run's jobs with the current log template.
+func (c *Checkpoint) jobs(logTemplate string) []*Job {
+	var jobs []*Job
+	for _, j := range c.Jobs {
+		job := newJob(j.Old, j.New, j.KeepText, logTemplate).limitSections(j.Sections)
+		job.Via = j.Via
+		job.When = j.When
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// sync reconciles a resumed run with the backlinks as they are now:
+// pending documents no longer linking were fixed meanwhile and are
+// dropped, newly linking documents are added, and documents the run
+// already processed are not visited again.
+func (c *Checkpoint) sync(current []string) []string {
+	if !c.resumed {
+		return current
+	}
+	linked := make(map[string]bool, len(current))
+	for _, doc := range current {
+		linked[doc] = true
+	}
+	pending := make(map[string]bool, len(c.Pending))
+	kept, dropped := 0, 0
+	for _, doc := range c.Pending {
+		pending[doc] = true
+		if linked[doc] {
+			kept++
+		} else {
+			dropped++
+		}
+	}
+	added := 0
+	var docs []string
+	for _, doc := range current {
+		if c.done[doc] {
+			continue
+		}
+		if !pending[doc] {
+			added++
+		}
+		docs = append(docs, doc)
+	}
+	say("resume_sync", c.RunID, kept, dropped, added, len(c.done))
+	return docs
+}
+
+func (c *Checkpoint) markDone(doc string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[doc] = true
+}
+
+// save writes the checkpoint with queue as the pending documents. Unless
+// force is set, writes closer together than checkpointInterval are
+// skipped.
+func (c *Checkpoint) save(queue []string, force bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !force && time.Since(c.saved) < checkpointInterval {
+		return
+	}
+	c.Updated = time.Now()
+	c.Pending = queue
+	c.Done = c.Done[:0]
+	for doc := range c.done {
+		c.Done = append(c.Done, doc)
+	}
+	sort.Strings(c.Done)
+	data, _ := json.MarshalIndent(c, "", "  ")
+	tmp := c.path + ".tmp"
+	err := writeArtifact(tmp, data, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+	if err != nil {
+		warn("checkpoint_failed", err)
+	}
+	c.saved = time.Now()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// A command is one of the bot's subcommands; help is the message key of
+// its one-line description. define defines the command's flags on fs and
+// returns the command itself, to call once they are parsed. It does
+// nothing else, so help and completion call it for the flags alone.
+type command struct {
+	name   string
+	help   string
+	define func(fs *flag.FlagSet) func()
+}
+
+// commands lists the subcommands in the order help shows them. edit is
+// also what runs when the first argument names no command.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"edit", "cmd_edit", runEdit},
+		{"plan", "cmd_plan", runPlan},
+		{"apply", "cmd_apply", runApply},
+		{"preview", "cmd_preview", runPreview},
+		{"estimate", "cmd_estimate", runEstimate},
+		{"backlinks", "cmd_backlinks", runBacklinks},
+		{"fetch", "cmd_fetch", runFetch},
+		{"contribs", "cmd_contribs", runContribs},
+		{"rollback", "cmd_rollback", runRollback},
+		{"daemon", "cmd_daemon", runDaemon},
+		{"runs", "cmd_runs", runRuns},
+		{"report", "cmd_report", runReport},
+		{"decrypt", "cmd_decrypt", runDecrypt},
+		{"update", "cmd_update", runUpdate},
+		{"help", "cmd_help", runHelp},
+		{"completion", "cmd_completion", runCompletion},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if i < 0 {
+		return command{}, false
+	}
+	return commands[i], true
+}
+
+// run parses args as c's flags and runs c.
+func (c command) run(args []string) {
+	fs := newFlagSet(c.name)
+	run := c.define(fs)
+	fs.Parse(args)
+	run()
+}
+
+// newFlagSet returns the flag set of the subcommand name. Its -h output
+// names the command and describes it before listing the flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "%s\n\n", msg("usage_command", name))
+		if c, ok := findCommand(name); ok {
+			fmt.Fprintf(out, "%s\n\n", msg(c.help))
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// commandFlags returns c's flag set, without running c.
+func commandFlags(c command) *flag.FlagSet {
+	fs := newFlagSet(c.name)
+	c.define(fs)
+	return fs
+}
+
+// runHelp lists the commands, or shows one command's flags.
+func runHelp(fs *flag.FlagSet) func() {
+	return func() {
+		if fs.NArg() > 0 {
+			c, ok := findCommand(fs.Arg(0))
+			if !ok {
+				warn("help_unknown", fs.Arg(0))
+				os.Exit(2)
+			}
+			printBlock(stderr, func(w io.Writer) {
+				fmt.Fprintf(w, "%s\n\n%s\n\n", msg("usage_command", c.name), msg(c.help))
+				cfs := commandFlags(c)
+				cfs.SetOutput(w)
+				cfs.PrintDefaults()
+			})
+			return
+		}
+		printBlock(stderr, func(w io.Writer) {
+			fmt.Fprintf(w, "%s\n\n", msg("usage_main"))
+			for _, c := range commands {
+				fmt.Fprintf(w, "  %-11s %s\n", c.name, msg(c.help))
+			}
+			fmt.Fprintf(w, "\n%s\n", msg("usage_more"))
+		})
+	}
+}
+
+// flagValues completes the values of flags that take one of a few names.
+// The rest complete as file names.
+var flagValues = map[string]func() []string{
+	"output":   func() []string { return []string{"text", "json"} },
+	"template": templateNames,
+	"user":     accountUsers,
+}
+
+// commandArgs completes the first argument of commands that take one of a
+// few words.
+var commandArgs = map[string]func() []string{
+	"help": func() []string {
+		var names []string
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+		return names
+	},
+	"completion": func() []string { return []string{"bash", "zsh", "fish"} },
+	"runs":       func() []string { return []string{"list", "clean"} },
+	"report":     func() []string { return []string{"diff"} },
+}
+
+// templateNames returns the job templates in data.ini.
+func templateNames() []string {
+	cfg, err := ini.Load("data.ini")
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, sec := range cfg.Sections() {
+		if name, ok := strings.CutPrefix(sec.Name(), "template."); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// accountUsers returns the wiki user names of the accounts in config.ini.
+func accountUsers() []string {
+	cfg, err := ini.Load("config.ini")
+	if err != nil {
+		return nil
+	}
+	pool := loadAccounts(cfg)
+	accounts := pool.accounts
+	if pool.privileged != nil && pool.privileged.User != "" {
+		accounts = append(accounts, *pool.privileged)
+	}
+	var users []string
+	for _, a := range accounts {
+		if user := a.wikiUser(); user != "" && !slices.Contains(users, user) {
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// complete returns the candidates for the last of words, the command line
+// after the program name, for the shell completion scripts.
+func complete(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	c, named := findCommand(words[0])
+	if !named {
+		c, _ = findCommand("edit")
+	}
+	var out []string
+	if len(words) == 1 {
+		for _, c := range commands {
+			out = append(out, c.name)
+		}
+	}
+	if args, ok := commandArgs[c.name]; ok && named && len(words) == 2 {
+		return withPrefix(args(), cur)
+	}
+	fs := commandFlags(c)
+	if len(words) > 1 {
+		prev := strings.TrimLeft(words[len(words)-2], "-")
+		if f := fs.Lookup(prev); f != nil && strings.HasPrefix(words[len(words)-2], "-") && !isBoolFlag(f) {
+			if values, ok := flagValues[prev]; ok {
+				return withPrefix(values(), cur)
+			}
+			return nil
+		}
+	}
+	if strings.HasPrefix(cur, "-") || len(words) == 1 {
+		fs.VisitAll(func(f *flag.Flag) { out = append(out, "-"+f.Name) })
+	}
+	return withPrefix(out, cur)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func withPrefix(list []string, prefix string) []string {
+	var out []string
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// runComplete answers a completion script: one candidate per line.
+func runComplete(args []string) {
+	for _, s := range complete(args) {
+		fmt.Println(s)
+	}
+}
+
+// completionScripts hook the shells up to __complete. Without candidates
+// bash and zsh fall back to completing file names.
+var completionScripts = map[string]string{
+	"bash": `_micro_rearalice() {
+	local IFS=$'\n'
+	COMPREPLY=($(micro-rearalice __complete "${COMP_WORDS[@]:1:COMP_CWORD}"))
+}
+complete -o default -F _micro_rearalice micro-rearalice
+`,
+	"zsh": `#compdef micro-rearalice
+_micro_rearalice() {
+	local -a candidates
+	candidates=("${(@f)$(micro-rearalice __complete "${(@)words[2,CURRENT]}")}")
+	if [[ -n $candidates[1] ]]; then
+		compadd -a candidates
+	else
+		_files
+	fi
+}
+compdef _micro_rearalice micro-rearalice
+`,
+	"fish": `complete -c micro-rearalice -a '(micro-rearalice __complete (commandline -opc)[2..-1] (commandline -ct))'
+`,
+}
+
+// runCompletion prints the completion script for a shell.
+func runCompletion(fs *flag.FlagSet) func() {
+	return func() {
+		script, ok := completionScripts[fs.Arg(0)]
+		if !ok {
+			warn("completion_usage")
+			os.Exit(2)
+		}
+		fmt.Print(script)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	for _, tc := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"fe"}, []string{"fetch"}},
+		{[]string{"fetch", "-"}, []string{"-namespace", "-o"}},
+		{[]string{"-out"}, []string{"-output"}},
+		{[]string{"edit", "-output", ""}, []string{"text", "json"}},
+		{[]string{"-batch", ""}, nil},
+		{[]string{"help", "co"}, []string{"contribs", "completion"}},
+		{[]string{"completion", ""}, []string{"bash", "zsh", "fish"}},
+	} {
+		if got := complete(tc.words); !slices.Equal(got, tc.want) {
+			t.Errorf("complete(%q) = %q, want %q", tc.words, got, tc.want)
+		}
+	}
+}
+
+func TestCompleteConfiguredNames(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.ini"), []byte("token = a\nuser = RearAlice\n[account.helper]\ntoken = b\n[privileged]\ntoken = p\n"), 0o600)
+	os.WriteFile(filepath.Join(dir, "data.ini"), []byte("[template.정리]\nnamespaces = 틀\n"), 0o600)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, tc := range []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"contribs", "-user", ""}, []string{"RearAlice", "helper"}},
+		{[]string{"rollback", "-user", "h"}, []string{"helper"}},
+		{[]string{"-template", ""}, []string{"정리"}},
+	} {
+		if got := complete(tc.words); !slices.Equal(got, tc.want) {
+			t.Errorf("complete(%q) = %q, want %q", tc.words, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressTransfer asks the wiki for gzip or deflate responses; it is
+// config.ini's compress, on unless set to false. Page text compresses
+// well, so large pages and backlink listings cost far less bandwidth.
+var compressTransfer = true
+
+// acceptEncoding is sent explicitly, which turns off the transport's own
+// gzip handling; decodeBody takes its place and also covers deflate.
+const acceptEncoding = "gzip, deflate"
+
+// decodeBody replaces a compressed resp.Body with its decoded stream, so
+// callers read plain bytes whatever the wiki sent.
+func decodeBody(resp *http.Response) error {
+	var body io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		body = zr
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send raw
+		// deflate; the zlib header tells them apart.
+		br := bufio.NewReader(resp.Body)
+		if head, _ := br.Peek(2); len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return err
+			}
+			body = zr
+		} else {
+			body = flate.NewReader(br)
+		}
+	default:
+		return nil
+	}
+	resp.Body = decodedBody{body, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody reads the decoded stream and closes the underlying body.
+type decodedBody struct {
+	io.Reader
+	raw io.Closer
+}
+
+func (d decodedBody) Close() error { return d.raw.Close() }
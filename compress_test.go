@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestEditQueueDecodesCompressed(t *testing.T) {
+	for _, coding := range []string{"gzip", "deflate", "raw-deflate"} {
+		t.Run(coding, func(t *testing.T) {
+			o := newOrchard(t, map[string]string{"과수원": "[[사과]]를 기른다."})
+			o.srv.Compress = coding
+
+			o.run()
+			o.checkPages(t, map[string]string{"과수원": "[[사과(과일)]]를 기른다."})
+		})
+	}
+}
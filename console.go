@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// consoleMu serializes everything written to the terminal, so lines from
+// concurrent workers come out whole instead of interleaved.
+var consoleMu sync.Mutex
+
+// consoleWriter writes to w under consoleMu. Each Write reaches w in one
+// piece; fmt's print functions issue one Write per call.
+type consoleWriter struct {
+	w io.Writer
+}
+
+func (c consoleWriter) Write(p []byte) (int, error) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+	return c.w.Write(p)
+}
+
+var (
+	stdout io.Writer = consoleWriter{os.Stdout}
+	stderr io.Writer = consoleWriter{os.Stderr}
+)
+
+// printBlock collects what fn prints and writes it to w at once, keeping
+// multi-line output such as a heading and its diff together.
+func printBlock(w io.Writer, fn func(w io.Writer)) {
+	var buf bytes.Buffer
+	fn(&buf)
+	w.Write(buf.Bytes())
+}
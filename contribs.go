@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type contribFilter struct {
+	Since     time.Time
+	Until     time.Time
+	Namespace string
+	Summary   string
+}
+
+func (f contribFilter) match(c Contribution) bool {
+	date := time.Unix(c.Date, 0)
+	if !f.Since.IsZero() && date.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !date.Before(f.Until) {
+		return false
+	}
+	if f.Namespace != "" && !strings.HasPrefix(c.Document, f.Namespace+":") {
+		return false
+	}
+	return strings.Contains(c.Log, f.Summary)
+}
+
+// listContributions walks user's contributions newest first and calls fn for
+// every one matching the filter. It stops when fn returns false or once
+// edits are older than Since.
+func listContributions(domain, token, user string, filter contribFilter, fn func(Contribution) bool) error {
+	from := ""
+	for {
+		list, next, err := getContributions(context.Background(), domain, token, user, from)
+		if err != nil {
+			return err
+		}
+		for _, c := range list {
+			if !filter.Since.IsZero() && time.Unix(c.Date, 0).Before(filter.Since) {
+				return nil
+			}
+			if filter.match(c) && !fn(c) {
+				return nil
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		from = next
+	}
+}
+
+func runContribs(fs *flag.FlagSet) func() {
+	user := fs.String("user", "", "account whose edits to list (defaults to the configured user)")
+	since := fs.String("since", "", "only edits on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only edits before this date (YYYY-MM-DD)")
+	namespace := fs.String("namespace", "", "only edits in this namespace")
+	summary := fs.String("summary", "", "only edits whose summary contains this text")
+	limit := fs.Int("limit", 50, "maximum number of edits to print (0 for all)")
+	return func() {
+
+		var filter contribFilter
+		var err error
+		if *since != "" {
+			if filter.Since, err = time.ParseInLocation(time.DateOnly, *since, time.Local); err != nil {
+				warn("invalid_flag", "since", err)
+				os.Exit(2)
+			}
+		}
+		if *until != "" {
+			if filter.Until, err = time.ParseInLocation(time.DateOnly, *until, time.Local); err != nil {
+				warn("invalid_flag", "until", err)
+				os.Exit(2)
+			}
+		}
+		filter.Namespace = *namespace
+		filter.Summary = *summary
+
+		bot := loadBot()
+		if *user == "" {
+			*user = bot.Accounts.Current().User
+		}
+		if *user == "" {
+			warn("no_user")
+			os.Exit(2)
+		}
+
+		n := 0
+		err = listContributions(bot.Domain, bot.Accounts.Current().Token, *user, filter, func(c Contribution) bool {
+			fmt.Fprintf(stdout, "%s  r%-6d %s  %s\n", time.Unix(c.Date, 0).Format(time.DateTime), c.Rev, c.Document, c.Log)
+			n++
+			return *limit <= 0 || n < *limit
+		})
+		if err != nil {
+			warn("contribs_failed", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobPaused    = "paused"
+	jobDone      = "done"
+	jobCancelled = "cancelled"
+)
+
+type daemonJob struct {
+	ID       string    `json:"id"`
+	OldTitle string    `json:"old_title"`
+	NewTitle string    `json:"new_title"`
+	State    string    `json:"state"`
+	Priority int       `json:"priority"`
+	Total    int       `json:"total"`
+	Edited   int       `json:"edited"`
+	Failed   int       `json:"failed"`
+	Created  time.Time `json:"created"`
+
+	job       *Job
+	collected bool
+	remaining []string
+	docJobs   map[string][]*Job
+	events    []Event
+	notify    chan struct{}
+	cancel    chan struct{}
+}
+
+// daemon runs rename jobs submitted over its HTTP or gRPC control API one
+// at a time, highest priority first, streaming each job's progress events
+// to interested clients. A running job is paused as soon as a job with a
+// higher priority is submitted and resumes where it left off afterwards.
+type daemon struct {
+	bot   *Bot
+	token string
+
+	mu       sync.Mutex
+	pending  *sync.Cond
+	jobs     []*daemonJob
+	next     int
+	progress time.Time
+
+	probeMu  sync.Mutex
+	probed   time.Time
+	probeErr error
+}
+
+// stallTimeout is how long a running job may go without any document
+// event before /healthz reports the daemon as wedged.
+const stallTimeout = 10 * time.Minute
+
+func runDaemon(fs *flag.FlagSet) func() {
+	listen := fs.String("listen", "127.0.0.1:8080", "address of the HTTP control API")
+	grpcAddr := fs.String("grpc", "", "also serve the gRPC control API on this address (e.g. 127.0.0.1:8081)")
+	diagAddr := fs.String("diag", "", "serve pprof and a status page on this address (e.g. 127.0.0.1:6060)")
+	debugHTTP := debugHTTPFlags(fs)
+	return func() {
+		debugHTTP()
+
+		bot := loadBot()
+		bot.requireToken("daemon")
+		bot.watchDiscuss()
+		d := &daemon{
+			bot:   bot,
+			token: bot.cfg.Section("").Key("controlToken").String(),
+		}
+		d.pending = sync.NewCond(&d.mu)
+		for _, addr := range []string{*listen, *grpcAddr} {
+			if err := checkControlAddr(addr, d.token); addr != "" && err != nil {
+				warn("daemon_needs_token", err)
+				os.Exit(2)
+			}
+		}
+		if *diagAddr != "" {
+			bot.serveDiagnostics(*diagAddr, func() map[string]int {
+				d.mu.Lock()
+				defer d.mu.Unlock()
+				return d.queueState()
+			})
+		}
+		go d.work()
+		if *grpcAddr != "" {
+			go d.serveGRPC(*grpcAddr)
+		}
+
+		say("daemon_listening", *listen)
+		if err := http.ListenAndServe(*listen, d); err != nil {
+			warn("daemon_failed", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func (d *daemon) work() {
+	for {
+		d.run(d.take())
+	}
+}
+
+// take blocks until a job is waiting and returns the one with the highest
+// priority, the oldest first among equals.
+func (d *daemon) take() *daemonJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		var best *daemonJob
+		for _, j := range d.jobs {
+			if (j.State == jobQueued || j.State == jobPaused) && (best == nil || j.Priority > best.Priority) {
+				best = j
+			}
+		}
+		if best != nil {
+			best.State = jobRunning
+			d.progress = time.Now()
+			return best
+		}
+		d.pending.Wait()
+	}
+}
+
+// preempted reports whether a waiting job outranks priority.
+func (d *daemon) preempted(priority int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, j := range d.jobs {
+		if (j.State == jobQueued || j.State == jobPaused) && j.Priority > priority {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *daemon) run(j *daemonJob) {
+	record := func(ev Event) { d.record(j, ev) }
+	d.bot.onEvent.Store(&record)
+	defer d.bot.onEvent.Store(nil)
+
+	if !j.collected {
+		docs, docJobs, _ := d.bot.collectJobBacklinks([]*Job{j.job})
+		d.mu.Lock()
+		j.Total = len(docs)
+		j.remaining = docs
+		j.docJobs = docJobs
+		j.collected = true
+		d.mu.Unlock()
+	}
+	for {
+		select {
+		case <-j.cancel:
+			d.finish(j, jobCancelled)
+			return
+		default:
+		}
+		if d.preempted(j.Priority) {
+			say("daemon_job_paused", j.ID)
+			d.finish(j, jobPaused)
+			return
+		}
+		d.mu.Lock()
+		if len(j.remaining) == 0 {
+			d.mu.Unlock()
+			break
+		}
+		doc := j.remaining[0]
+		j.remaining = j.remaining[1:]
+		jobs := j.docJobs[doc]
+		pos := fmt.Sprintf("%d/%d", j.Total-len(j.remaining), j.Total)
+		d.mu.Unlock()
+		d.bot.editDocument(doc, jobs, "", pos)
+	}
+	d.finish(j, jobDone)
+}
+
+// queueRequest changes a job's remaining documents: "add" appends them
+// (or puts them first with "front"), "remove" drops them and "front"
+// moves queued ones to the head of the queue in the order given.
+type queueRequest struct {
+	Action    string   `json:"action"`
+	Documents []string `json:"documents"`
+	Front     bool     `json:"front"`
+}
+
+// editQueue applies req to j's remaining documents.
+func (d *daemon) editQueue(j *daemonJob, req queueRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !j.collected {
+		return errors.New("the job's documents are not collected yet")
+	}
+	if j.State == jobDone || j.State == jobCancelled {
+		return fmt.Errorf("the job is %s", j.State)
+	}
+	listed := make(map[string]bool, len(req.Documents))
+	for _, doc := range req.Documents {
+		listed[doc] = true
+	}
+	var rest []string
+	for _, doc := range j.remaining {
+		if !listed[doc] {
+			rest = append(rest, doc)
+		}
+	}
+	switch req.Action {
+	case "add":
+		for _, doc := range req.Documents {
+			if j.docJobs[doc] == nil {
+				j.docJobs[doc] = []*Job{j.job}
+			}
+		}
+		j.Total += len(req.Documents) - (len(j.remaining) - len(rest))
+		if req.Front {
+			j.remaining = append(append([]string(nil), req.Documents...), rest...)
+		} else {
+			j.remaining = append(rest, req.Documents...)
+		}
+	case "remove":
+		j.Total -= len(j.remaining) - len(rest)
+		j.remaining = rest
+	case "front":
+		queued := make(map[string]bool, len(j.remaining))
+		for _, doc := range j.remaining {
+			queued[doc] = true
+		}
+		var head []string
+		for _, doc := range req.Documents {
+			if queued[doc] {
+				head = append(head, doc)
+			}
+		}
+		j.remaining = append(head, rest...)
+	default:
+		return fmt.Errorf("unknown action %q", req.Action)
+	}
+	return nil
+}
+
+func (d *daemon) record(j *daemonJob, ev Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch ev.Type {
+	case "edited":
+		j.Edited++
+	case "failed":
+		j.Failed++
+	}
+	j.events = append(j.events, ev)
+	d.progress = ev.Time
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+func (d *daemon) finish(j *daemonJob, state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	j.State = state
+	close(j.notify)
+	j.notify = make(chan struct{})
+}
+
+func (d *daemon) find(id string) *daemonJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, j := range d.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// ServeHTTP implements the control API:
+//
+//	GET    /healthz           liveness: fails when a running job has stalled
+//	GET    /readyz            readiness: API reachable and token accepted
+//	POST   /jobs              submit {"old": ..., "new": ..., "keep_text": ..., "priority": ...}
+//	GET    /jobs              list jobs
+//	GET    /jobs/{id}         show one job
+//	GET    /jobs/{id}/events  stream the job's events as JSON lines
+//	DELETE /jobs/{id}         cancel a queued or running job
+//	GET    /jobs/{id}/queue   list the job's remaining documents
+//	POST   /jobs/{id}/queue   change them: {"action": "add"|"remove"|"front", "documents": [...], "front": ...}
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		d.healthz(w)
+		return
+	case "/readyz":
+		d.readyz(w)
+		return
+	}
+	if !d.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "jobs" || len(parts) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		d.submit(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		d.mu.Lock()
+		data, _ := json.Marshal(d.jobs)
+		d.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	case len(parts) >= 2:
+		j := d.find(parts[1])
+		if j == nil {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case len(parts) == 3 && parts[2] == "events" && r.Method == http.MethodGet:
+			d.streamEvents(w, r, j)
+		case len(parts) == 3 && parts[2] == "queue" && r.Method == http.MethodGet:
+			d.mu.Lock()
+			data, _ := json.Marshal(map[string]any{"remaining": append([]string{}, j.remaining...)})
+			d.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		case len(parts) == 3 && parts[2] == "queue" && r.Method == http.MethodPost:
+			var req queueRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := d.editQueue(j, req); err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			say("daemon_queue_edited", j.ID, req.Action, len(req.Documents))
+			w.WriteHeader(http.StatusNoContent)
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			d.mu.Lock()
+			data, _ := json.Marshal(j)
+			d.mu.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			d.cancel(j)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// submitRequest is a job submitted over either control API.
+type submitRequest struct {
+	Old      string     `json:"old"`
+	New      string     `json:"new"`
+	KeepText bool       `json:"keep_text"`
+	Priority int        `json:"priority"`
+	Sections []string   `json:"sections"`
+	When     Conditions `json:"when"`
+}
+
+func (d *daemon) submit(w http.ResponseWriter, r *http.Request) {
+	var req submitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	j, err := d.add(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	d.mu.Lock()
+	data, _ := json.Marshal(j)
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// add validates req and queues it as a new job.
+func (d *daemon) add(req submitRequest) (*daemonJob, error) {
+	if req.Old == "" || req.New == "" {
+		return nil, errors.New("old and new are required")
+	}
+	job := newJob(req.Old, req.New, req.KeepText, d.bot.LogTemplate).limitSections(req.Sections)
+	job.When = req.When
+	jobs := []*Job{job}
+	inheritNamespaces(jobs, d.bot.LogTemplate, false)
+	job = jobs[0]
+	if errs := validateJob(job); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.next++
+	j := &daemonJob{
+		ID:       fmt.Sprint(d.next),
+		OldTitle: req.Old,
+		NewTitle: job.NewTitle,
+		State:    jobQueued,
+		Priority: req.Priority,
+		Created:  time.Now(),
+		job:      job,
+		notify:   make(chan struct{}),
+		cancel:   make(chan struct{}),
+	}
+	d.jobs = append(d.jobs, j)
+	d.pending.Signal()
+	return j, nil
+}
+
+// authorized reports whether r carries the control token, when one is set.
+func (d *daemon) authorized(r *http.Request) bool {
+	return d.token == "" || r.Header.Get("Authorization") == "Bearer "+d.token
+}
+
+// checkControlAddr refuses to serve a control API on addr without a token
+// unless addr only accepts connections from this machine.
+func checkControlAddr(addr, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s is not a loopback address and config.ini has no controlToken", addr)
+}
+
+func (d *daemon) cancel(j *daemonJob) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch j.State {
+	case jobQueued, jobPaused:
+		j.State = jobCancelled
+		close(j.notify)
+		j.notify = make(chan struct{})
+	case jobRunning:
+		select {
+		case <-j.cancel:
+		default:
+			close(j.cancel)
+		}
+	}
+}
+
+// streamEvents writes the job's events as JSON lines as they happen.
+func (d *daemon) streamEvents(w http.ResponseWriter, r *http.Request, j *daemonJob) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	d.follow(r.Context(), j, func(events []Event) error {
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
+
+// follow passes the job's events to send as they happen until the job
+// finishes, ctx is done or send fails.
+func (d *daemon) follow(ctx context.Context, j *daemonJob, send func([]Event) error) error {
+	sent := 0
+	for {
+		d.mu.Lock()
+		pending := j.events[sent:]
+		notify := j.notify
+		finished := j.State == jobDone || j.State == jobCancelled
+		d.mu.Unlock()
+
+		if err := send(pending); err != nil {
+			return err
+		}
+		sent += len(pending)
+		if finished {
+			return nil
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (d *daemon) queueState() map[string]int {
+	states := make(map[string]int)
+	for _, j := range d.jobs {
+		states[j.State]++
+	}
+	return states
+}
+
+func (d *daemon) healthz(w http.ResponseWriter) {
+	d.mu.Lock()
+	states := d.queueState()
+	stalled := states[jobRunning] > 0 && time.Since(d.progress) > stallTimeout
+	last := d.progress
+	d.mu.Unlock()
+
+	status := http.StatusOK
+	if stalled {
+		status = http.StatusServiceUnavailable
+	}
+	writeStatus(w, status, map[string]any{"stalled": stalled, "last_progress": last, "jobs": states})
+}
+
+// readyz checks that the wiki API is reachable and still accepts the
+// token. The probe reads the watch document; a missing or read-protected
+// one still shows the API answering. The probe result is cached briefly so
+// frequent health checks do not add load to the wiki.
+func (d *daemon) readyz(w http.ResponseWriter) {
+	d.probeMu.Lock()
+	if time.Since(d.probed) > 30*time.Second {
+		_, err := getPageContent(context.Background(), d.bot.Domain, d.bot.Accounts.Current().Token, d.bot.WatchDocument)
+		if errors.Is(err, ErrPermDenied) || errors.Is(err, ErrGone) {
+			err = nil
+		}
+		d.probeErr = err
+		d.probed = time.Now()
+	}
+	err := d.probeErr
+	d.probeMu.Unlock()
+
+	d.mu.Lock()
+	states := d.queueState()
+	d.mu.Unlock()
+
+	body := map[string]any{"api": "ok", "token": "ok", "jobs": states}
+	status := http.StatusOK
+	switch {
+	case errors.Is(err, ErrBadToken), errors.Is(err, ErrBlocked):
+		body["token"] = err.Error()
+		status = http.StatusServiceUnavailable
+	case err != nil:
+		body["api"] = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+	writeStatus(w, status, body)
+}
+
+func writeStatus(w http.ResponseWriter, status int, body any) {
+	data, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
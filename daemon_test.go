@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"micro-rearalice/internal/fakeseed"
+)
+
+func TestReadyzMissingWatchDocument(t *testing.T) {
+	useEngine(t, "dokuwiki")
+	srv := fakeseed.NewDokuWiki(map[string]string{"사과": "사과는 과일이다."})
+	defer srv.Close()
+	d := &daemon{bot: newTestBot(t, srv)}
+	d.bot.WatchDocument = "없는 문서"
+
+	rec := httptest.NewRecorder()
+	d.readyz(rec)
+	if rec.Code != http.StatusOK {
+		t.Errorf("readyz = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
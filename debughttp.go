@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// httpDebug logs every API request when -debug-http is given: method, URL,
+// status and duration, plus the bodies with credentials masked when
+// -debug-http-bodies is set.
+var httpDebug struct {
+	mu     sync.Mutex
+	out    io.WriteCloser
+	bodies bool
+}
+
+// debugHTTPFlags registers -debug-http and -debug-http-bodies on fs. The
+// returned function turns logging on once fs is parsed.
+func debugHTTPFlags(fs *flag.FlagSet) func() {
+	path := fs.String("debug-http", "", "log every API request and response to this file")
+	bodies := fs.Bool("debug-http-bodies", false, "with -debug-http, also log request and response bodies (tokens are masked)")
+	return func() {
+		if *path == "" {
+			return
+		}
+		f, err := os.OpenFile(*path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			warn("debug_http_failed", err)
+			return
+		}
+		httpDebug.mu.Lock()
+		httpDebug.out = f
+		httpDebug.bodies = *bodies
+		httpDebug.mu.Unlock()
+	}
+}
+
+// sealHTTPDebug encrypts the debug log line by line from here on when an
+// artifact key is configured, as it holds page text and titles. The key is
+// loaded after the flags, but before the first request.
+func sealHTTPDebug() {
+	httpDebug.mu.Lock()
+	defer httpDebug.mu.Unlock()
+	if httpDebug.out != nil {
+		httpDebug.out = newLineSealer(httpDebug.out)
+	}
+}
+
+func closeHTTPDebug() {
+	httpDebug.mu.Lock()
+	defer httpDebug.mu.Unlock()
+	if httpDebug.out != nil {
+		for _, line := range httpClient.statsLines() {
+			fmt.Fprintf(httpDebug.out, "%s pool %s\n", time.Now().Format(time.RFC3339Nano), line)
+		}
+		httpDebug.out.Close()
+		httpDebug.out = nil
+	}
+}
+
+func httpDebugEnabled() (on, bodies bool) {
+	httpDebug.mu.Lock()
+	defer httpDebug.mu.Unlock()
+	return httpDebug.out != nil, httpDebug.bodies
+}
+
+// logHTTP writes one request to the debug log. When bodies are logged the
+// response body is read and replaced so the caller can still read it.
+func logHTTP(id, method, urlStr string, reqBody []byte, resp *http.Response, err error, took time.Duration) {
+	on, bodies := httpDebugEnabled()
+	if !on {
+		return
+	}
+	var buf bytes.Buffer
+	status := "-"
+	if resp != nil {
+		status = resp.Status
+	}
+	fmt.Fprintf(&buf, "%s [%s] %s %s %s %v", time.Now().Format(time.RFC3339Nano), id, method, urlStr, status, took.Round(time.Millisecond))
+	if err != nil {
+		fmt.Fprintf(&buf, " error=%q", err)
+	}
+	if u, err := url.Parse(urlStr); err == nil {
+		if p, ok := httpClient.stats()[canonicalAddr(u)]; ok {
+			fmt.Fprintf(&buf, " conns=%d/%d", p.Open, httpClient.perHost)
+		}
+	}
+	buf.WriteByte('\n')
+	if bodies {
+		if len(reqBody) > 0 {
+			fmt.Fprintf(&buf, "  > %s\n", redactBody(reqBody))
+		}
+		if resp != nil {
+			data, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			fmt.Fprintf(&buf, "  < %s\n", redactBody(data))
+		}
+	}
+	httpDebug.mu.Lock()
+	defer httpDebug.mu.Unlock()
+	if httpDebug.out != nil {
+		httpDebug.out.Write(buf.Bytes())
+	}
+}
+
+// secretKeys are JSON fields whose values never reach the debug log.
+var secretKeys = map[string]bool{"token": true, "password": true}
+
+// redactBody masks secret fields of a JSON object body and shortens long
+// bodies. Non-object bodies are only shortened.
+func redactBody(data []byte) string {
+	var obj map[string]any
+	if json.Unmarshal(data, &obj) == nil {
+		for k := range obj {
+			if secretKeys[k] {
+				obj[k] = "***"
+			}
+		}
+		data, _ = json.Marshal(obj)
+	}
+	const limit = 4096
+	if len(data) > limit {
+		return fmt.Sprintf("%s... (%d bytes)", data[:limit], len(data))
+	}
+	return string(data)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// serveDiagnostics exposes pprof under /debug/pprof/ and a JSON status
+// page under /debug/status on addr. It listens separately from the control
+// API so profiling is never reachable unless asked for.
+func (b *Bot) serveDiagnostics(addr string, queue func() map[string]int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/status", func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		status := map[string]any{
+			"run_id":     b.RunID,
+			"goroutines": runtime.NumGoroutine(),
+			"memory": map[string]uint64{
+				"heap_alloc":  mem.HeapAlloc,
+				"heap_inuse":  mem.HeapInuse,
+				"sys":         mem.Sys,
+				"num_gc":      uint64(mem.NumGC),
+				"total_alloc": mem.TotalAlloc,
+			},
+			"limiter":   b.limiter.State(),
+			"http_pool": httpClient.stats(),
+			"results":   b.report.counts(),
+		}
+		if queue != nil {
+			status["queue"] = queue()
+		}
+		data, _ := json.MarshalIndent(status, "", "  ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+	say("diag_listening", addr)
+	go func() {
+		server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		if err := server.ListenAndServe(); err != nil {
+			warn("diag_failed", err)
+		}
+	}()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineDiff renders the changed lines between a and b. Link rewrites never
+// add or remove lines, so lines are compared pairwise when the counts match;
+// otherwise the differing middle block is shown as a whole.
+func lineDiff(a, b string) string {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+	var out strings.Builder
+	if len(al) == len(bl) {
+		for i := range al {
+			if al[i] != bl[i] {
+				fmt.Fprintf(&out, "@@ line %d @@\n-%s\n+%s\n", i+1, al[i], bl[i])
+			}
+		}
+		return out.String()
+	}
+	start := 0
+	for start < len(al) && start < len(bl) && al[start] == bl[start] {
+		start++
+	}
+	ae, be := len(al), len(bl)
+	for ae > start && be > start && al[ae-1] == bl[be-1] {
+		ae--
+		be--
+	}
+	fmt.Fprintf(&out, "@@ line %d @@\n", start+1)
+	for _, l := range al[start:ae] {
+		fmt.Fprintf(&out, "-%s\n", l)
+	}
+	for _, l := range bl[start:be] {
+		fmt.Fprintf(&out, "+%s\n", l)
+	}
+	return out.String()
+}
+
+// changedBytes estimates how many bytes of a differ in b: for each changed
+// line, the span between the line's common prefix and suffix.
+func changedBytes(a, b string) int {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+	if len(al) != len(bl) {
+		return max(len(a), len(b)) - commonAffixes(a, b)
+	}
+	n := 0
+	for i := range al {
+		if al[i] != bl[i] {
+			n += max(len(al[i]), len(bl[i])) - commonAffixes(al[i], bl[i])
+		}
+	}
+	return n
+}
+
+// commonAffixes returns the length of a and b's common prefix plus their
+// common suffix, without letting the two overlap.
+func commonAffixes(a, b string) int {
+	p := 0
+	for p < len(a) && p < len(b) && a[p] == b[p] {
+		p++
+	}
+	s := 0
+	for s < len(a)-p && s < len(b)-p && a[len(a)-1-s] == b[len(b)-1-s] {
+		s++
+	}
+	return p + s
+}
+
+const maxMergeCells = 1 << 22
+
+// merge3 merges the bot's rewrite (ours) of base with a concurrent human
+// edit (theirs). Our changed lines are carried over onto the lines theirs
+// left intact; it reports false if theirs touched any line we rewrote.
+func merge3(base, ours, theirs string) (string, bool) {
+	bl := strings.Split(base, "\n")
+	ol := strings.Split(ours, "\n")
+	tl := strings.Split(theirs, "\n")
+	if len(bl) != len(ol) {
+		return "", false
+	}
+	match, ok := matchLines(bl, tl)
+	if !ok {
+		return "", false
+	}
+	out := append([]string(nil), tl...)
+	for i := range bl {
+		if bl[i] == ol[i] {
+			continue
+		}
+		if match[i] < 0 {
+			return "", false
+		}
+		out[match[i]] = ol[i]
+	}
+	return strings.Join(out, "\n"), true
+}
+
+// matchLines maps every line of a to the index of the same line in b along
+// a longest common subsequence, or -1 if the line is not kept in b.
+func matchLines(a, b []string) ([]int, bool) {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		match[start] = start
+		start++
+	}
+	ae, be := len(a), len(b)
+	for ae > start && be > start && a[ae-1] == b[be-1] {
+		ae--
+		be--
+		match[ae] = be
+	}
+	n, m := ae-start, be-start
+	if n == 0 || m == 0 {
+		return match, true
+	}
+	if n*m > maxMergeCells {
+		return nil, false
+	}
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[start+i] == b[start+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a[start+i] == b[start+j]:
+			match[start+i] = start + j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match, true
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	const (
+		base = "머리말\n[[사과]]를 기른다.\n꼬리말"
+		ours = "머리말\n[[사과(과일)]]를 기른다.\n꼬리말"
+	)
+	for _, c := range []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		wantOK             bool
+	}{
+		{
+			name:   "disjoint edits",
+			base:   base,
+			ours:   ours,
+			theirs: "머리말\n[[사과]]를 기른다.\n새 꼬리말",
+			want:   "머리말\n[[사과(과일)]]를 기른다.\n새 꼬리말",
+			wantOK: true,
+		},
+		{
+			name:   "theirs unchanged",
+			base:   base,
+			ours:   ours,
+			theirs: base,
+			want:   ours,
+			wantOK: true,
+		},
+		{
+			name:   "both change the same line",
+			base:   base,
+			ours:   ours,
+			theirs: "머리말\n[[사과]]를 많이 기른다.\n꼬리말",
+		},
+		{
+			name:   "theirs deletes the rewritten line",
+			base:   base,
+			ours:   ours,
+			theirs: "머리말\n꼬리말",
+		},
+		{
+			name:   "lines inserted before",
+			base:   base,
+			ours:   ours,
+			theirs: "새 줄\n또 새 줄\n머리말\n[[사과]]를 기른다.\n꼬리말",
+			want:   "새 줄\n또 새 줄\n머리말\n[[사과(과일)]]를 기른다.\n꼬리말",
+			wantOK: true,
+		},
+		{
+			name:   "lines deleted before",
+			base:   base,
+			ours:   ours,
+			theirs: "[[사과]]를 기른다.\n꼬리말",
+			want:   "[[사과(과일)]]를 기른다.\n꼬리말",
+			wantOK: true,
+		},
+		{
+			name:   "ours changes the line count",
+			base:   base,
+			ours:   "머리말\n[[사과(과일)]]를\n기른다.\n꼬리말",
+			theirs: base,
+		},
+		{
+			name:   "empty base, nothing rewritten",
+			base:   "",
+			ours:   "",
+			theirs: "새로 쓴 글",
+			want:   "새로 쓴 글",
+			wantOK: true,
+		},
+		{
+			name:   "empty base, theirs replaced the rewritten line",
+			base:   "",
+			ours:   "[[사과(과일)]]",
+			theirs: "새로 쓴 글",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := merge3(c.base, c.ours, c.theirs)
+			if ok != c.wantOK || got != c.want {
+				t.Errorf("merge3 = %q, %v; want %q, %v", got, ok, c.want, c.wantOK)
+			}
+		})
+	}
+}
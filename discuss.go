@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discussPoll       = 15 * time.Second
+	discussMaxBackoff = 5 * time.Minute
+)
+
+// discussActions are the values of data.ini's discussAction, what the bot
+// does when a discussion opens on the watched document:
+//
+//	stop       exit at once (default)
+//	pause      hold editing until the discussion closes, notifying the
+//	           pause and resume events
+//	namespace  finish the namespace being edited, then stop
+var discussActions = []string{"stop", "pause", "namespace"}
+
+// watchDiscuss acts on a discussion opening on the watched document (see
+// discussActions). With data.ini's discussTopic set, only discussions whose
+// topic matches that regular expression count, so unrelated threads on a
+// busy page do not halt the run. A failed check is retried with
+// exponential backoff; only data.ini's watchFailures consecutive failures
+// (5 by default) stop the run, since the bot must not keep editing
+// unwatched for long. An engine without a discussion API cannot be
+// watched, so the bot refuses to start rather than edit with the kill
+// switch silently off.
+func (b *Bot) watchDiscuss() {
+	if slug := b.data.Section("").Key("commandThread").String(); slug != "" {
+		go b.watchCommands(slug)
+	}
+	if b.WatchDocument == "" {
+		return
+	}
+	if !api.has("discuss") {
+		warn("discuss_unsupported", b.WatchDocument, api.Name)
+		os.Exit(2)
+	}
+	sec := b.data.Section("")
+	budget := sec.Key("watchFailures").MustInt(5)
+	action := sec.Key("discussAction").In("stop", discussActions)
+	var topic *regexp.Regexp
+	if pattern := sec.Key("discussTopic").String(); pattern != "" {
+		var err error
+		if topic, err = regexp.Compile(pattern); err != nil {
+			warn("discuss_topic_invalid", pattern, err)
+			os.Exit(2)
+		}
+	}
+	go func() {
+		failures := 0
+		for {
+			open, err := checkDiscuss(context.Background(), b.Domain, b.Accounts.Current().Token, b.WatchDocument, topic)
+			switch {
+			case err != nil:
+				failures++
+				if failures >= budget {
+					say("discuss_watch_gave_up", failures, err)
+					b.notify("abort", msg("discuss_watch_gave_up", failures, err))
+					exitRun(1)
+				}
+				wait := min(discussPoll<<min(failures, 5), discussMaxBackoff)
+				warn("discuss_check_failed", err, failures, budget, wait)
+				time.Sleep(wait)
+				continue
+			case open && action == "pause":
+				if b.paused.pause() {
+					say("discuss_paused", b.WatchDocument)
+					b.notify("pause", msg("discuss_paused", b.WatchDocument))
+				}
+			case open && action == "namespace":
+				say("discuss_open_finish", b.WatchDocument)
+				b.notify("abort", msg("discuss_open_finish", b.WatchDocument))
+				b.stopAfterNamespace.Store(true)
+				return
+			case open:
+				say("discuss_open_stop", b.WatchDocument)
+				b.notify("abort", msg("discuss_open_stop", b.WatchDocument))
+				exitRun(0)
+			default:
+				if b.paused.resume() {
+					say("discuss_resumed", b.WatchDocument)
+					b.notify("resume", msg("discuss_resumed", b.WatchDocument))
+				}
+			}
+			failures = 0
+			time.Sleep(discussPoll)
+		}
+	}()
+}
+
+// exitRun stops the bot away from main, as a watcher goroutine or
+// withAccount does. os.Exit skips main's deferred calls, so the event
+// sinks and the HTTP debug log are flushed here first; otherwise the
+// abort event just notified could be lost.
+func exitRun(code int) {
+	closeEventSinks()
+	closeHTTPDebug()
+	os.Exit(code)
+}
+
+// pauseGate holds callers of wait while paused. Its zero value is open.
+type pauseGate struct {
+	mu sync.Mutex
+	// closed is non-nil while paused and is closed to resume.
+	closed chan struct{}
+}
+
+// pause closes the gate, reporting whether it was open.
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed != nil {
+		return false
+	}
+	g.closed = make(chan struct{})
+	return true
+}
+
+// resume opens the gate, reporting whether it was closed.
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed == nil {
+		return false
+	}
+	close(g.closed)
+	g.closed = nil
+	return true
+}
+
+// wait returns once the gate is open.
+func (g *pauseGate) wait() {
+	g.mu.Lock()
+	ch := g.closed
+	g.mu.Unlock()
+	if ch != nil {
+		<-ch
+	}
+}
+
+// watchCommands polls the comments of a control thread and runs the
+// commands posted there by the users listed in data.ini's commandAdmins:
+//
+//	!stop        stop the bot
+//	!slow 5s     wait the given duration between edits
+//	!fast        go back to the configured edit rate
+func (b *Bot) watchCommands(slug string) {
+	admins := make(map[string]bool)
+	for _, name := range parseList(b.data.Section("").Key("commandAdmins").String()) {
+		admins[name] = true
+	}
+	// Comments posted before the bot started are only used to find where to
+	// begin, so stale commands are never replayed.
+	seen, ready := 0, false
+	for {
+		comments, err := getThreadComments(context.Background(), b.Domain, b.Accounts.Current().Token, slug)
+		if err != nil {
+			warn("command_thread_failed", err)
+		}
+		for _, c := range comments {
+			if c.ID <= seen {
+				continue
+			}
+			seen = c.ID
+			if ready && admins[c.Author] {
+				b.runCommand(c)
+			}
+		}
+		ready = ready || err == nil
+		time.Sleep(15 * time.Second)
+	}
+}
+
+func (b *Bot) runCommand(c ThreadComment) {
+	fields := strings.Fields(c.Text)
+	if len(fields) == 0 {
+		return
+	}
+	switch fields[0] {
+	case "!stop":
+		say("command_stop", c.Author)
+		b.notify("abort", msg("command_stop", c.Author))
+		exitRun(0)
+	case "!slow":
+		if len(fields) < 2 {
+			return
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			say("command_bad_duration", c.Text, c.Author)
+			return
+		}
+		b.limiter.SetInterval(d)
+		say("command_slow", d, c.Author)
+	case "!fast":
+		b.limiter.SetInterval(0)
+		say("command_fast", c.Author)
+	}
+}
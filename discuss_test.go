@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestCheckDiscussTopic(t *testing.T) {
+	o := newOrchard(t, map[string]string{"봇 작업": ""})
+	o.srv.OpenDiscussion("봇 작업", "문단 정리 제안")
+
+	for pattern, want := range map[string]bool{"": true, "봇 중지|RearAlice": false, "정리": true} {
+		var topic *regexp.Regexp
+		if pattern != "" {
+			topic = regexp.MustCompile(pattern)
+		}
+		open, err := checkDiscuss(context.Background(), o.srv.Domain(), "test", "봇 작업", topic)
+		if err != nil || open != want {
+			t.Errorf("checkDiscuss with topic %q = %v, %v; want %v", pattern, open, err, want)
+		}
+	}
+}
+
+func TestCheckDiscussUnsupported(t *testing.T) {
+	o := newOrchard(t, map[string]string{"봇 작업": ""})
+	o.srv.OpenDiscussion("봇 작업", "봇 중지")
+	schema := engineSchemas["opennamu"]
+	setForTest(t, &api, &schema)
+
+	if open, err := checkDiscuss(context.Background(), o.srv.Domain(), "test", "봇 작업", nil); open || !errors.Is(err, ErrUnsupported) {
+		t.Errorf("checkDiscuss on opennamu = %v, %v; want ErrUnsupported", open, err)
+	}
+}
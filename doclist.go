@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// readDocList reads document titles one per line from path, or from
+// standard input when path is "-". Blank lines and lines starting with
+// "#" are skipped, as are repeated titles. An encrypted list, such as
+// -protected-out writes with an artifact key, is decrypted first.
+func readDocList(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		if data, err = io.ReadAll(stdin); err == nil {
+			data, err = openArtifact(data)
+		}
+	} else {
+		data, err = readArtifact(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	docs := []string{}
+	seen := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || seen[line] {
+			continue
+		}
+		seen[line] = true
+		docs = append(docs, line)
+	}
+	return docs, sc.Err()
+}
+
+// docSelection adjusts which documents a run edits. Only replaces the
+// backlinks when it is not nil; Extra adds to them and Exclude removes
+// from either.
+type docSelection struct {
+	Only    []string `json:"only,omitempty"`
+	Extra   []string `json:"extra,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (s docSelection) empty() bool {
+	return s.Only == nil && s.Extra == nil && s.Exclude == nil
+}
+
+// resolveDocuments returns the documents to edit with the jobs that apply
+// to each and the per-namespace counts, printing where they came from.
+// Listed documents get every job, since which ones apply is not known
+// until the page is read.
+func (b *Bot) resolveDocuments(jobs []*Job, sel docSelection) ([]string, map[string][]*Job, map[string]int) {
+	var docs []string
+	docJobs := make(map[string][]*Job)
+	if sel.Only != nil {
+		for _, doc := range sel.Only {
+			docs = append(docs, doc)
+			docJobs[doc] = jobs
+		}
+		say("docs_listed", len(docs))
+	} else {
+		docs, docJobs, _ = b.collectJobBacklinks(jobs)
+		say("docs_discovered", len(docs))
+	}
+	added := 0
+	for _, doc := range sel.Extra {
+		if _, ok := docJobs[doc]; ok {
+			continue
+		}
+		docs = append(docs, doc)
+		docJobs[doc] = jobs
+		added++
+	}
+	if sel.Extra != nil {
+		say("docs_extra", added, len(sel.Extra)-added)
+	}
+	if sel.Exclude != nil {
+		excluded := make(map[string]bool)
+		for _, doc := range sel.Exclude {
+			excluded[doc] = true
+		}
+		kept := docs[:0]
+		for _, doc := range docs {
+			if excluded[doc] {
+				delete(docJobs, doc)
+				continue
+			}
+			kept = append(kept, doc)
+		}
+		say("docs_excluded", len(docs)-len(kept))
+		docs = kept
+	}
+	counts := make(map[string]int)
+	for _, doc := range docs {
+		counts[namespaceOf(doc)]++
+	}
+	return docs, docJobs, counts
+}
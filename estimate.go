@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sort"
+	"time"
+)
+
+// runEstimate reports what a rename would touch — documents, links,
+// namespaces and protected pages — and how long editing them would take
+// at the configured rate, without editing anything.
+func runEstimate(fs *flag.FlagSet) func() {
+	batch := fs.String("batch", "", "ini file listing the rename jobs to estimate")
+	depth := fs.Int("depth", 0, "also count links to redirects of the old title, this many levels deep")
+	subpages := fs.Bool("subpages", false, "also count links to subpages of the old title")
+	countOnly := fs.Bool("count-only", false, "only count backlinks, without fetching documents to count links and protection")
+	return func() {
+
+		bot := loadBot()
+		jobs := bot.buildJobs(jobOptions{batch: *batch, subpages: *subpages, yes: true, depth: *depth})
+		docs, docJobs, counts := bot.collectJobBacklinks(jobs)
+
+		namespaces := make([]string, 0, len(counts))
+		for ns := range counts {
+			namespaces = append(namespaces, ns)
+		}
+		sort.Strings(namespaces)
+		say("estimate_documents", len(docs), len(namespaces))
+		for _, ns := range namespaces {
+			if counts[ns] > 0 {
+				say("namespace_count", ns, counts[ns])
+			}
+		}
+		toEdit := len(docs)
+		var perDoc time.Duration
+		if !*countOnly {
+			links, protected, unchanged := 0, 0, 0
+			for idx, doc := range docs {
+				var page *Page
+				_, err := bot.withAccountFor(context.Background(), doc, func(account Account) (err error) {
+					page, err = getPageContent(context.Background(), bot.Domain, account.Token, doc)
+					return err
+				})
+				switch {
+				case errors.Is(err, ErrPermDenied):
+					protected++
+				case err != nil:
+					say("fetch_failed", doc, idx+1, len(docs), err)
+				default:
+					_, _, n, _ := rewriteAll(docJobs[doc], doc, page.Text)
+					links += n
+					if n == 0 {
+						unchanged++
+					}
+				}
+			}
+			toEdit -= protected + unchanged
+			say("estimate_links", links, max(0, toEdit), unchanged, protected)
+			apiLatency.mu.Lock()
+			if apiLatency.calls > 0 {
+				// Each edit fetches the page and saves it.
+				perDoc = 2 * apiLatency.total / time.Duration(apiLatency.calls)
+			}
+			apiLatency.mu.Unlock()
+		}
+		toEdit = max(0, toEdit)
+		projected := bot.limiter.Projected(toEdit) + time.Duration(toEdit)*perDoc
+		say("estimate_duration", projected.Round(time.Minute), toEdit)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+type Event struct {
+	Time     time.Time `json:"time"`
+	Run      string    `json:"run"`
+	Type     string    `json:"type"`
+	Document string    `json:"document"`
+	Account  string    `json:"account,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// An EventSink receives every run event, for example to keep an audit
+// trail in another system. Write is called from one goroutine at a time.
+type EventSink interface {
+	Write(Event) error
+	Close() error
+}
+
+var (
+	eventsMu   sync.Mutex
+	eventSinks []EventSink
+)
+
+func addEventSink(s EventSink) {
+	eventsMu.Lock()
+	eventSinks = append(eventSinks, s)
+	eventsMu.Unlock()
+}
+
+// closeEventSinks flushes and closes every sink at the end of the run.
+func closeEventSinks() {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for _, s := range eventSinks {
+		if err := s.Close(); err != nil {
+			warn("sink_failed", err)
+		}
+	}
+	eventSinks = nil
+}
+
+// jsonSink writes one JSON event per line.
+type jsonSink struct {
+	enc *json.Encoder
+	c   io.Closer
+}
+
+func (s *jsonSink) Write(ev Event) error { return s.enc.Encode(ev) }
+
+func (s *jsonSink) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
+
+// enableJSONOutput switches stdout to one JSON event per line and moves all
+// human-oriented messages to stderr.
+func enableJSONOutput() {
+	addEventSink(&jsonSink{enc: json.NewEncoder(stdout)})
+	humanOut = stderr
+}
+
+func (b *Bot) emit(typ, doc, account string, err error) {
+	ev := Event{Time: time.Now(), Run: b.RunID, Type: typ, Document: doc, Account: account}
+	if err != nil {
+		ev.Reason = err.Error()
+	}
+	if fn := b.onEvent.Load(); fn != nil {
+		(*fn)(ev)
+	}
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	for _, s := range eventSinks {
+		if err := s.Write(ev); err != nil {
+			warn("sink_failed", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A Corpus is the index.json that fetch writes next to the pages it
+// downloads, recording where each page came from and which revision.
+type Corpus struct {
+	Domain  string       `json:"domain"`
+	Title   string       `json:"title"`
+	Created time.Time    `json:"created"`
+	Pages   []CorpusPage `json:"pages"`
+}
+
+type CorpusPage struct {
+	Title     string `json:"title"`
+	File      string `json:"file"`
+	Namespace string `json:"namespace"`
+	Flags     string `json:"flags"`
+	// BaseHash keeps its old JSON name so directories fetched before
+	// the rename still apply.
+	BaseHash string    `json:"base_rev"`
+	Fetched  time.Time `json:"fetched"`
+	Size     int       `json:"size"`
+}
+
+// corpusIndex is the name of a corpus directory's index file.
+const corpusIndex = "index.json"
+
+// runFetch downloads the raw text of every document linking to a title
+// into a directory, one file per document, for offline analysis.
+func runFetch(fs *flag.FlagSet) func() {
+	dir := fs.String("o", "", "directory to save the documents in (default the run's directory under runsDir)")
+	namespaces := fs.String("namespace", "", "comma-separated namespaces to fetch (defaults to data.ini's namespaces)")
+	return func() {
+		if fs.NArg() != 1 {
+			warn("fetch_usage")
+			os.Exit(2)
+		}
+		title := fs.Arg(0)
+
+		bot := loadBot()
+		if *dir == "" {
+			*dir = bot.runPath("corpus")
+		}
+		nsList := bot.Namespaces
+		if *namespaces != "" {
+			nsList = parseList(*namespaces)
+		}
+		if err := os.MkdirAll(*dir, 0o755); err != nil {
+			warn("fetch_dir_failed", err)
+			os.Exit(1)
+		}
+
+		var links []Backlink
+		for _, ns := range nsList {
+			err := listBacklinks(context.Background(), bot.Domain, bot.Accounts.Current().Token, title, ns, func(page []Backlink) error {
+				links = append(links, page...)
+				return nil
+			})
+			if err != nil {
+				say("backlink_fetch_failed", ns, err)
+			}
+		}
+		say("found_backlinks", len(links))
+
+		corpus := &Corpus{Domain: bot.Domain, Title: title, Created: time.Now()}
+		for idx, link := range links {
+			var page *Page
+			_, err := bot.withAccount(context.Background(), func(account Account) (err error) {
+				page, err = getPageContent(context.Background(), bot.Domain, account.Token, link.Document)
+				return err
+			})
+			if err != nil {
+				say("fetch_failed", link.Document, idx+1, len(links), err)
+				continue
+			}
+			file := corpusFile(link.Document)
+			if err := writeArtifact(filepath.Join(*dir, file), []byte(page.Text), 0o644); err != nil {
+				warn("fetch_write_failed", err)
+				os.Exit(1)
+			}
+			corpus.Pages = append(corpus.Pages, CorpusPage{
+				Title:     link.Document,
+				File:      file,
+				Namespace: namespaceOf(link.Document),
+				Flags:     link.Flags,
+				BaseHash:  page.Hash,
+				Fetched:   page.Fetched,
+				Size:      len(page.Text),
+			})
+		}
+
+		data, _ := json.MarshalIndent(corpus, "", "  ")
+		if err := writeArtifact(filepath.Join(*dir, corpusIndex), data, 0o644); err != nil {
+			warn("fetch_write_failed", err)
+			os.Exit(1)
+		}
+		say("fetch_done", len(corpus.Pages), *dir)
+	}
+}
+
+// corpusFile names the file a fetched page is kept in. Path escaping
+// leaves ":" alone, but Windows does not allow it in file names and
+// namespaced titles are full of it.
+func corpusFile(title string) string {
+	return strings.ReplaceAll(url.PathEscape(title), ":", "%3A") + ".txt"
+}
+
+// applyCorpus saves the pages of a fetched directory whose files were
+// edited locally. A page edited on the wiki since it was fetched is left
+// alone, so nobody's edit is overwritten; saved pages get their new
+// revision recorded in the index, so the directory can be applied again.
+func (b *Bot) applyCorpus(dir, summary string) {
+	indexPath := filepath.Join(dir, corpusIndex)
+	var corpus Corpus
+	data, err := readArtifact(indexPath)
+	if err == nil {
+		err = json.Unmarshal(data, &corpus)
+	}
+	if err != nil {
+		warn("corpus_index_failed", err)
+		os.Exit(1)
+	}
+	if corpus.Domain != b.Domain {
+		warn("corpus_wrong_domain", corpus.Domain, b.Domain)
+		os.Exit(1)
+	}
+	if summary == "" {
+		summary = msg("corpus_summary", b.RunID)
+	}
+	b.watchDiscuss()
+
+	var changed []int
+	for i, p := range corpus.Pages {
+		text, err := readArtifact(filepath.Join(dir, p.File))
+		if err != nil {
+			warn("corpus_read_failed", p.File, err)
+			continue
+		}
+		if hashText(string(text)) != p.BaseHash {
+			changed = append(changed, i)
+		}
+	}
+	say("corpus_changed", len(changed), len(corpus.Pages))
+
+	for n, i := range changed {
+		p := &corpus.Pages[i]
+		text, _ := readArtifact(filepath.Join(dir, p.File))
+		account, err := b.withAccountFor(context.Background(), p.Title, func(account Account) error {
+			page, err := getPageContent(context.Background(), b.Domain, account.Token, p.Title)
+			if err != nil {
+				return err
+			}
+			if page.Hash != p.BaseHash {
+				return fmt.Errorf("%w (fetched at %s)", ErrPageChanged, p.Fetched.Format(time.DateTime))
+			}
+			_, err = updatePageContent(context.Background(), b.Domain, account.Token, p.Title, string(text), page.Token, summary)
+			return err
+		})
+		if err != nil {
+			say("apply_failed", p.Title, n+1, len(changed), err)
+			continue
+		}
+		p.BaseHash, p.Fetched, p.Size = hashText(string(text)), time.Now(), len(text)
+		say("apply_updated", p.Title, n+1, len(changed), account.Name)
+		b.limiter.Wait()
+	}
+
+	data, _ = json.MarshalIndent(corpus, "", "  ")
+	if err := writeArtifact(indexPath, data, 0o644); err != nil {
+		warn("fetch_write_failed", err)
+	}
+}
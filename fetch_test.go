@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestCorpusFile(t *testing.T) {
+	for title, want := range map[string]string{
+		"과수원":           "%EA%B3%BC%EC%88%98%EC%9B%90.txt",
+		"틀:과일":          "%ED%8B%80%3A%EA%B3%BC%EC%9D%BC.txt",
+		"사과/역사":         "%EC%82%AC%EA%B3%BC%2F%EC%97%AD%EC%82%AC.txt",
+		"A:B?C*D<E>F|G": "A%3AB%3FC%2AD%3CE%3EF%7CG.txt",
+	} {
+		if got := corpusFile(title); got != want {
+			t.Errorf("corpusFile(%q) = %s, want %s", title, got, want)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// The gRPC control API is the rearalice.v1.Control service described in
+// proto/control.proto. It is served with net/http's HTTP/2 support, so it
+// needs TLS: config.ini's grpcCert and grpcKey, or a self-signed
+// certificate made at startup whose fingerprint is printed. Messages are
+// encoded by hand; the service is small enough not to need generated code.
+const grpcService = "/rearalice.v1.Control/"
+
+// gRPC status codes the service returns.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcNotFound        = 5
+	grpcUnimplemented   = 12
+	grpcUnauthenticated = 16
+)
+
+// grpcMaxMessage caps the size of a request message.
+const grpcMaxMessage = 4 << 20
+
+func (d *daemon) serveGRPC(addr string) {
+	cert, err := controlCertificate(d.bot.cfg)
+	if err != nil {
+		warn("daemon_failed", err)
+		os.Exit(1)
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(d.grpc),
+		TLSConfig:         &tls.Config{Certificates: []tls.Certificate{cert}},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	say("daemon_grpc_listening", addr)
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		warn("daemon_failed", err)
+		os.Exit(1)
+	}
+}
+
+// controlCertificate loads config.ini's grpcCert and grpcKey, or makes a
+// self-signed certificate for localhost.
+func controlCertificate(cfg *ini.File) (tls.Certificate, error) {
+	sec := cfg.Section("")
+	if sec.Key("grpcCert").String() != "" {
+		return tls.LoadX509KeyPair(sec.Key("grpcCert").String(), sec.Key("grpcKey").String())
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "micro-rearalice"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	sum := sha256.Sum256(der)
+	say("daemon_grpc_selfsigned", hex.EncodeToString(sum[:]))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// grpc serves one call. The status goes in the grpc-status and
+// grpc-message trailers.
+func (d *daemon) grpc(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	code, err := d.grpcCall(w, r)
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if err != nil {
+		w.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+	}
+}
+
+func (d *daemon) grpcCall(w http.ResponseWriter, r *http.Request) (int, error) {
+	if !d.authorized(r) {
+		return grpcUnauthenticated, errors.New("missing or wrong control token")
+	}
+	in, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	fields, err := pbDecode(in)
+	if err != nil {
+		return grpcInvalidArgument, err
+	}
+	method, _ := strings.CutPrefix(r.URL.Path, grpcService)
+	switch method {
+	case "SubmitJob":
+		var req submitRequest
+		for _, f := range fields {
+			switch f.num {
+			case 1:
+				req.Old = string(f.bytes)
+			case 2:
+				req.New = string(f.bytes)
+			case 3:
+				req.KeepText = f.varint != 0
+			case 4:
+				req.Priority = int(int32(f.varint))
+			case 5:
+				req.Sections = append(req.Sections, string(f.bytes))
+			}
+		}
+		j, err := d.add(req)
+		if err != nil {
+			return grpcInvalidArgument, err
+		}
+		return grpcOK, writeGRPCMessage(w, d.jobMessage(j))
+	case "ListJobs":
+		d.mu.Lock()
+		jobs := append([]*daemonJob(nil), d.jobs...)
+		d.mu.Unlock()
+		var out pbMessage
+		for _, j := range jobs {
+			out = out.bytes(1, d.jobMessage(j))
+		}
+		return grpcOK, writeGRPCMessage(w, out)
+	case "GetJob", "CancelJob", "WatchJob":
+		var id string
+		for _, f := range fields {
+			if f.num == 1 {
+				id = string(f.bytes)
+			}
+		}
+		j := d.find(id)
+		if j == nil {
+			return grpcNotFound, fmt.Errorf("no job %q", id)
+		}
+		switch method {
+		case "CancelJob":
+			d.cancel(j)
+		case "WatchJob":
+			flusher, _ := w.(http.Flusher)
+			err := d.follow(r.Context(), j, func(events []Event) error {
+				for _, ev := range events {
+					if err := writeGRPCMessage(w, eventMessage(ev)); err != nil {
+						return err
+					}
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				return nil
+			})
+			return grpcOK, err
+		}
+		return grpcOK, writeGRPCMessage(w, d.jobMessage(j))
+	}
+	return grpcUnimplemented, fmt.Errorf("unknown method %s", r.URL.Path)
+}
+
+// jobMessage encodes j as a rearalice.v1.Job.
+func (d *daemon) jobMessage(j *daemonJob) pbMessage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return pbMessage(nil).
+		string(1, j.ID).
+		string(2, j.OldTitle).
+		string(3, j.NewTitle).
+		string(4, j.State).
+		varint(5, uint64(int64(j.Priority))).
+		varint(6, uint64(j.Total)).
+		varint(7, uint64(j.Edited)).
+		varint(8, uint64(j.Failed)).
+		varint(9, uint64(j.Created.Unix()))
+}
+
+// eventMessage encodes ev as a rearalice.v1.JobEvent.
+func eventMessage(ev Event) pbMessage {
+	return pbMessage(nil).
+		varint(1, uint64(ev.Time.UnixNano())).
+		string(2, ev.Type).
+		string(3, ev.Document).
+		string(4, ev.Account).
+		string(5, ev.Reason)
+}
+
+// readGRPCMessage reads one length-prefixed, uncompressed message.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	if head[0] != 0 {
+		return nil, errors.New("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(head[1:])
+	if n > grpcMaxMessage {
+		return nil, fmt.Errorf("message of %d bytes is too large", n)
+	}
+	msg := make([]byte, n)
+	_, err := io.ReadFull(r, msg)
+	return msg, err
+}
+
+func writeGRPCMessage(w io.Writer, msg []byte) error {
+	var head [5]byte
+	binary.BigEndian.PutUint32(head[1:], uint32(len(msg)))
+	_, err := w.Write(append(head[:], msg...))
+	return err
+}
+
+// pbMessage is a protobuf message being encoded. Fields holding their
+// zero value are left out, as proto3 does.
+type pbMessage []byte
+
+func (m pbMessage) varint(num int, v uint64) pbMessage {
+	if v == 0 {
+		return m
+	}
+	m = binary.AppendUvarint(m, uint64(num)<<3)
+	return binary.AppendUvarint(m, v)
+}
+
+func (m pbMessage) bytes(num int, b []byte) pbMessage {
+	m = binary.AppendUvarint(m, uint64(num)<<3|2)
+	m = binary.AppendUvarint(m, uint64(len(b)))
+	return append(m, b...)
+}
+
+func (m pbMessage) string(num int, s string) pbMessage {
+	if s == "" {
+		return m
+	}
+	return m.bytes(num, []byte(s))
+}
+
+// pbField is one decoded field: varint for wire type 0, bytes for 2.
+type pbField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+var errBadProtobuf = errors.New("malformed protobuf message")
+
+// pbDecode splits a message into its fields. Fixed-width fields, which the
+// service does not use, are skipped.
+func pbDecode(data []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errBadProtobuf
+		}
+		data = data[n:]
+		f := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errBadProtobuf
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return nil, errBadProtobuf
+			}
+			data = data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, errBadProtobuf
+			}
+			f.bytes = data[n : n+int(size)]
+			data = data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return nil, errBadProtobuf
+			}
+			data = data[4:]
+		default:
+			return nil, errBadProtobuf
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// grpcCall makes one unary call to srv and returns the response message
+// and grpc-status.
+func grpcCall(t *testing.T, srv *httptest.Server, token, method string, req pbMessage) ([]byte, string) {
+	t.Helper()
+	var body bytes.Buffer
+	writeGRPCMessage(&body, req)
+	r, _ := http.NewRequest(http.MethodPost, srv.URL+grpcService+method, &body)
+	r.Header.Set("Content-Type", "application/grpc")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("served over %s, want HTTP/2", resp.Proto)
+	}
+	msg, _ := readGRPCMessage(resp.Body)
+	io.Copy(io.Discard, resp.Body) // the trailers follow the body
+	return msg, resp.Trailer.Get("Grpc-Status")
+}
+
+func TestGRPCControl(t *testing.T) {
+	d := &daemon{bot: newOrchard(t, nil).bot, token: "secret"}
+	d.pending = sync.NewCond(&d.mu)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(d.grpc))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	priority := -1
+	submit := pbMessage(nil).string(1, "사과").string(2, "사과(과일)").varint(4, uint64(priority))
+	if _, status := grpcCall(t, srv, "", "SubmitJob", submit); status != "16" {
+		t.Errorf("SubmitJob without the token: status %s, want 16", status)
+	}
+	msg, status := grpcCall(t, srv, "secret", "SubmitJob", submit)
+	fields, err := pbDecode(msg)
+	if status != "0" || err != nil || len(fields) == 0 || string(fields[0].bytes) != "1" {
+		t.Fatalf("SubmitJob = %v, status %s, %v; want job 1", fields, status, err)
+	}
+	if d.jobs[0].Priority != -1 || d.jobs[0].job.NewTitle != "사과(과일)" {
+		t.Errorf("queued job = %+v", d.jobs[0])
+	}
+
+	ref := pbMessage(nil).string(1, "1")
+	if _, status := grpcCall(t, srv, "secret", "CancelJob", ref); status != "0" || d.jobs[0].State != jobCancelled {
+		t.Errorf("CancelJob: status %s, state %s", status, d.jobs[0].State)
+	}
+	if _, status := grpcCall(t, srv, "secret", "GetJob", pbMessage(nil).string(1, "9")); status != "5" {
+		t.Errorf("GetJob of a missing job: status %s, want 5", status)
+	}
+	msg, status = grpcCall(t, srv, "secret", "ListJobs", nil)
+	if fields, _ := pbDecode(msg); status != "0" || len(fields) != 1 {
+		t.Errorf("ListJobs = %v, status %s; want one job", fields, status)
+	}
+}
+
+func TestCheckControlAddr(t *testing.T) {
+	for _, tc := range []struct {
+		addr, token string
+		ok          bool
+	}{
+		{"127.0.0.1:8080", "", true},
+		{"[::1]:8080", "", true},
+		{"localhost:8080", "", true},
+		{"0.0.0.0:8080", "", false},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "secret", true},
+	} {
+		if err := checkControlAddr(tc.addr, tc.token); (err == nil) != tc.ok {
+			t.Errorf("checkControlAddr(%q, %q) = %v", tc.addr, tc.token, err)
+		}
+	}
+}
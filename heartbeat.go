@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat keeps the on-wiki status page of data.ini's statusDocument up
+// to date, so the community can follow a long run without dashboards.
+type heartbeat struct {
+	title string
+	jobs  []*Job
+	total int
+	done  atomic.Int64
+	// lastEdit is the Unix time of the bot's last save, 0 before one.
+	lastEdit atomic.Int64
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// startHeartbeat posts the status page now and then every data.ini
+// statusInterval (10m by default) until the returned stop function runs,
+// which posts a final update. It does nothing without a statusDocument or
+// in sandbox mode.
+func (b *Bot) startHeartbeat(jobs []*Job, total int, sandbox string) func() {
+	sec := b.data.Section("")
+	title := strings.ReplaceAll(sec.Key("statusDocument").String(), "{run}", b.RunID)
+	if title == "" || sandbox != "" {
+		return func() {}
+	}
+	h := &heartbeat{title: title, jobs: jobs, total: total, stop: make(chan struct{})}
+	b.heartbeat = h
+	interval := sec.Key("statusInterval").MustDuration(10 * time.Minute)
+	b.postStatus(h, false)
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.postStatus(h, false)
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(h.stop)
+		h.wg.Wait()
+		b.postStatus(h, true)
+		b.heartbeat = nil
+	}
+}
+
+// processed counts one document as handled, and as saved when edited.
+func (h *heartbeat) processed(edited bool) {
+	if h == nil {
+		return
+	}
+	h.done.Add(1)
+	if edited {
+		h.lastEdit.Store(time.Now().Unix())
+	}
+}
+
+func (b *Bot) postStatus(h *heartbeat, finished bool) {
+	var sb strings.Builder
+	state := msg("status_running")
+	if finished {
+		state = msg("status_finished")
+	}
+	fmt.Fprintf(&sb, "%s\n", msg("status_intro", b.RunID, state, time.Now().Format(time.DateTime)))
+	for _, job := range h.jobs {
+		fmt.Fprintf(&sb, " * [[:%s]] → [[:%s]]\n", job.OldTitle, job.NewTitle)
+	}
+	done := int(h.done.Load())
+	percent := 100.0
+	if h.total > 0 {
+		percent = 100 * float64(min(done, h.total)) / float64(h.total)
+		fmt.Fprintf(&sb, "\n%s\n", msg("status_progress", done, h.total, percent))
+	} else {
+		// A streamed run does not know its total in advance.
+		fmt.Fprintf(&sb, "\n%s\n", msg("status_processed", done))
+	}
+	last := msg("status_no_edit")
+	if t := h.lastEdit.Load(); t != 0 {
+		last = time.Unix(t, 0).Format(time.DateTime)
+	}
+	fmt.Fprintf(&sb, "%s\n", msg("status_last_edit", last))
+	text := sb.String()
+
+	_, err := b.withAccount(context.Background(), func(account Account) error {
+		page, err := getPageContent(context.Background(), b.Domain, account.Token, h.title)
+		if errors.Is(err, ErrGone) {
+			page, err = &Page{Title: h.title}, nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = updatePageContent(context.Background(), b.Domain, account.Token, h.title, text, page.Token, msg("status_summary", b.RunID, percent))
+		return err
+	})
+	if err != nil {
+		warn("status_failed", h.title, err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditQueuePostsStatus(t *testing.T) {
+	o := newOrchard(t, map[string]string{"과수원": "[[사과]]", "과일가게": "[[사과]]"})
+	o.bot.data.Section("").Key("statusDocument").SetValue("사용자:봇/상태")
+
+	o.run()
+	status := o.srv.Page("사용자:봇/상태")
+	if !strings.Contains(status, msg("status_progress", 2, 2, 100.0)) || !strings.Contains(status, msg("status_finished")) {
+		t.Errorf("status page = %q, want the finished run at 100%%", status)
+	}
+}
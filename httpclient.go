@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Every HTTP request the bot makes — to the wiki, webhooks, the metrics
+// gateway, the trace collector and the update server — goes through
+// httpClient, so connections are pooled in one place and no host gets more
+// than config.ini's maxConnsPerHost at once, however many workers run.
+var httpClient = newHTTPClient(defaultConnsPerHost, nil)
+
+const defaultConnsPerHost = 8
+
+// sharedClient is an http.Client that counts, per host, the connections
+// it dials and holds and the requests that reuse them.
+type sharedClient struct {
+	*http.Client
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]*hostPool
+}
+
+type hostPool struct {
+	Open     int `json:"open"`
+	Peak     int `json:"peak"`
+	Dialed   int `json:"dialed"`
+	Reused   int `json:"reused"`
+	InFlight int `json:"in_flight"`
+}
+
+// newHTTPClient returns a client allowing perHost connections to each
+// host. tlsConfig, when not nil, replaces the default TLS settings.
+func newHTTPClient(perHost int, tlsConfig *tls.Config) *sharedClient {
+	c := &sharedClient{perHost: perHost, hosts: make(map[string]*hostPool)}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxConnsPerHost = perHost
+	t.MaxIdleConnsPerHost = perHost
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.update(addr, func(p *hostPool) {
+			p.Dialed++
+			p.Open++
+			p.Peak = max(p.Peak, p.Open)
+		})
+		return &countedConn{Conn: conn, closed: func() { c.update(addr, func(p *hostPool) { p.Open-- }) }}, nil
+	}
+	c.Client = &http.Client{Transport: c.track(t)}
+	return c
+}
+
+// loadHTTPClient reads config.ini's maxConnsPerHost.
+func loadHTTPClient(cfg *ini.File) {
+	perHost := max(1, cfg.Section("").Key("maxConnsPerHost").MustInt(defaultConnsPerHost))
+	if perHost != httpClient.perHost {
+		httpClient = newHTTPClient(perHost, nil)
+	}
+}
+
+func (c *sharedClient) update(addr string, fn func(*hostPool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p := c.hosts[addr]
+	if p == nil {
+		p = &hostPool{}
+		c.hosts[addr] = p
+	}
+	fn(p)
+}
+
+// track counts requests in flight and reused connections. A request is in
+// flight until its response body is closed, as it holds the connection
+// while the body streams in.
+func (c *sharedClient) track(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		addr := canonicalAddr(req.URL)
+		c.update(addr, func(p *hostPool) { p.InFlight++ })
+		done := sync.OnceFunc(func() { c.update(addr, func(p *hostPool) { p.InFlight-- }) })
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				c.update(addr, func(p *hostPool) { p.Reused++ })
+			}
+		}}
+		resp, err := next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+		if err != nil || resp == nil || resp.Body == nil {
+			done()
+			return resp, err
+		}
+		resp.Body = doneOnClose{resp.Body, done}
+		return resp, nil
+	})
+}
+
+// doneOnClose calls done once its body is closed.
+type doneOnClose struct {
+	io.ReadCloser
+	done func()
+}
+
+func (d doneOnClose) Close() error {
+	defer d.done()
+	return d.ReadCloser.Close()
+}
+
+// canonicalAddr is u's host:port, as the transport dials it.
+func canonicalAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "http" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return net.JoinHostPort(u.Hostname(), "443")
+}
+
+// stats returns a snapshot of each host's pool, for the debug log and
+// /debug/status.
+func (c *sharedClient) stats() map[string]hostPool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]hostPool, len(c.hosts))
+	for addr, p := range c.hosts {
+		out[addr] = *p
+	}
+	return out
+}
+
+// statsLines formats stats one host per line, sorted by host.
+func (c *sharedClient) statsLines() []string {
+	stats := c.stats()
+	hosts := make([]string, 0, len(stats))
+	for h := range stats {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	var lines []string
+	for _, h := range hosts {
+		p := stats[h]
+		lines = append(lines, fmt.Sprintf("%s open=%d/%d peak=%d dialed=%d reused=%d in_flight=%d", h, p.Open, c.perHost, p.Peak, p.Dialed, p.Reused, p.InFlight))
+	}
+	return lines
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// countedConn calls closed the first time it is closed.
+type countedConn struct {
+	net.Conn
+	once   sync.Once
+	closed func()
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.closed)
+	return c.Conn.Close()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestHTTPClientPerHostLimit(t *testing.T) {
+	o := newOrchard(t, nil)
+	setForTest(t, &httpClient, newHTTPClient(2, o.srv.Client().Transport.(*http.Transport).TLSClientConfig))
+
+	done := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := getPageContent(context.Background(), o.bot.Domain, "test", "과수원")
+			done <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := httpClient.stats()[o.bot.Domain]
+	if p.Peak > 2 || p.Dialed > 2 || p.Dialed+p.Reused < 10 || p.InFlight != 0 {
+		t.Errorf("pool = %+v, want at most 2 connections serving all 10 requests", p)
+	}
+}
+
+func TestHTTPClientInFlightUntilClosed(t *testing.T) {
+	o := newOrchard(t, nil)
+	setForTest(t, &httpClient, newHTTPClient(2, o.srv.Client().Transport.(*http.Transport).TLSClientConfig))
+
+	resp, err := httpClient.Get("https://" + o.bot.Domain + "/api/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := httpClient.stats()[o.bot.Domain].InFlight; n != 1 {
+		t.Errorf("%d in flight with the body still open, want 1", n)
+	}
+	resp.Body.Close()
+	resp.Body.Close()
+	if n := httpClient.stats()[o.bot.Domain].InFlight; n != 0 {
+		t.Errorf("%d in flight once the body is closed, want 0", n)
+	}
+
+	o.srv.Close()
+	if _, err := httpClient.Get("https://" + o.bot.Domain + "/api/version"); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+	if n := httpClient.stats()[o.bot.Domain].InFlight; n != 0 {
+		t.Errorf("%d in flight after a failed request, want 0", n)
+	}
+}
@@ -0,0 +1,204 @@
+package fakeseed
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NewDokuWiki starts a server speaking DokuWiki's JSON-RPC API instead of
+// the seed engine's: core.getPage, core.savePage and core.getPageBackLinks.
+// Page, Edits and Protect work as on New's servers.
+func NewDokuWiki(pages map[string]string) *Server {
+	s := newBare(pages)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lib/exe/jsonrpc.php/", s.jsonRPC)
+	s.Server = httptest.NewTLSServer(s.private(mux))
+	return s
+}
+
+// rpcError is a JSON-RPC error body with DokuWiki's codes.
+func rpcError(w http.ResponseWriter, code int, message string) {
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": code, "message": message}})
+}
+
+func (s *Server) jsonRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var params struct {
+		Page    string `json:"page"`
+		Text    string `json:"text"`
+		Summary string `json:"summary"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		rpcError(w, -32700, "parse error")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result any
+	switch strings.TrimPrefix(r.URL.Path, "/lib/exe/jsonrpc.php/") {
+	case "core.getPage":
+		text, ok := s.pages[params.Page]
+		if !ok {
+			rpcError(w, 121, "The requested page does not exist")
+			return
+		}
+		result = text
+	case "core.savePage":
+		if s.protected[params.Page] {
+			rpcError(w, 131, "You are not allowed to edit this page")
+			return
+		}
+		s.save(params.Page, params.Text, params.Summary)
+		result = true
+	case "core.getPageBackLinks":
+		result = s.linking(linkRe, params.Page)
+	default:
+		rpcError(w, -32601, "method not found")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
+
+// NewXWiki starts a server speaking XWiki's REST API for the wiki named
+// xwiki: page GET and PUT under /rest/wikis/xwiki/spaces/.../pages/... and
+// the HQL query the bot lists backlinks with. Titles are page references
+// such as Main.Apple.
+func NewXWiki(pages map[string]string) *Server {
+	s := newBare(pages)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/wikis/xwiki/spaces/", s.xwikiPage)
+	mux.HandleFunc("/rest/wikis/xwiki/query", s.xwikiQuery)
+	s.Server = httptest.NewTLSServer(s.private(mux))
+	return s
+}
+
+// xwikiLinkRe captures the target of [[label>>doc:Target||params]] and
+// {{include reference="Target"/}}.
+var xwikiLinkRe = regexp.MustCompile(`\[\[(?:[^\]]*?>>)?(?:doc:)?([^\[\]|>]+?)(?:\|\|[^\]]*)?\]\]|\{\{include reference="([^"]+)"`)
+
+// xwikiTitle turns /rest/wikis/xwiki/spaces/A/spaces/B/pages/C into A.B.C,
+// and a top-level space's WebHome into the space's name.
+func xwikiTitle(path string) (string, bool) {
+	segs := strings.Split(strings.TrimPrefix(path, "/rest/wikis/xwiki/"), "/")
+	n := len(segs)
+	if n < 4 || n%2 != 0 || segs[n-2] != "pages" {
+		return "", false
+	}
+	var parts []string
+	for i := 0; i < n; i += 2 {
+		name, err := url.PathUnescape(segs[i+1])
+		if err != nil || (segs[i] != "spaces" && i < n-2) {
+			return "", false
+		}
+		if i < n-2 || name != "WebHome" || len(parts) != 1 {
+			parts = append(parts, name)
+		}
+	}
+	return strings.Join(parts, "."), true
+}
+
+func (s *Server) xwikiPage(w http.ResponseWriter, r *http.Request) {
+	title, ok := xwikiTitle(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		text, ok := s.pages[title]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"fullName": title, "content": text})
+	case http.MethodPut:
+		var body struct {
+			Content string `json:"content"`
+			Comment string `json:"comment"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		if s.protected[title] {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		status := http.StatusAccepted
+		if _, ok := s.pages[title]; !ok {
+			status = http.StatusCreated
+		}
+		s.save(title, body.Content, body.Comment)
+		w.WriteHeader(status)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// xwikiQueryRe picks the linked page out of the bot's backlink query.
+var xwikiQueryRe = regexp.MustCompile(`link\.link = '((?:[^']|'')*)'`)
+
+func (s *Server) xwikiQuery(w http.ResponseWriter, r *http.Request) {
+	m := xwikiQueryRe.FindStringSubmatch(r.URL.Query().Get("q"))
+	if r.URL.Query().Get("type") != "hql" || m == nil {
+		http.Error(w, "unsupported query", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type result struct {
+		PageFullName string `json:"pageFullName"`
+	}
+	results := []result{}
+	for _, doc := range s.linking(xwikiLinkRe, strings.ReplaceAll(m[1], "''", "'")) {
+		results = append(results, result{doc})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"searchResults": results})
+}
+
+// newBare returns a server holding pages, without an HTTP server yet.
+func newBare(pages map[string]string) *Server {
+	s := &Server{
+		PageSize:  100,
+		pages:     make(map[string]string),
+		protected: make(map[string]bool),
+		discuss:   make(map[string]string),
+	}
+	for title, text := range pages {
+		s.pages[title] = text
+	}
+	return s
+}
+
+// save stores an edit; the caller holds s.mu.
+func (s *Server) save(title, text, log string) {
+	s.rev++
+	s.pages[title] = text
+	s.edits = append(s.edits, Edit{Title: title, Text: text, Log: log, Rev: s.rev})
+}
+
+// linking lists the pages with a link to title that re matches, sorted;
+// the caller holds s.mu.
+func (s *Server) linking(re *regexp.Regexp, title string) []string {
+	docs := []string{}
+	for doc, text := range s.pages {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			if strings.TrimSpace(strings.Join(m[1:], "")) == title {
+				docs = append(docs, doc)
+				break
+			}
+		}
+	}
+	sort.Strings(docs)
+	return docs
+}
@@ -0,0 +1,318 @@
+// Package fakeseed is an in-memory stand-in for the seed wiki's API, for
+// testing the bot end to end without a network. It serves the backlink,
+// edit, history and discuss endpoints over TLS, so the bot's https URLs
+// work unchanged when its HTTP client trusts the server. NewDokuWiki and
+// NewXWiki stand in for the other engines the bot has a backend for.
+package fakeseed
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Edit is one save received by the server.
+type Edit struct {
+	Title string
+	Text  string
+	Log   string
+	Rev   int
+}
+
+type Server struct {
+	*httptest.Server
+
+	// PageSize is how many backlinks one listing page holds. Small values
+	// exercise pagination.
+	PageSize int
+	// Compress, when "gzip", "deflate" or "raw-deflate", compresses every
+	// response for clients that accept it. "raw-deflate" sends deflate
+	// without the zlib header, as some servers do.
+	Compress string
+	// Fields, when set, renames the edit endpoint's fields as a fork of
+	// the engine might, and /api/version describes the renames.
+	Fields map[string]string
+	// Private, when set, refuses requests without a token, as a wiki
+	// closed to anonymous readers would.
+	Private bool
+
+	mu        sync.Mutex
+	pages     map[string]string
+	protected map[string]bool
+	discuss   map[string]string
+	moves     [][2]string
+	failing   int
+	failCode  int
+	rev       int
+	edits     []Edit
+}
+
+// New starts a server holding the given pages, keyed by title.
+func New(pages map[string]string) *Server {
+	s := newBare(pages)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/backlink/", s.backlinks)
+	mux.HandleFunc("/api/edit/", s.edit)
+	mux.HandleFunc("/api/history/", s.history)
+	mux.HandleFunc("/api/discuss/", s.discussList)
+	mux.HandleFunc("/api/version", s.version)
+	mux.HandleFunc("/api/moves", s.moveLog)
+	s.Server = httptest.NewTLSServer(s.flaky(s.private(s.compress(mux))))
+	return s
+}
+
+// Domain is the host:port to configure as the bot's domain.
+func (s *Server) Domain() string {
+	return s.Listener.Addr().String()
+}
+
+// Protect makes saving title fail with the wiki's permission message.
+func (s *Server) Protect(title string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.protected[title] = true
+}
+
+// OpenDiscussion opens a discussion thread on title about topic.
+func (s *Server) OpenDiscussion(title, topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discuss[title] = topic
+}
+
+// Move moves the page from to to and logs the move.
+func (s *Server) Move(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[to] = s.pages[from]
+	delete(s.pages, from)
+	s.moves = append(s.moves, [2]string{from, to})
+}
+
+// SetPage replaces title's text, as a person editing the wiki would.
+func (s *Server) SetPage(title, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[title] = text
+}
+
+// Page returns the current text of title.
+func (s *Server) Page(title string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pages[title]
+}
+
+// Edits returns the saves received so far, in order.
+func (s *Server) Edits() []Edit {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Edit(nil), s.edits...)
+}
+
+// Fail makes the next n requests fail with status, as an overloaded
+// server might.
+func (s *Server) Fail(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failing, s.failCode = n, status
+}
+
+// flaky wraps next to fail requests as Fail asks.
+func (s *Server) flaky(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		fail := s.failing > 0
+		if fail {
+			s.failing--
+		}
+		s.mu.Unlock()
+		if fail {
+			http.Error(w, http.StatusText(s.failCode), s.failCode)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// private wraps next to refuse anonymous requests when s.Private is set.
+func (s *Server) private(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Private && r.Header.Get("Authorization") == "" {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compress wraps next to encode responses as s.Compress asks.
+func (s *Server) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coding := strings.TrimPrefix(s.Compress, "raw-")
+		if coding == "" || !strings.Contains(r.Header.Get("Accept-Encoding"), coding) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var zw io.WriteCloser
+		switch s.Compress {
+		case "gzip":
+			zw = gzip.NewWriter(w)
+		case "deflate":
+			zw = zlib.NewWriter(w)
+		default:
+			zw, _ = flate.NewWriter(w, flate.DefaultCompression)
+		}
+		defer zw.Close()
+		w.Header().Set("Content-Encoding", coding)
+		next.ServeHTTP(encodedWriter{w, zw}, r)
+	})
+}
+
+type encodedWriter struct {
+	http.ResponseWriter
+	w io.Writer
+}
+
+func (e encodedWriter) Write(p []byte) (int, error) { return e.w.Write(p) }
+
+func titleFrom(r *http.Request, prefix string) string {
+	t, _ := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), prefix))
+	return t
+}
+
+func namespaceOf(title string) string {
+	if ns, _, ok := strings.Cut(title, ":"); ok {
+		return ns
+	}
+	return "문서"
+}
+
+var linkRe = regexp.MustCompile(`\[\[[\t\f ]*([^\[\]|#]+?)[\t\f ]*(?:#[^\[\]|]*)?(?:\|[^\[\]]*)?\]\]`)
+
+func (s *Server) backlinks(w http.ResponseWriter, r *http.Request) {
+	target := titleFrom(r, "/api/backlink/")
+	ns := r.URL.Query().Get("namespace")
+	from := r.URL.Query().Get("from")
+
+	s.mu.Lock()
+	var docs []string
+	for title, text := range s.pages {
+		if namespaceOf(title) != ns || title <= from && from != "" {
+			continue
+		}
+		for _, m := range linkRe.FindAllStringSubmatch(text, -1) {
+			if m[1] == target {
+				docs = append(docs, title)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Strings(docs)
+	res := map[string]any{"until": ""}
+	if len(docs) > s.PageSize {
+		docs = docs[:s.PageSize]
+		res["until"] = docs[len(docs)-1]
+	}
+	links := []map[string]string{}
+	for _, d := range docs {
+		links = append(links, map[string]string{"document": d, "flags": "link"})
+	}
+	res["backlinks"] = links
+	json.NewEncoder(w).Encode(res)
+}
+
+func hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) edit(w http.ResponseWriter, r *http.Request) {
+	title := titleFrom(r, "/api/edit/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.protected[title] {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{"status": "편집 요청 권한 때문에 편집 권한이 부족합니다."})
+		return
+	}
+	text := s.pages[title]
+	if r.Method == http.MethodGet {
+		json.NewEncoder(w).Encode(map[string]string{s.field("text"): text, s.field("token"): hash(text)})
+		return
+	}
+	var fields map[string]string
+	json.NewDecoder(r.Body).Decode(&fields)
+	req := struct{ Text, Log, Token string }{fields[s.field("text")], fields[s.field("log")], fields[s.field("token")]}
+	if req.Token != hash(text) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"status": "편집 도중에 다른 사용자가 먼저 편집을 했습니다."})
+		return
+	}
+	s.save(title, req.Text, req.Log)
+	json.NewEncoder(w).Encode(map[string]any{"status": "success", "rev": s.rev})
+}
+
+// field returns the server's name for the edit endpoint's field name.
+func (s *Server) field(name string) string {
+	if renamed, ok := s.Fields[name]; ok {
+		return renamed
+	}
+	return name
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	if s.Fields == nil {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"name": "fakeseed", "version": "1.0", "fields": s.Fields})
+}
+
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	title := titleFrom(r, "/api/history/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := []map[string]any{}
+	for i := len(s.edits) - 1; i >= 0; i-- {
+		if e := s.edits[i]; e.Title == title {
+			history = append(history, map[string]any{"rev": e.Rev, "author": "bot", "log": e.Log, "date": time.Now().Unix()})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"history": history})
+}
+
+func (s *Server) moveLog(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	moves := []map[string]any{}
+	for i := len(s.moves) - 1; i >= 0; i-- {
+		moves = append(moves, map[string]any{"from": s.moves[i][0], "to": s.moves[i][1], "author": "admin", "date": time.Now().Unix()})
+	}
+	json.NewEncoder(w).Encode(map[string]any{"moves": moves})
+}
+
+func (s *Server) discussList(w http.ResponseWriter, r *http.Request) {
+	title := titleFrom(r, "/api/discuss/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []map[string]any{}
+	if topic, ok := s.discuss[title]; ok {
+		list = append(list, map[string]any{"slug": "fake", "topic": topic, "status": "normal"})
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,137 @@
+package fakeseed
+
+import "strings"
+
+// Fixture is a namumark snippet and the text expected after renaming Old
+// to New.
+type Fixture struct {
+	Name     string
+	Old      string
+	New      string
+	KeepText bool
+	Sections []string
+	Near     []string
+	NoQuotes bool
+	Input    string
+	Want     string
+}
+
+var Fixtures = []Fixture{
+	{
+		Name:  "plain link",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과]]는 맛있다.",
+		Want:  "[[사과(과일)]]는 맛있다.",
+	},
+	{
+		Name:     "keep text",
+		Old:      "사과",
+		New:      "사과(과일)",
+		KeepText: true,
+		Input:    "[[사과]]는 맛있다.",
+		Want:     "[[사과(과일)|사과]]는 맛있다.",
+	},
+	{
+		Name:  "display text kept",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과|빨간 열매]]",
+		Want:  "[[사과(과일)|빨간 열매]]",
+	},
+	{
+		Name:  "display text equal to new title collapses",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과|사과(과일)]]",
+		Want:  "[[사과(과일)]]",
+	},
+	{
+		Name:  "display text equal to new title up to whitespace collapses",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과|사과(과일) ]] [[사과| 사과(과일)]]",
+		Want:  "[[사과(과일)]] [[사과(과일)]]",
+	},
+	{
+		Name:  "spaces inside brackets",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[ 사과 ]]",
+		Want:  "[[사과(과일)]]",
+	},
+	{
+		Name:  "longer title untouched",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과나무]] [[사과/역사]]",
+		Want:  "[[사과나무]] [[사과/역사]]",
+	},
+	{
+		Name:  "regex metacharacters in title",
+		Old:   "C++ (언어)",
+		New:   "C++",
+		Input: "[[C++ (언어)]]와 [[C]]",
+		Want:  "[[C++]]와 [[C]]",
+	},
+	{
+		Name:  "section anchor retarget",
+		Old:   "사과#역사",
+		New:   "#연혁",
+		Input: "[[사과#역사]] [[사과#맛]] [[사과]]",
+		Want:  "[[사과#연혁]] [[사과#맛]] [[사과]]",
+	},
+	{
+		Name:  "inside footnote",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "본문[* [[사과]] 참고]",
+		Want:  "본문[* [[사과(과일)]] 참고]",
+	},
+	{
+		Name:  "several on one line",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Input: "[[사과]], [[사과|능금]], [[배]]",
+		Want:  "[[사과(과일)]], [[사과(과일)|능금]], [[배]]",
+	},
+	{
+		Name:     "only in listed sections",
+		Old:      "사과",
+		New:      "사과(과일)",
+		Sections: []string{"관련 문서"},
+		Input:    "[[사과]]\n== 관련 문서 ==\n[[사과]]\n== 각주 ==\n[[사과]]",
+		Want:     "[[사과]]\n== 관련 문서 ==\n[[사과(과일)]]\n== 각주 ==\n[[사과]]",
+	},
+	{
+		Name:  "only near a phrase",
+		Old:   "사과",
+		New:   "사과(과일)",
+		Near:  []string{"과일"},
+		Input: "과일 가게에서 [[사과]]를 샀다." + strings.Repeat(" ", 60) + "[[사과]]를 받았다.",
+		Want:  "과일 가게에서 [[사과(과일)]]를 샀다." + strings.Repeat(" ", 60) + "[[사과]]를 받았다.",
+	},
+	{
+		Name:     "not in quote blocks",
+		Old:      "사과",
+		New:      "사과(과일)",
+		NoQuotes: true,
+		Input:    "> [[사과]]라고 했다.\n[[사과]]",
+		Want:     "> [[사과]]라고 했다.\n[[사과(과일)]]",
+	},
+	{
+		Name:  "template include",
+		Old:   "틀:과일",
+		New:   "틀:과일 정보",
+		Input: "[include(틀:과일)] [include(틀:과일, 이름=사과)] [[틀:과일]]",
+		Want:  "[include(틀:과일 정보)] [include(틀:과일 정보, 이름=사과)] [[틀:과일 정보]]",
+	},
+	{
+		Name:     "file parameters kept",
+		Old:      "파일:사과.png",
+		New:      "파일:사과 사진.png",
+		KeepText: true,
+		Input:    "[[파일:사과.png|width=100]] [[:파일:사과.png]]",
+		Want:     "[[파일:사과 사진.png|width=100]] [[:파일:사과 사진.png]]",
+	},
+}
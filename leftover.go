@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// publishLeftovers saves a namumark table of the documents the run could
+// not edit to data.ini's leftoverDocument, so human editors can finish
+// them by hand. {run} in the document name is replaced with the run ID.
+func (b *Bot) publishLeftovers(jobs []*Job) {
+	title := strings.ReplaceAll(b.data.Section("").Key("leftoverDocument").String(), "{run}", b.RunID)
+	if title == "" {
+		return
+	}
+	var rows []DocResult
+	for _, status := range []string{statusSkipped, statusProtected, statusTooLarge, statusTimedOut, statusFiltered, statusReview, statusFailed, statusDead} {
+		rows = append(rows, b.report.withStatus(status)...)
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n", msg("leftover_intro", b.RunID, time.Now().Format(time.DateTime)))
+	for _, job := range jobs {
+		fmt.Fprintf(&sb, " * [[:%s]] → [[:%s]]\n", job.OldTitle, job.NewTitle)
+	}
+	fmt.Fprintf(&sb, "\n||<tablewidth=100%%> '''%s''' || '''%s''' ||\n", msg("leftover_col_document"), msg("leftover_col_reason"))
+	for _, res := range rows {
+		fmt.Fprintf(&sb, "||[[:%s]]||%s||\n", res.Document, leftoverReason(res))
+	}
+	text := sb.String()
+
+	_, err := b.withAccount(context.Background(), func(account Account) error {
+		page, err := getPageContent(context.Background(), b.Domain, account.Token, title)
+		if err != nil {
+			return err
+		}
+		_, err = updatePageContent(context.Background(), b.Domain, account.Token, title, text, page.Token, msg("leftover_summary", b.RunID))
+		return err
+	})
+	if err != nil {
+		say("leftover_failed", title, err)
+		return
+	}
+	say("leftover_posted", title, len(rows))
+}
+
+func leftoverReason(res DocResult) string {
+	var reason string
+	switch {
+	case res.Status == statusProtected, res.Status == statusSkipped && res.Error == ErrPermDenied.Error():
+		reason = msg("leftover_protected")
+	case res.Status == statusFiltered:
+		reason = msg("leftover_filtered", strings.TrimPrefix(res.Error, "rejected by edit filter: "))
+	default:
+		reason = res.Error
+	}
+	// A literal "||" would end the table cell early.
+	return strings.ReplaceAll(reason, "||", "| |")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Limiter paces edits with a token bucket: tokens refill at the configured
+// rate up to burst, each edit takes one, and a random jitter is added to
+// every wait so edits do not land on an exact beat.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	base     time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+	jitter   time.Duration
+}
+
+func newLimiter(perMinute float64, burst int, jitter time.Duration) *Limiter {
+	if perMinute <= 0 {
+		perMinute = 60
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	interval := time.Duration(float64(time.Minute) / perMinute)
+	return &Limiter{
+		interval: interval,
+		base:     interval,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+		jitter:   jitter,
+	}
+}
+
+func (l *Limiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens * float64(l.interval))
+	}
+	if l.jitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(l.jitter)))
+	}
+	l.mu.Unlock()
+	time.Sleep(wait)
+}
+
+// SetInterval changes the pace to one edit per d; zero restores the
+// configured rate.
+func (l *Limiter) SetInterval(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if d <= 0 {
+		d = l.base
+	}
+	l.interval = d
+}
+
+// configure replaces the limiter's settings, as newLimiter takes them.
+func (l *Limiter) configure(perMinute float64, burst int, jitter time.Duration) {
+	n := newLimiter(perMinute, burst, jitter)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.interval, l.base, l.burst, l.tokens, l.last, l.jitter = n.interval, n.base, n.burst, n.tokens, n.last, n.jitter
+}
+
+// SetBase changes the configured pace to one edit per d, keeping a pace
+// set with SetInterval until it is reset.
+func (l *Limiter) SetBase(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.interval == l.base {
+		l.interval = d
+	}
+	l.base = d
+}
+
+func (l *Limiter) State() map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tokens := min(l.burst, l.tokens+float64(time.Since(l.last))/float64(l.interval))
+	return map[string]any{
+		"interval": l.interval.String(),
+		"burst":    l.burst,
+		"tokens":   tokens,
+		"jitter":   l.jitter.String(),
+	}
+}
+
+// Projected estimates how long n edits take at the configured pace: the
+// first burst goes out at once and every later edit waits one interval,
+// plus half the jitter on average.
+func (l *Limiter) Projected(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n <= 0 {
+		return 0
+	}
+	paced := max(0, n-int(l.burst))
+	return time.Duration(paced)*l.base + time.Duration(n)*l.jitter/2
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	emptyLinkRe   = regexp.MustCompile(`\[\[[\t\f ]*(?:\|[^\[\]]*)?\]\]`)
+	doubledPipeRe = regexp.MustCompile(`\[\[[^\[\]]*\|\|[^\[\]]*\]\]`)
+)
+
+// lintProblems counts the kinds of broken markup in a namumark text.
+func lintProblems(text string) map[string]int {
+	problems := make(map[string]int)
+	if d := strings.Count(text, "[[") - strings.Count(text, "]]"); d != 0 {
+		problems["unbalanced link brackets"] = max(d, -d)
+	}
+	problems["empty link"] = len(emptyLinkRe.FindAllString(text, -1))
+	problems["doubled pipe in link"] = len(doubledPipeRe.FindAllString(text, -1))
+	problems["unclosed footnote"] = unclosedFootnotes(text)
+	return problems
+}
+
+// unclosedFootnotes counts "[*" footnote macros without a closing bracket.
+func unclosedFootnotes(text string) int {
+	n := 0
+	for i := 0; i < len(text); i++ {
+		if !strings.HasPrefix(text[i:], "[*") {
+			continue
+		}
+		depth := 0
+		closed := false
+		for j := i; j < len(text) && !closed; j++ {
+			switch text[j] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+				closed = depth == 0
+			}
+		}
+		if !closed {
+			n++
+		}
+	}
+	return n
+}
+
+// lintRewrite rejects a rewrite that introduced broken markup. Problems
+// already present before the rewrite are not the bot's to fix and do not
+// block the save.
+func lintRewrite(before, after string) error {
+	was, now := lintProblems(before), lintProblems(after)
+	var found []string
+	for kind, n := range now {
+		if n > was[kind] {
+			found = append(found, kind)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	sort.Strings(found)
+	return fmt.Errorf("%w: lint: %s", ErrNeedsReview, strings.Join(found, ", "))
+}
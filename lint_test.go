@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnclosedFootnotes(t *testing.T) {
+	for _, c := range []struct {
+		text string
+		want int
+	}{
+		{"[* 각주] 본문", 0},
+		{"[* 닫히지 않은 각주", 1},
+		{"[* [[사과]]를 보라]", 0},
+		{"[* 바깥 [* 안쪽] 끝]", 0},
+		{"[* 바깥 [* 안쪽] 끝", 1},
+		{"[* 바깥 [* 안쪽 끝", 2},
+		{"[[사과]] [목차]", 0},
+	} {
+		if got := unclosedFootnotes(c.text); got != c.want {
+			t.Errorf("unclosedFootnotes(%q) = %d, want %d", c.text, got, c.want)
+		}
+	}
+}
+
+func TestLintRewrite(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		before, after string
+		// want is the problem the rewrite is rejected for, "" to accept it.
+		want string
+	}{
+		{"clean", "[[사과]]를 기른다.", "[[사과(과일)]]를 기른다.", ""},
+		{"bracket lost", "[[사과]]를 기른다.", "[[사과(과일)]를 기른다.", "unbalanced link brackets"},
+		{"link emptied", "[[사과]]를 기른다.", "[[]]를 기른다.", "empty link"},
+		{"pipe doubled", "[[사과|능금]]", "[[사과(과일)||능금]]", "doubled pipe in link"},
+		{"footnote left open", "[* [[사과]]를 보라]", "[* [[사과(과일)]]를 보라", "unclosed footnote"},
+		{"footnote already open", "[* [[사과]]를 보라", "[* [[사과(과일)]]를 보라", ""},
+		{"another footnote opened", "[* [[사과]]를 보라", "[* [[사과(과일)]]를 보라 [* 또", "unclosed footnote"},
+		{"brackets already unbalanced", "[[사과]] [[배", "[[사과(과일)]] [[배", ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := lintRewrite(c.before, c.after)
+			switch {
+			case c.want == "" && err != nil:
+				t.Errorf("lintRewrite = %v, want nil", err)
+			case c.want != "" && (!errors.Is(err, ErrNeedsReview) || !strings.Contains(err.Error(), c.want)):
+				t.Errorf("lintRewrite = %v, want ErrNeedsReview for %s", err, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// mailReport emails subject and body, with the JSON report attached, to
+// the addresses in config.ini's mailTo. Mail is sent through smtpHost
+// (host:port), authenticating with smtpUser and smtpPassword when set.
+func (b *Bot) mailReport(subject, body string) {
+	sec := b.cfg.Section("")
+	host := sec.Key("smtpHost").String()
+	to := parseList(sec.Key("mailTo").String())
+	if host == "" || len(to) == 0 {
+		return
+	}
+	from := sec.Key("mailFrom").MustString(sec.Key("smtpUser").String())
+	report, _ := json.MarshalIndent(b.report, "", "  ")
+
+	msgData := buildMail(from, to, subject, body, "report-"+b.RunID+".json", report)
+	var auth smtp.Auth
+	if user := sec.Key("smtpUser").String(); user != "" {
+		h, _, _ := net.SplitHostPort(host)
+		auth = smtp.PlainAuth("", user, sec.Key("smtpPassword").String(), h)
+	}
+	if err := smtp.SendMail(host, auth, from, to, msgData); err != nil {
+		warn("mail_failed", err)
+		return
+	}
+	say("mail_sent", strings.Join(to, ", "))
+}
+
+// buildMail assembles a multipart message with a plain-text body and one
+// JSON attachment.
+func buildMail(from string, to []string, subject, text, filename string, attachment []byte) []byte {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64(part, []byte(text))
+	part, _ = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"application/json"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	writeBase64(part, attachment)
+	w.Close()
+	return buf.Bytes()
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	w.Write([]byte(enc + "\r\n"))
+}
@@ -2,37 +2,699 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
-	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/ini.v1"
 )
 
-type Backlink struct {
-	Document string `json:"document"`
-	Flags    string `json:"flags"`
+type Bot struct {
+	RunID         string
+	Domain        string
+	Accounts      *AccountPool
+	Namespaces    []string
+	LogTemplate   string
+	WatchDocument string
+	cfg           *ini.File
+	data          *ini.File
+	limiter       *Limiter
+	workers       *workerGate
+	onEvent       atomic.Pointer[func(Event)]
+	report        *Report
+	// logTemplate is LogTemplate before useRunID fills in {run}.
+	logTemplate string
+	// recentGuard, when set, defers documents a person edited more
+	// recently than this.
+	recentGuard time.Duration
+	limits      changeLimits
+	// checkpoint, when set, records the queue's progress for -resume.
+	checkpoint *Checkpoint
+	// probeProtection splits out documents the bot may not edit before
+	// editing starts; protectedOut is where to list them.
+	probeProtection bool
+	protectedOut    string
+	// deadline, when set, is when editQueue stops and leaves the rest of
+	// the queue to a resumed run; stoppedEarly records that it did, or
+	// that discussAction or a failed canary stopped it, and stopReason
+	// says which for the finish notification.
+	deadline     time.Time
+	stoppedEarly bool
+	stopReason   string
+	// paused holds editing while the watched discussion is open, and
+	// stopAfterNamespace ends the run once the current namespace is done
+	// (see discussAction).
+	paused             pauseGate
+	stopAfterNamespace atomic.Bool
+	// heartbeat, while set, is the run's on-wiki status page.
+	heartbeat *heartbeat
+	// docTimeout bounds the fetch, rewrite and save of one document
+	// (data.ini's documentTimeout); 0 means no limit.
+	docTimeout time.Duration
+	// revalidate checks after each save that the page's latest revision
+	// is still the bot's.
+	revalidate bool
+	skipCache  *SkipCache
+	skipList   *SkipList
+	remaining  *RemainingLog
+	// safeMode is data.ini's safeMode: how many documents of every live
+	// run are previewed, and their diffs confirmed, before anything is
+	// saved. -yes does not skip the confirmation.
+	safeMode int
+	// canaries are the documents edited and verified before the rest of
+	// the queue (see runCanaries).
+	canaries []string
+	// template is the -template the run's job comes from.
+	template *jobTemplate
+	// notifyRules are config.ini's notification rules (see notify).
+	notifyRules []notifyRule
 }
 
-type BacklinkResponse struct {
-	Backlinks []Backlink `json:"backlinks"`
+// changeLimits holds data.ini's maxLinksPerPage and maxChangePercent. A
+// rewrite beyond either is held for review instead of being saved, since
+// it usually means the pattern matched far more than intended.
+type changeLimits struct {
+	MaxLinks   int
+	MaxPercent float64
 }
 
-type Discuss struct {
-	Slug        string `json:"slug"`
-	Topic       string `json:"topic"`
-	UpdatedDate int    `json:"updated_date"`
-	Status      string `json:"status"`
+func (l changeLimits) check(before, after string, links int) error {
+	if l.MaxLinks > 0 && links > l.MaxLinks {
+		return fmt.Errorf("%w: %d links changed, limit %d", ErrNeedsReview, links, l.MaxLinks)
+	}
+	if l.MaxPercent > 0 && len(before) > 0 {
+		pct := float64(changedBytes(before, after)) * 100 / float64(len(before))
+		if pct > l.MaxPercent {
+			return fmt.Errorf("%w: %.1f%% of the page changed, limit %g%%", ErrNeedsReview, pct, l.MaxPercent)
+		}
+	}
+	return nil
 }
 
 func main() {
+	initLocale()
+	defer shutdownTracing()
+	defer closeEventSinks()
+	defer closeHTTPDebug()
+	if len(os.Args) > 1 {
+		if os.Args[1] == "__complete" {
+			runComplete(os.Args[2:])
+			return
+		}
+		if c, ok := findCommand(os.Args[1]); ok {
+			c.run(os.Args[2:])
+			return
+		}
+	}
+	c, _ := findCommand("edit")
+	c.run(os.Args[1:])
+}
+
+func runEdit(fs *flag.FlagSet) func() {
+	sandbox := fs.String("sandbox", "", "write rewritten pages under this prefix (e.g. 'User:Bot/sandbox/') instead of editing them")
+	yes := fs.Bool("yes", false, "start editing without asking for confirmation")
+	stream := fs.Bool("stream", false, "process backlinks page by page as they are listed instead of collecting them first")
+	batch := fs.String("batch", "", "ini file listing several rename jobs to run together")
+	template := fs.String("template", "", "run data.ini's [template.NAME] job template; the old and new titles may follow the flags")
+	output := fs.String("output", "text", "progress output format: text or json (one event per document on stdout)")
+	reportPath := fs.String("report", "", "save the run report as JSON to this file (default report.json in the run's directory)")
+	csvPath := fs.String("csv", "", "save per-document results as CSV to this file")
+	remaining := fs.String("remaining", "", "save the old titles' mentions left in each document, by why they were not changed, as CSV to this file")
+	diagAddr := fs.String("diag", "", "serve pprof and a status page on this address (e.g. 127.0.0.1:6060)")
+	debugHTTP := debugHTTPFlags(fs)
+	depth := fs.Int("depth", 0, "also fix links to redirects of the old title, following redirect chains this many levels deep")
+	subpages := fs.Bool("subpages", false, "also move links to subpages of the old title under the new title")
+	redirect := fs.Bool("redirect", false, "after the run, turn an empty or missing old title into a redirect to the new title")
+	skipRecent := fs.Duration("skip-recent", 0, "defer documents a person edited within this long (e.g. 30m) to a later pass")
+	docsFile := fs.String("docs-file", "", "edit the documents listed one per line in this file (- for stdin) instead of the backlinks")
+	extraFile := fs.String("extra-docs", "", "also edit the documents listed in this file, besides the backlinks")
+	excludeFile := fs.String("exclude-docs", "", "never edit the documents listed in this file")
+	discover := fs.Bool("discover", false, "count backlinks in every namespace first and choose which namespaces to process")
+	canaries := fs.String("canary", "", "edit these documents first (comma-separated) and go on only once their edits are verified")
+	fromMoveLog := fs.Bool("from-move-log", false, "choose the renames from the wiki's latest page moves instead of typing the titles")
+	moves := fs.String("moves", "", "with -from-move-log, take the latest moves of these old titles (comma-separated) without asking")
+	checkpointPath := fs.String("checkpoint", "", "record the run's progress in this file so it can be resumed")
+	protection := fs.Bool("protection", false, "check edit permission of every document first and split out the protected ones")
+	protectedOut := fs.String("protected-out", "", "with -protection, list the protected documents in this file for a privileged account")
+	sections := fs.String("sections", "", "only rewrite links under these headings (comma-separated, '*' matches any text)")
+	sample := fs.Int("sample", 0, "only preview the diffs of this many random documents per namespace, without editing")
+	manifestPath := fs.String("manifest", "", "write the run manifest to this file (default manifest.json in the run's directory)")
+	revalidate := fs.Bool("revalidate", false, "after each save, check the latest revision is the bot's and redo the document if it was overwritten")
+	skipCache := fs.String("skip-cache", "", "remember documents with nothing to change in this file and skip them on reruns of the same jobs")
+	skipList := fs.String("skip-list", "", "remember documents the bot may never edit or that opted out in this file, and skip them in later runs")
+	maxDuration := fs.Duration("max-duration", 0, "stop cleanly after this long (e.g. 2h), saving a checkpoint to resume from")
+	resume := fs.String("resume", "", "resume the run recorded in this checkpoint file or run directory (by run ID), syncing its queue with the current backlinks")
+	return func() {
+		debugHTTP()
+		if *output == "json" {
+			enableJSONOutput()
+		}
+		if *fromMoveLog && *yes && *moves == "" {
+			warn("move_log_needs_pick")
+			os.Exit(2)
+		}
+
+		bot := loadBot()
+		bot.requireToken("edit")
+		if *template != "" {
+			bot.applyTemplate(*template, fs, fs.Args())
+		}
+		bot.recentGuard = *skipRecent
+		bot.probeProtection, bot.protectedOut = *protection, *protectedOut
+		bot.revalidate = *revalidate
+		if *skipCache != "" && *sandbox == "" {
+			bot.skipCache = loadSkipCache(*skipCache, bot.data.Section("").Key("skipCacheAge").MustDuration(7*24*time.Hour))
+		}
+		bot.canaries = parseList(*canaries)
+		if *remaining != "" && *sandbox == "" {
+			bot.remaining = newRemainingLog(*remaining)
+		}
+		if *skipList != "" && *sandbox == "" {
+			bot.skipList = loadSkipList(*skipList, bot.data.Section("").Key("skipListAge").MustDuration(30*24*time.Hour))
+		}
+		bot.watchDiscuss()
+
+		var jobs []*Job
+		if *resume != "" {
+			cp, err := loadCheckpoint(resumePath(bot.data, *resume))
+			if err != nil {
+				warn("checkpoint_load_failed", err)
+				os.Exit(1)
+			}
+			say("resuming", cp.RunID, cp.Updated.Format(time.DateTime))
+			jobs = bot.resume(cp)
+		} else {
+			jobs = bot.buildJobs(jobOptions{
+				batch:       *batch,
+				subpages:    *subpages,
+				discover:    *discover,
+				fromMoveLog: *fromMoveLog,
+				moves:       parseList(*moves),
+				yes:         *yes,
+				depth:       *depth,
+			})
+			for _, job := range jobs {
+				if len(job.Sections) == 0 {
+					job.limitSections(parseList(*sections))
+				}
+			}
+			if *checkpointPath == "" && *maxDuration > 0 {
+				*checkpointPath = bot.runPath("checkpoint.json")
+			}
+			if *checkpointPath != "" {
+				bot.checkpoint = newCheckpoint(*checkpointPath, bot.RunID, bot.Namespaces, jobs)
+			}
+		}
+		var err error
+		var sel docSelection
+		for _, f := range []struct {
+			path string
+			list *[]string
+		}{{*docsFile, &sel.Only}, {*extraFile, &sel.Extra}, {*excludeFile, &sel.Exclude}} {
+			if f.path == "" {
+				continue
+			}
+			if *f.list, err = readDocList(f.path); err != nil {
+				warn("docs_file_failed", err)
+				os.Exit(1)
+			}
+		}
+		if *sample > 0 {
+			bot.previewSample(jobs, sel, *sample)
+			return
+		}
+		if *resume == "" {
+			if *manifestPath == "" {
+				*manifestPath = bot.runPath("manifest.json")
+			}
+			bot.writeManifest(*manifestPath, fs, jobs, sel)
+		}
+		if *reportPath == "" {
+			*reportPath = bot.runPath("report.json")
+		}
+		if sink, err := newFileSink(bot.runPath("events.jsonl")); err == nil {
+			addEventSink(sink)
+		} else {
+			warn("run_dir_failed", err)
+		}
+		if *sandbox != "" {
+			say("sandbox_mode", *sandbox)
+		}
+		var edited map[*Job]int
+		if *maxDuration > 0 {
+			bot.deadline = time.Now().Add(*maxDuration)
+		}
+		if *stream && (bot.safeMode > 0 || len(bot.canaries) > 0) && *sandbox == "" {
+			say("stream_needs_queue")
+			*stream = false
+		}
+		if *stream && sel.empty() && bot.checkpoint == nil {
+			edited = bot.streamEdit(jobs, *sandbox)
+		} else if edited = bot.editQueue(jobs, sel, *sandbox, *yes, *diagAddr); edited == nil {
+			return
+		}
+		bot.skipCache.save()
+		bot.skipList.save()
+		bot.remaining.finish()
+		if bot.stoppedEarly {
+			bot.report.finish(*reportPath, *csvPath)
+			bot.pushMetrics()
+			bot.notify("finish", bot.stopReason)
+			return
+		}
+		if *sandbox == "" {
+			for _, job := range jobs {
+				bot.postNotice(job, edited[job])
+			}
+			if *redirect {
+				bot.createRedirects(jobs)
+			}
+		}
+		bot.finishRun(jobs, *sandbox, *reportPath, *csvPath)
+	}
+}
+
+// jobOptions say where buildJobs reads the run's jobs from and how far it
+// expands them.
+type jobOptions struct {
+	// batch is the batch file to read, "" for none.
+	batch    string
+	subpages bool
+	// discover has the operator choose the namespaces once the jobs are
+	// known.
+	discover    bool
+	fromMoveLog bool
+	// moves are the old titles to take from the move log without asking.
+	moves []string
+	yes   bool
+	// depth is how many levels of redirects to the old titles to follow.
+	depth int
+}
+
+// buildJobs reads the run's jobs from the batch file, the move log, the
+// job template or the prompt and expands patterns, subpages and redirects
+// into jobs of their own.
+func (b *Bot) buildJobs(opts jobOptions) []*Job {
+	var jobs []*Job
+	if opts.batch != "" {
+		var err error
+		if jobs, err = loadBatch(opts.batch, b.LogTemplate); err != nil {
+			warn("batch_load_failed", err)
+			os.Exit(1)
+		}
+	} else if opts.fromMoveLog {
+		jobs = b.moveLogJobs(opts.moves, opts.yes)
+	} else if b.template != nil {
+		jobs = []*Job{b.template.job(b.LogTemplate)}
+	} else {
+		jobs = []*Job{promptJob(b.LogTemplate)}
+	}
+	inheritNamespaces(jobs, b.LogTemplate, true)
+	jobs, err := b.expandPatterns(jobs)
+	if err == nil && opts.subpages {
+		jobs, err = b.expandSubpages(jobs)
+	}
+	if err != nil {
+		warn("pattern_failed", err)
+		os.Exit(1)
+	}
+	validateJobs(jobs)
+	if opts.discover {
+		b.discoverNamespaces(jobs, opts.yes)
+	}
+	return b.expandRedirects(jobs, opts.depth)
+}
+
+// editQueue resolves the documents to edit from the jobs' backlinks and
+// sel, and edits them one by one. It returns how many documents each job
+// edited, or nil when the operator declines to start.
+func (b *Bot) editQueue(jobs []*Job, sel docSelection, sandbox string, yes bool, diagAddr string) map[*Job]int {
+	docs, docJobs, counts := b.resolveDocuments(jobs, sel)
+	if b.checkpoint != nil {
+		docs = b.checkpoint.sync(docs)
+	}
+	if b.probeProtection {
+		docs = b.splitProtected(docs)
+	}
+	total := len(docs)
+	say("found_backlinks", total)
+	if !b.confirmBacklinks(counts, yes) {
+		say("aborted")
+		b.notify("abort", msg("aborted"))
+		return nil
+	}
+
+	if sandbox == "" && b.safeMode > 0 && !b.confirmSafeMode(docs, docJobs) {
+		say("aborted")
+		b.notify("abort", msg("aborted"))
+		return nil
+	}
+
+	defer b.startHeartbeat(jobs, total, sandbox)()
+
+	// Documents that fail are retried after the rest of the queue, up to
+	// maxRetries attempts each, then moved to the report's dead letters.
+	maxRetries := b.data.Section("").Key("maxRetries").MustInt(3)
+	edited := make(map[*Job]int)
+	queue := docs
+	// deferred holds the documents a person edited recently, in the order
+	// they become due again recentGuard later.
+	type deferredDoc struct {
+		doc string
+		due time.Time
+	}
+	var deferred []deferredDoc
+	// pending is every document still to be edited, for the checkpoint.
+	pending := func(later []string) []string {
+		out := append(slices.Clip(queue), later...)
+		for _, d := range deferred {
+			out = append(out, d.doc)
+		}
+		return out
+	}
+	// current is the namespace being edited; later holds the documents of
+	// other namespaces once discussAction stops the run after it.
+	current := ""
+	var later []string
+	var queueLen atomic.Int64
+	if diagAddr != "" {
+		b.serveDiagnostics(diagAddr, func() map[string]int {
+			return map[string]int{"total": total, "remaining": int(queueLen.Load())}
+		})
+	}
+	var ok bool
+	if queue, ok = b.runCanaries(queue, docJobs, sandbox, edited); !ok {
+		return edited
+	}
+	for n := 1; len(queue) > 0 || len(deferred) > 0; n++ {
+		if len(queue) == 0 {
+			wait := time.Until(deferred[0].due)
+			if !b.deadline.IsZero() {
+				wait = min(wait, time.Until(b.deadline))
+			}
+			time.Sleep(wait)
+		}
+		for len(deferred) > 0 && !time.Now().Before(deferred[0].due) {
+			queue = append(queue, deferred[0].doc)
+			deferred = deferred[1:]
+		}
+		if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+			b.stoppedEarly = true
+			b.stopReason = msg("time_up", len(queue)+len(deferred))
+			b.checkpoint.save(pending(nil), true)
+			say("time_up", len(queue)+len(deferred))
+			say("resume_hint", b.checkpoint.path)
+			break
+		}
+		if b.stopAfterNamespace.Load() && n > 1 && later == nil {
+			// Backlinks of several namespaces arrive interleaved, so the
+			// rest of the current namespace is picked out of the queue.
+			later = []string{}
+			queue = slices.DeleteFunc(queue, func(doc string) bool {
+				if namespaceOf(doc) != current {
+					later = append(later, doc)
+					return true
+				}
+				return false
+			})
+			deferred = slices.DeleteFunc(deferred, func(d deferredDoc) bool {
+				if namespaceOf(d.doc) != current {
+					later = append(later, d.doc)
+					return true
+				}
+				return false
+			})
+			if len(queue) == 0 && len(deferred) == 0 {
+				break
+			}
+		}
+		if len(queue) == 0 {
+			continue
+		}
+		doc := queue[0]
+		current = namespaceOf(doc)
+		queue = queue[1:]
+		queueLen.Store(int64(len(queue)))
+		changed, err := b.editDocument(doc, docJobs[doc], sandbox, fmt.Sprintf("%d/%d", min(n, total), total))
+		for _, job := range changed {
+			edited[job]++
+		}
+		requeued := false
+		if retryable(err) {
+			switch {
+			case b.report.attempts(doc) >= maxRetries:
+				b.report.markDead(doc)
+			case errors.Is(err, ErrRecentEdit):
+				// Retrying at once would only find the same recent edit.
+				deferred = append(deferred, deferredDoc{doc, time.Now().Add(b.recentGuard)})
+				requeued = true
+			default:
+				queue = append(queue, doc)
+				requeued = true
+			}
+		}
+		if !requeued {
+			b.heartbeat.processed(err == nil)
+		}
+		if b.checkpoint != nil {
+			if !requeued {
+				b.checkpoint.markDone(doc)
+			}
+			b.checkpoint.save(pending(later), len(queue) == 0 && len(deferred) == 0)
+		}
+	}
+	if len(later) > 0 {
+		b.stoppedEarly = true
+		b.stopReason = msg("namespace_done_stop", current)
+		say("namespace_done_stop", current)
+		if b.checkpoint != nil {
+			b.checkpoint.save(later, true)
+			say("resume_hint", b.checkpoint.path)
+		}
+	}
+	return edited
+}
+
+// finishRun prints and saves the run report and pushes its metrics, then
+// publishes what is left for people to finish and mails the operators.
+func (b *Bot) finishRun(jobs []*Job, sandbox, reportPath, csvPath string) {
+	b.report.finish(reportPath, csvPath)
+	b.pushMetrics()
+	if sandbox == "" {
+		b.publishLeftovers(jobs)
+	}
+	b.notify("finish", "")
+}
+
+// finalErrors are the outcomes another attempt at the document would only
+// repeat, so it is not queued for one.
+var finalErrors = []error{
+	ErrPermDenied,
+	errUnchanged,
+	ErrNeedsReview,
+	ErrGone,
+	ErrTooLarge,
+	ErrDocTimeout,
+	ErrOptedOut,
+	ErrSkipListed,
+	ErrFiltered,
+}
+
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, final := range finalErrors {
+		if errors.Is(err, final) {
+			return false
+		}
+	}
+	return true
+}
+
+// collectJobBacklinks gathers the backlinks of every job and groups the jobs
+// by document, so a document touched by several jobs is edited only once.
+func (b *Bot) collectJobBacklinks(jobs []*Job) ([]string, map[string][]*Job, map[string]int) {
+	var docs []string
+	docJobs := make(map[string][]*Job)
+	counts := make(map[string]int)
+	for _, job := range jobs {
+		list, jobCounts := b.collectBacklinks(job.Page())
+		for ns, n := range jobCounts {
+			counts[ns] += n
+		}
+		for _, doc := range list {
+			if _, ok := docJobs[doc]; !ok {
+				docs = append(docs, doc)
+			}
+			docJobs[doc] = append(docJobs[doc], job)
+		}
+	}
+	if len(jobs) > 1 {
+		say("jobs_touch_documents", len(jobs), len(docs))
+	}
+	return docs, docJobs, counts
+}
+
+// streamEdit edits backlinks namespace by namespace as each page of the
+// listing arrives, so the full backlink set is never held in memory. Every
+// job is applied to each document, so a page linking to several old titles
+// gets one edit; with more than one job the titles already visited are
+// remembered to skip them in later listings.
+func (b *Bot) streamEdit(jobs []*Job, sandbox string) map[*Job]int {
+	n := 0
+	edited := make(map[*Job]int)
+	var seen map[string]bool
+	if len(jobs) > 1 {
+		seen = make(map[string]bool)
+	}
+	defer b.startHeartbeat(jobs, 0, sandbox)()
+	last := ""
+jobs:
+	for _, job := range jobs {
+		for _, ns := range b.Namespaces {
+			if b.stopAfterNamespace.Load() && n > 0 {
+				b.stoppedEarly = true
+				b.stopReason = msg("namespace_done_stop", last)
+				say("namespace_done_stop", last)
+				break jobs
+			}
+			last = ns
+			err := streamBacklinks(context.Background(), b.Domain, b.Accounts.Current().Token, job.Page(), ns, func(docs []string) error {
+				for _, doc := range docs {
+					if seen != nil {
+						if seen[doc] {
+							continue
+						}
+						seen[doc] = true
+					}
+					n++
+					changed, err := b.editDocument(doc, jobs, sandbox, fmt.Sprint(n))
+					for _, job := range changed {
+						edited[job]++
+					}
+					b.heartbeat.processed(err == nil)
+				}
+				return nil
+			})
+			if err != nil {
+				say("backlink_fetch_failed", ns, err)
+			}
+		}
+	}
+	say("processed_backlinks", n)
+	return edited
+}
+
+// editDocument applies jobs to doc, reports the outcome and returns the
+// jobs whose rewrites it saved.
+func (b *Bot) editDocument(doc string, jobs []*Job, sandbox, pos string) ([]*Job, error) {
+	b.paused.wait()
+	b.emit("started", doc, "", nil)
+	ctx, span := startSpan(context.Background(), "document", "run.id", b.RunID, "document", doc)
+	if b.docTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.docTimeout)
+		defer cancel()
+	}
+	var saved docEdit
+	if err := b.skipList.check(doc); err != nil {
+		span.end(err)
+		say("skip_listed", doc, pos, err)
+		b.report.record(doc, statusSkipped, "", err)
+		b.emit("skipped", doc, "", err)
+		return nil, err
+	}
+	account, err := b.withAccountFor(ctx, doc, func(account Account) (err error) {
+		if err := b.checkRecentEdit(ctx, account, doc); err != nil {
+			return err
+		}
+		saved, err = processDocument(ctx, b.Domain, account, doc, jobs, docOptions{
+			sandbox:    sandbox,
+			limits:     b.limits,
+			revalidate: b.revalidate,
+			cache:      b.skipCache,
+			remaining:  b.remaining,
+		})
+		return err
+	})
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w (%v): %v", ErrDocTimeout, b.docTimeout, err)
+	}
+	if sandbox == "" {
+		b.skipList.note(doc, err)
+	}
+	span.set("account", account.Name)
+	span.end(err)
+	var filterErr *FilterError
+	switch {
+	case errors.As(err, &filterErr):
+		say("edit_filtered", doc, pos, filterErr.Message)
+		b.report.record(doc, statusFiltered, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrGone):
+		say("document_gone", doc, pos)
+		b.report.record(doc, statusGone, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrOptedOut):
+		say("opted_out", doc, pos)
+		b.report.record(doc, statusSkipped, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrDocTimeout):
+		say("document_timed_out", doc, pos, err)
+		b.report.record(doc, statusTimedOut, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrTooLarge):
+		say("too_large", doc, pos, err)
+		b.report.record(doc, statusTooLarge, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrNeedsReview):
+		say("needs_review", doc, pos, err)
+		b.report.record(doc, statusReview, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrRecentEdit):
+		say("recent_edit_deferred", doc, pos, err)
+		b.report.record(doc, statusSkipped, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, ErrPermDenied):
+		say("perm_denied", doc, pos)
+		b.report.record(doc, statusSkipped, account.Name, err)
+		b.emit("skipped", doc, account.Name, err)
+	case errors.Is(err, errUnchanged):
+		b.report.record(doc, statusUnchanged, account.Name, nil)
+		b.emit("skipped", doc, account.Name, err)
+	case err != nil:
+		say("process_failed", doc, pos, err)
+		b.report.record(doc, statusFailed, account.Name, err)
+		b.emit("failed", doc, account.Name, err)
+	default:
+		if summaryLinkCount {
+			say("updated_links", sandbox, doc, pos, account.Name, saved.links)
+		} else {
+			say("updated", sandbox, doc, pos, account.Name)
+		}
+		b.report.record(doc, statusEdited, account.Name, nil)
+		b.report.setEdit(doc, saved.links, saved.rev)
+		b.emit("edited", doc, account.Name, nil)
+		b.limiter.Wait()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return saved.jobs, nil
+}
+
+func loadBot() *Bot {
 	cfg, err := ini.Load("config.ini")
 	if err != nil {
 		cfg = ini.Empty()
@@ -41,209 +703,423 @@ func main() {
 		cfg.Section("").Key("token").SetValue(token)
 		cfg.SaveTo("config.ini")
 	}
-	domain := cfg.Section("").Key("domain").String()
-	token := cfg.Section("").Key("token").String()
 
 	dataCfg, err := ini.Load("data.ini")
 	if err != nil {
 		dataCfg = ini.Empty()
-		nsInput := prompt("Enter namespaces to search (comma-separated): ")
-		logTpl := prompt("Enter log template (use {old} and {new}): ")
-		watchDoc := prompt("Enter document to watch for open discussion: ")
+		nsInput := prompt(msg("prompt_namespaces"))
+		logTpl := prompt(msg("prompt_log_template"))
+		watchDoc := prompt(msg("prompt_watch_document"))
 		dataCfg.Section("").Key("namespaces").SetValue(nsInput)
 		dataCfg.Section("").Key("logTemplate").SetValue(logTpl)
 		dataCfg.Section("").Key("watchDocument").SetValue(watchDoc)
 		dataCfg.SaveTo("data.ini")
 	}
-	nsList := parseList(dataCfg.Section("").Key("namespaces").String())
-	logTemplate := dataCfg.Section("").Key("logTemplate").String()
-	watchDocument := dataCfg.Section("").Key("watchDocument").String()
-
-	go func() {
-		for {
-			open, err := checkDiscuss(domain, token, watchDocument)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error checking discuss: %v\n", err)
-				panic(err)
-			} else if open {
-				fmt.Printf("Discuss on '%s' is normal. Stopping bot.\n", watchDocument)
-				os.Exit(0)
-			}
-			time.Sleep(15 * time.Second)
-		}
-	}()
 
-	oldTitle := prompt("Enter old title: ")
-	newTitle := prompt("Enter new title: ")
-	keepText := strings.ToLower(prompt("Keep display text for bare links? (y/n): ")) == "y"
+	runID := newRunID()
+	say("run_id", runID)
+	bot := &Bot{
+		Domain:        cfg.Section("").Key("domain").String(),
+		Accounts:      loadAccounts(cfg),
+		Namespaces:    parseList(dataCfg.Section("").Key("namespaces").String()),
+		WatchDocument: dataCfg.Section("").Key("watchDocument").String(),
+		cfg:           cfg,
+		data:          dataCfg,
+		logTemplate:   dataCfg.Section("").Key("logTemplate").String(),
+	}
+	bot.useRunID(runID)
+	sec := dataCfg.Section("")
+	bot.limiter = newLimiter(sec.Key("editsPerMinute").MustFloat64(60), sec.Key("editBurst").MustInt(1), sec.Key("editJitter").MustDuration(0))
+	bot.workers = newWorkerGate(backlinkParallelism)
+	tuner = loadAutoTuner(sec, bot.limiter, bot.workers)
+	displayCollapse = sec.Key("collapseDisplay").In("space", []string{"exact", "space", "fold"})
+	linkMatcherKind = sec.Key("matcher").In("regex", linkMatcherKinds)
+	maxPageBytes = sec.Key("maxPageBytes").MustInt64(maxPageBytes)
+	bot.docTimeout = sec.Key("documentTimeout").MustDuration(5 * time.Minute)
+	optOutMarker = sec.Key("optOutMarker").String()
+	summaryLinkCount = sec.Key("summaryLinkCount").MustBool(false)
+	bot.safeMode = sec.Key("safeMode").MustInt(0)
+	bot.limits = changeLimits{MaxLinks: sec.Key("maxLinksPerPage").MustInt(0), MaxPercent: sec.Key("maxChangePercent").MustFloat64(0)}
+	initTracing(cfg.Section("").Key("otlpEndpoint").String())
+	requestIDHeader = cfg.Section("").Key("requestIDHeader").String()
+	compressTransfer = cfg.Section("").Key("compress").MustBool(true)
+	loadTimeouts(cfg)
+	loadHTTPClient(cfg)
+	loadRetryPolicy(cfg)
+	if err := loadArtifactKey(cfg); err != nil {
+		warn("artifact_key_failed", err)
+		os.Exit(1)
+	}
+	sealHTTPDebug()
+	engine, err := loadEngine(cfg)
+	if err != nil {
+		warn("engine_unknown", err)
+		os.Exit(2)
+	}
+	api = engine
+	if backend == nil {
+		api = detectSchema(engine, bot.Domain, bot.Accounts.Current().Token)
+	}
+	say("api_detected", api.Name, api.Version)
+	if bot.Accounts.Anonymous() {
+		say("anonymous_mode")
+	}
+	loadEventSinks(cfg)
+	bot.notifyRules = loadNotifyRules(cfg)
+	return bot
+}
 
-	logEntry := strings.ReplaceAll(logTemplate, "{old}", oldTitle)
-	logEntry = strings.ReplaceAll(logEntry, "{new}", newTitle)
+const backlinkParallelism = 4
 
+// collectBacklinks returns the documents linking to title in the configured
+// namespaces along with the number found in each namespace.
+func (b *Bot) collectBacklinks(title string) ([]string, map[string]int) {
+	if backend != nil {
+		return b.collectBackendBacklinks(title)
+	}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
 	docsMap := make(map[string]struct{})
-	for _, ns := range nsList {
-		list, err := getBacklinksByNamespace(domain, token, oldTitle, ns)
-		if err != nil {
-			fmt.Printf("Error fetching backlinks in namespace '%s': %v\n", ns, err)
-			continue
-		}
-		for _, doc := range list {
-			docsMap[doc] = struct{}{}
-		}
+	counts := make(map[string]int)
+	for _, ns := range b.Namespaces {
+		wg.Add(1)
+		go func(ns string) {
+			defer wg.Done()
+			b.workers.acquire()
+			defer b.workers.release()
+			list, err := getBacklinksByNamespace(context.Background(), b.Domain, b.Accounts.Current().Token, title, ns)
+			if err != nil {
+				say("backlink_fetch_failed", ns, err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			counts[ns] = len(list)
+			for _, doc := range list {
+				docsMap[doc] = struct{}{}
+			}
+		}(ns)
 	}
+	wg.Wait()
 	var docs []string
 	for doc := range docsMap {
 		docs = append(docs, doc)
 	}
-	total := len(docs)
-	fmt.Printf("Found %d backlinks to process.\n", total)
+	return docs, counts
+}
 
-	re := regexp.MustCompile(`\[\[[\t\f ]*` + regexp.QuoteMeta(oldTitle) + `[\t\f ]*(?:\|([^\[\]]+))?\]\]`)
-	for idx, doc := range docs {
-		text, editToken, err := getPageContent(domain, token, doc)
-		if err != nil {
-			if err == ErrPermDenied {
-				fmt.Printf("권한 문제로 %s 문서를 편집할 수 없습니다. (%d/%d).\n", doc, idx+1, total)
-			} else {
-				fmt.Printf("Failed to fetch %s (%d/%d): %v\n", doc, idx+1, total, err)
+// collectBackendBacklinks is collectBacklinks on a wikiBackend, which lists
+// every backlink in one call. Documents outside the configured namespaces
+// (a DokuWiki ns:page, say) are reported instead of dropped silently.
+func (b *Bot) collectBackendBacklinks(title string) ([]string, map[string]int) {
+	counts := make(map[string]int)
+	list, err := backend.backlinks(context.Background(), b.Domain, b.Accounts.Current().Token, title)
+	if err != nil {
+		say("backlink_fetch_failed", strings.Join(b.Namespaces, ", "), err)
+		return nil, counts
+	}
+	var docs, outside []string
+	skipped := make(map[string]int)
+	for _, doc := range list {
+		ns := namespaceOf(doc)
+		if !slices.Contains(b.Namespaces, ns) {
+			if skipped[ns] == 0 {
+				outside = append(outside, ns)
 			}
+			skipped[ns]++
 			continue
 		}
-		updated := re.ReplaceAllStringFunc(text, func(m string) string {
-			parts := re.FindStringSubmatch(m)
-			if parts[1] == newTitle {
-				parts[1] = ""
-			}
-			if parts[1] != "" {
-				return fmt.Sprintf("[[%s|%s]]", newTitle, parts[1])
-			}
-			if keepText {
-				return fmt.Sprintf("[[%s|%s]]", newTitle, oldTitle)
-			}
-			return fmt.Sprintf("[[%s]]", newTitle)
-		})
-		if updated != text {
-			err = updatePageContent(domain, token, doc, updated, editToken, logEntry)
-			if err != nil {
-				fmt.Printf("Failed to update %s (%d/%d): %v\n", doc, idx+1, total, err)
-			} else {
-				fmt.Printf("Updated %s (%d/%d)\n", doc, idx+1, total)
-			}
-			time.Sleep(time.Second)
+		counts[ns]++
+		docs = append(docs, doc)
+	}
+	if len(outside) > 0 {
+		sort.Strings(outside)
+		warn("backend_ns_skipped", len(list)-len(docs), title, strings.Join(outside, ", "))
+	}
+	return docs, counts
+}
+
+// confirmBacklinks prints the per-namespace backlink counts and asks the
+// operator to go ahead, so an unexpectedly huge run is not started blindly.
+func (b *Bot) confirmBacklinks(counts map[string]int, yes bool) bool {
+	shown := make(map[string]bool)
+	for _, ns := range b.Namespaces {
+		say("namespace_count", ns, counts[ns])
+		shown[ns] = true
+	}
+	var others []string
+	for ns := range counts {
+		if !shown[ns] {
+			others = append(others, ns)
 		}
 	}
+	sort.Strings(others)
+	for _, ns := range others {
+		say("namespace_count", ns, counts[ns])
+	}
+	if yes {
+		return true
+	}
+	return strings.ToLower(prompt(msg("prompt_proceed"))) == "y"
 }
 
-func promptConfig() (string, string) {
-	d := prompt("Enter domain (e.g. theseed.io): ")
-	t := prompt("Enter API token: ")
-	return d, t
+// withAccountFor runs fn for doc with the account meant for it: the
+// privileged account for its namespaces, otherwise the pool's accounts,
+// falling back to the privileged account when they may not edit doc.
+func (b *Bot) withAccountFor(ctx context.Context, doc string, fn func(Account) error) (Account, error) {
+	p := b.Accounts
+	if p.privileged != nil && slices.Contains(p.privilegedNS, namespaceOf(doc)) {
+		return b.withPrivileged(ctx, fn)
+	}
+	account, err := b.withAccount(ctx, fn)
+	if p.privileged != nil && p.forProtected && errors.Is(err, ErrPermDenied) {
+		say("privileged_retry", doc, p.privileged.Name)
+		return b.withPrivileged(ctx, fn)
+	}
+	return account, err
 }
 
-func prompt(msg string) string {
-	fmt.Print(msg)
-	reader := bufio.NewReader(os.Stdin)
-	line, _ := reader.ReadString('\n')
-	return strings.TrimSpace(line)
+// withAccount runs fn with the current account, switching to the next one
+// and retrying whenever the account is rate limited or blocked. Waiting
+// for an account ends early with ctx's error when ctx is done. Once every
+// account is blocked the run is aborted.
+func (b *Bot) withAccount(ctx context.Context, fn func(Account) error) (Account, error) {
+	account, err := retryAccounts(ctx, b.Accounts.Current(), b.Accounts.Next, fn)
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrBlocked) {
+		say("no_accounts_left")
+		b.notify("abort", msg("no_accounts_left"))
+		exitRun(1)
+	}
+	return account, err
 }
 
-func parseList(s string) []string {
-	parts := strings.Split(s, ",")
-	var list []string
-	for _, p := range parts {
-		if t := strings.TrimSpace(p); t != "" {
-			list = append(list, t)
+// withPrivileged runs fn with the privileged account, waiting out its rate
+// limits as withAccount does. There is no account to fall back on, so a
+// block is returned to the caller.
+func (b *Bot) withPrivileged(ctx context.Context, fn func(Account) error) (Account, error) {
+	privileged := *b.Accounts.privileged
+	return retryAccounts(ctx, privileged, func(block bool) (Account, bool) { return privileged, !block }, fn)
+}
+
+// retryAccounts runs fn with account and, while it is rate limited or
+// blocked, again with the account next gives, waiting a minute before
+// reusing the same one. It returns the last error once next reports that
+// no account is left, or ctx's error if ctx is done while waiting.
+func retryAccounts(ctx context.Context, account Account, next func(block bool) (Account, bool), fn func(Account) error) (Account, error) {
+	for {
+		err := fn(account)
+		if !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrBlocked) {
+			return account, err
+		}
+		following, ok := next(errors.Is(err, ErrBlocked))
+		if !ok {
+			return account, err
+		}
+		if following == account {
+			say("account_wait", account.Name, err)
+			if err := sleepCtx(ctx, time.Minute); err != nil {
+				return account, err
+			}
+		} else {
+			say("account_switch", account.Name, err)
 		}
+		account = following
 	}
-	return list
 }
 
-func getBacklinksByNamespace(domain, token, title, namespace string) ([]string, error) {
-	urlStr := fmt.Sprintf("https://%s/api/backlink/%s?namespace=%s", domain,
-		url.PathEscape(title), url.QueryEscape(namespace))
-	req, _ := http.NewRequest("GET", urlStr, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+var (
+	errUnchanged   = errors.New("document unchanged")
+	ErrPageChanged = errors.New("page changed since it was fetched")
+	ErrOverwritten = errors.New("the bot's edit was overwritten")
+	ErrDocTimeout  = errors.New("document took too long to process")
+)
+
+// rebase re-fetches page before saving. If someone edited it after it was
+// read, the rewrite is three-way merged onto their revision; overlapping
+// changes fail with ErrPageChanged so the bot never saves over a human edit.
+func rebase(ctx context.Context, domain, token string, page *Page, rewritten string) (*Page, string, error) {
+	latest, err := getPageContent(ctx, domain, token, page.Title)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var res BacklinkResponse
-	json.Unmarshal(body, &res)
-	var docs []string
-	for _, b := range res.Backlinks {
-		if b.Flags == "link" {
-			docs = append(docs, b.Document)
-		}
+	if latest.Hash == page.Hash {
+		return page, rewritten, nil
 	}
-	return docs, nil
+	merged, ok := merge3(page.Text, rewritten, latest.Text)
+	if !ok {
+		return nil, "", fmt.Errorf("%w (fetched at %s) and the edits conflict", ErrPageChanged, page.Fetched.Format(time.TimeOnly))
+	}
+	say("merged_concurrent_edit", page.Title)
+	return latest, merged, nil
 }
 
-func checkDiscuss(domain, token, title string) (bool, error) {
-	urlStr := fmt.Sprintf("https://%s/api/discuss/%s", domain, url.PathEscape(title))
-	req, _ := http.NewRequest("GET", urlStr, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+// checkRecentEdit fails with ErrRecentEdit when someone other than the
+// bot's accounts edited doc within recentGuard, so the bot does not edit
+// war with a person who is still working on the page.
+func (b *Bot) checkRecentEdit(ctx context.Context, account Account, doc string) error {
+	if b.recentGuard <= 0 {
+		return nil
+	}
+	history, err := getHistory(ctx, b.Domain, account.Token, doc)
 	if err != nil {
-		return false, err
+		return err
 	}
-	defer resp.Body.Close()
-	var discussList []Discuss
-	body, _ := io.ReadAll(resp.Body)
-	json.Unmarshal(body, &discussList)
-
-	for _, d := range discussList {
-		if d.Status == "normal" {
-			return true, nil
+	for _, rev := range history {
+		at := time.Unix(rev.Date, 0)
+		if time.Since(at) > b.recentGuard {
+			break
+		}
+		if !b.Accounts.IsBot(rev.Author) {
+			return fmt.Errorf("%w (%s at %s)", ErrRecentEdit, rev.Author, at.Format(time.TimeOnly))
 		}
 	}
+	return nil
+}
+
+// docEdit is what processDocument saved: the number of links it changed,
+// the new revision and the jobs whose rewrites changed the text.
+type docEdit struct {
+	links, rev int
+	jobs       []*Job
+}
 
-	return false, nil
+// docOptions are the settings processDocument edits a document with.
+type docOptions struct {
+	// sandbox, when set, is the prefix of the page saved instead of the
+	// document itself.
+	sandbox    string
+	limits     changeLimits
+	revalidate bool
+	cache      *SkipCache
+	// remaining, when set, notes the old titles still mentioned in the
+	// text the document is left with.
+	remaining *RemainingLog
 }
 
-var ErrPermDenied = errors.New("API access denied due to insufficient permissions")
+// processDocument rewrites and saves doc.
+func processDocument(ctx context.Context, domain string, account Account, doc string, jobs []*Job, opts docOptions) (docEdit, error) {
+	key := opts.cache.key(jobs, doc)
+	page, err := getPageContent(ctx, domain, account.Token, doc)
+	if err != nil {
+		return docEdit{}, err
+	}
+	if err := optedOut(page.Text); err != nil {
+		return docEdit{}, err
+	}
+	if opts.cache.sameHash(key, page.Hash) {
+		return docEdit{}, errCached
+	}
+	text, summary, links, changed := rewriteAll(jobs, doc, page.Text)
+	if err := ctx.Err(); err != nil {
+		return docEdit{}, err
+	}
+	if text == page.Text {
+		opts.cache.store(key, page.Hash)
+		opts.remaining.note(doc, jobs, text)
+		return docEdit{}, errUnchanged
+	}
+	opts.cache.forget(key)
+	if err := opts.limits.check(page.Text, text, links); err != nil {
+		return docEdit{links: links}, err
+	}
+	if err := lintRewrite(page.Text, text); err != nil {
+		return docEdit{links: links}, err
+	}
+	if opts.sandbox != "" {
+		box, err := getPageContent(ctx, domain, account.Token, opts.sandbox+doc)
+		if errors.Is(err, ErrGone) {
+			box, err = &Page{Title: opts.sandbox + doc}, nil
+		}
+		if err != nil {
+			return docEdit{}, err
+		}
+		rev, err := updatePageContent(ctx, domain, account.Token, box.Title, text, box.Token, summary)
+		return docEdit{links, rev, changed}, err
+	}
+	page, text, err = rebase(ctx, domain, account.Token, page, text)
+	if err != nil {
+		return docEdit{}, err
+	}
+	rev, err := updatePageContent(ctx, domain, account.Token, doc, text, page.Token, summary)
+	if err == nil && opts.revalidate {
+		err = verifySave(ctx, domain, account.Token, doc, rev, text, summary)
+	}
+	if err == nil {
+		opts.remaining.note(doc, jobs, text)
+	}
+	return docEdit{links, rev, changed}, err
+}
 
-func getPageContent(domain, token, title string) (string, string, error) {
-	urlStr := fmt.Sprintf("https://%s/api/edit/%s", domain, url.PathEscape(title))
-	req, _ := http.NewRequest("GET", urlStr, nil)
-	req.Header.Set("Authorization", "Bearer "+token)
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+// verifySave checks that doc's latest revision is the save rev, with the
+// saved text and summary. A later edit racing the bot's fails it with
+// ErrOverwritten, so the document is processed again.
+func verifySave(ctx context.Context, domain, token, doc string, rev int, text, summary string) error {
+	history, err := getHistory(ctx, domain, token, doc)
 	if err != nil {
-		return "", "", err
-	}
-	defer resp.Body.Close()
-	body, _ := io.ReadAll(resp.Body)
-	var r struct {
-		Text   string `json:"text"`
-		Token  string `json:"token"`
-		Status string `json:"status"`
-	}
-	json.Unmarshal(body, &r)
-	if strings.Contains(r.Status, "때문에 편집 권한이 부족합니다.") {
-		return "", "", ErrPermDenied
-	}
-	return r.Text, r.Token, nil
-}
-
-func updatePageContent(domain, token, title, content, editToken, logMsg string) error {
-	payload := map[string]string{"text": content, "log": logMsg, "token": editToken}
-	data, _ := json.Marshal(payload)
-	urlStr := fmt.Sprintf("https://%s/api/edit/%s", domain, url.PathEscape(title))
-	req, _ := http.NewRequest("POST", urlStr, strings.NewReader(string(data)))
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+		return err
+	}
+	if len(history) > 0 && (rev != 0 && history[0].Rev != rev || history[0].Log != summary) {
+		return fmt.Errorf("%w by r%d (%s)", ErrOverwritten, history[0].Rev, history[0].Author)
+	}
+	page, err := getPageContent(ctx, domain, token, doc)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("status %s", resp.Status)
+	if hashText(page.Text) != hashText(text) {
+		return fmt.Errorf("%w: the text differs from r%d", ErrOverwritten, rev)
 	}
 	return nil
 }
+
+// useRunID makes id the run's ID: the report and the {run} of the edit
+// summaries carry it.
+func (b *Bot) useRunID(id string) {
+	b.RunID = id
+	b.LogTemplate = strings.ReplaceAll(b.logTemplate, "{run}", id)
+	batchLogTemplate = strings.ReplaceAll(b.data.Section("").Key("batchLogTemplate").String(), "{run}", id)
+	b.report = newReport(id)
+}
+
+// newRunID returns an identifier unique to this run, used to find the run's
+// edits again in summaries, logs and reports.
+func newRunID() string {
+	buf := make([]byte, 3)
+	rand.Read(buf)
+	return time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(buf)
+}
+
+func promptJob(logTemplate string) *Job {
+	oldTitle := prompt(msg("prompt_old_title"))
+	newTitle := prompt(msg("prompt_new_title"))
+	keepText := strings.ToLower(prompt(msg("prompt_keep_text"))) == "y"
+	return newJob(oldTitle, newTitle, keepText, logTemplate)
+}
+
+func promptConfig() (string, string) {
+	d := prompt(msg("prompt_domain"))
+	t := prompt(msg("prompt_token"))
+	return d, t
+}
+
+// stdin is shared by every read from standard input; a reader per call
+// would lose whatever it had buffered beyond its own line.
+var stdin = bufio.NewReader(os.Stdin)
+
+func prompt(text string) string {
+	fmt.Fprint(humanOut, text)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func parseList(s string) []string {
+	parts := strings.Split(s, ",")
+	var list []string
+	for _, p := range parts {
+		if t := strings.TrimSpace(p); t != "" {
+			list = append(list, t)
+		}
+	}
+	return list
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+
+	"micro-rearalice/internal/fakeseed"
+)
+
+// newTestBot returns a bot talking to srv, with edit pacing turned off.
+func newTestBot(t *testing.T, srv *fakeseed.Server) *Bot {
+	t.Helper()
+	setForTest(t, &httpClient, newHTTPClient(defaultConnsPerHost, srv.Client().Transport.(*http.Transport).TLSClientConfig))
+	setForTest[io.Writer](t, &humanOut, testWriter{t})
+
+	cfg := ini.Empty()
+	cfg.Section("").Key("token").SetValue("test")
+	cfg.Section("").Key("user").SetValue("bot")
+	return &Bot{
+		RunID:       "test-run",
+		Domain:      srv.Domain(),
+		Accounts:    loadAccounts(cfg),
+		Namespaces:  []string{"문서", "틀"},
+		LogTemplate: "{old} → {new}",
+		cfg:         cfg,
+		data:        ini.Empty(),
+		limiter:     newLimiter(1e9, 1, 0),
+		workers:     newWorkerGate(backlinkParallelism),
+		report:      newReport("test-run"),
+	}
+}
+
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// setForTest sets a package variable for the rest of the test.
+func setForTest[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// orchardPages is the wiki most tests share: one document linking to 사과.
+var orchardPages = map[string]string{"과수원": "[[사과]]"}
+
+// orchard is a fake wiki, a bot talking to it and the job moving 사과 to
+// 사과(과일).
+type orchard struct {
+	srv *fakeseed.Server
+	bot *Bot
+	job *Job
+}
+
+// newOrchard serves pages, or orchardPages when pages is nil.
+func newOrchard(t *testing.T, pages map[string]string) orchard {
+	t.Helper()
+	if pages == nil {
+		pages = orchardPages
+	}
+	srv := fakeseed.New(pages)
+	t.Cleanup(srv.Close)
+	bot := newTestBot(t, srv)
+	return orchard{srv: srv, bot: bot, job: newJob("사과", "사과(과일)", false, bot.LogTemplate)}
+}
+
+// run edits the backlinks of the orchard's job.
+func (o orchard) run() map[*Job]int {
+	return o.bot.editQueue([]*Job{o.job}, docSelection{}, "", true, "")
+}
+
+// checkCounts fails the test when a report count differs from want.
+func (o orchard) checkCounts(t *testing.T, want map[string]int) {
+	t.Helper()
+	counts := o.bot.report.counts()
+	for status, n := range want {
+		if counts[status] != n {
+			t.Errorf("report counts = %v, want %v", counts, want)
+			return
+		}
+	}
+}
+
+// checkPages fails the test when a page's text differs from want.
+func (o orchard) checkPages(t *testing.T, want map[string]string) {
+	t.Helper()
+	for title, text := range want {
+		if got := o.srv.Page(title); got != text {
+			t.Errorf("%s = %q, want %q", title, got, text)
+		}
+	}
+}
+
+func TestEditQueue(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		pages  map[string]string
+		setup  func(t *testing.T, o orchard)
+		counts map[string]int
+		want   map[string]string
+		check  func(t *testing.T, o orchard, edited map[*Job]int)
+	}{{
+		name: "end to end",
+		pages: map[string]string{
+			"사과":    "사과는 과일이다.",
+			"과수원":   "[[사과]]를 기른다. [[사과|능금]]도.",
+			"틀:과일":  "[[사과]] · [[배]]",
+			"배":     "[[배나무]]",
+			"보호 문서": "[[사과]]",
+		},
+		setup: func(t *testing.T, o orchard) {
+			o.srv.PageSize = 1
+			o.srv.Protect("보호 문서")
+		},
+		counts: map[string]int{statusEdited: 2, statusSkipped: 1},
+		want: map[string]string{
+			"과수원":  "[[사과(과일)]]를 기른다. [[사과(과일)|능금]]도.",
+			"틀:과일": "[[사과(과일)]] · [[배]]",
+		},
+		check: func(t *testing.T, o orchard, edited map[*Job]int) {
+			if edited[o.job] != 2 {
+				t.Errorf("edited %d documents, want 2", edited[o.job])
+			}
+			for _, e := range o.srv.Edits() {
+				if e.Log != "사과 → 사과(과일)" {
+					t.Errorf("summary of %s = %q", e.Title, e.Log)
+				}
+			}
+		},
+	}, {
+		name:  "protected documents are split out",
+		pages: map[string]string{"과수원": "[[사과]]", "보호 문서": "[[사과]]"},
+		setup: func(t *testing.T, o orchard) {
+			o.srv.Protect("보호 문서")
+			o.bot.probeProtection = true
+		},
+		counts: map[string]int{statusEdited: 1, statusProtected: 1, statusSkipped: 0},
+	}, {
+		name:   "revalidate",
+		setup:  func(t *testing.T, o orchard) { o.bot.revalidate = true },
+		counts: map[string]int{statusEdited: 1},
+		check: func(t *testing.T, o orchard, edited map[*Job]int) {
+			if n := len(o.srv.Edits()); n != 1 {
+				t.Errorf("%d saves, want 1", n)
+			}
+		},
+	}, {
+		name: "too large",
+		pages: map[string]string{
+			"과수원": "[[사과]]",
+			"목록":  "[[사과]]\n" + strings.Repeat(" * 항목\n", 500),
+		},
+		setup:  func(t *testing.T, o orchard) { setForTest(t, &maxPageBytes, 1<<10) },
+		counts: map[string]int{statusEdited: 1, statusTooLarge: 1},
+	}, {
+		name:  "link count in summary",
+		pages: map[string]string{"과수원": "[[사과]]를 기른다. [[사과|능금]]도."},
+		setup: func(t *testing.T, o orchard) { setForTest(t, &summaryLinkCount, true) },
+		check: func(t *testing.T, o orchard, edited map[*Job]int) {
+			edits := o.srv.Edits()
+			if len(edits) != 1 {
+				t.Fatalf("got %d edits, want 1", len(edits))
+			}
+			if want := msg("summary_links", 2); !strings.HasSuffix(edits[0].Log, want) {
+				t.Errorf("summary = %q, want it to end in %q", edits[0].Log, want)
+			}
+		},
+	}, {
+		name:  "stop after namespace",
+		pages: map[string]string{"과수원": "[[사과]]", "과일가게": "[[사과]]", "틀:과일": "[[사과]]"},
+		setup: func(t *testing.T, o orchard) { o.bot.stopAfterNamespace.Store(true) },
+		check: func(t *testing.T, o orchard, edited map[*Job]int) {
+			// Whichever namespace comes first is finished, and only that one.
+			edits := o.srv.Edits()
+			first := namespaceOf(edits[0].Title)
+			for _, e := range edits {
+				if namespaceOf(e.Title) != first {
+					t.Errorf("edited %s after finishing %s", e.Title, first)
+				}
+			}
+			want := map[string]int{"문서": 2, "틀": 1}[first]
+			if len(edits) != want || !o.bot.stoppedEarly {
+				t.Errorf("%d edits, stopped early %v; want %d, then a stop", len(edits), o.bot.stoppedEarly, want)
+			}
+		},
+	}, {
+		name:  "safe mode declined",
+		pages: map[string]string{"과수원": "[[사과]]", "농장": "[[사과]]"},
+		setup: func(t *testing.T, o orchard) {
+			o.bot.safeMode = 1
+			setForTest(t, &stdin, bufio.NewReader(strings.NewReader("n\n")))
+		},
+		check: func(t *testing.T, o orchard, edited map[*Job]int) {
+			if edited != nil || len(o.srv.Edits()) != 0 {
+				t.Errorf("declined safe mode still edited %d documents", len(o.srv.Edits()))
+			}
+		},
+	}, {
+		name:  "safe mode confirmed",
+		pages: map[string]string{"과수원": "[[사과]]", "농장": "[[사과]]"},
+		setup: func(t *testing.T, o orchard) {
+			o.bot.safeMode = 1
+			setForTest(t, &stdin, bufio.NewReader(strings.NewReader("y\n")))
+		},
+		counts: map[string]int{statusEdited: 2},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOrchard(t, tc.pages)
+			if tc.setup != nil {
+				tc.setup(t, o)
+			}
+			edited := o.run()
+			o.checkCounts(t, tc.counts)
+			o.checkPages(t, tc.want)
+			if tc.check != nil {
+				tc.check(t, o, edited)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("status 502"), true},
+		{ErrRateLimited, true},
+		{ErrPermDenied, false},
+		{fmt.Errorf("saving: %w", ErrPermDenied), false},
+		{fmt.Errorf("%w: 과수원", ErrSkipListed), false},
+		{&FilterError{Message: "광고"}, false},
+		{fmt.Errorf("saving: %w", &FilterError{Message: "광고"}), false},
+	} {
+		if got := retryable(tc.err); got != tc.want {
+			t.Errorf("retryable(%v) = %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestStreamEditCountsEveryJob(t *testing.T) {
+	o := newOrchard(t, map[string]string{"과수원": "[[사과]] [[배]]", "농장": "[[배]]"})
+	pear := newJob("배", "배(과일)", false, o.bot.LogTemplate)
+
+	// 과수원 is reached through 사과 but is rewritten for 배 as well.
+	edited := o.bot.streamEdit([]*Job{o.job, pear}, "")
+	if edited[o.job] != 1 || edited[pear] != 2 {
+		t.Errorf("edited %d for 사과 and %d for 배, want 1 and 2", edited[o.job], edited[pear])
+	}
+	o.checkPages(t, map[string]string{"과수원": "[[사과(과일)]] [[배(과일)]]", "농장": "[[배(과일)]]"})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// A Manifest records everything a run was started with, so the run can be
+// audited or repeated exactly later. It is written before the first edit.
+type Manifest struct {
+	RunID      string            `json:"run_id"`
+	Created    time.Time         `json:"created"`
+	Version    string            `json:"version"`
+	GoVersion  string            `json:"go_version"`
+	Domain     string            `json:"domain"`
+	Flags      map[string]string `json:"flags"`
+	Namespaces []string          `json:"namespaces"`
+	Jobs       []CheckpointJob   `json:"jobs"`
+	Documents  docSelection      `json:"documents"`
+	// Data holds every data.ini setting by section; DataHash is the
+	// SHA-256 of the file as read, identifying the rule set used.
+	Data     map[string]map[string]string `json:"data"`
+	DataHash string                       `json:"data_hash,omitempty"`
+	// Config holds every config.ini setting by section, with secrets
+	// redacted (see redactSetting). API and HTTP are the settings in
+	// effect, defaults and the detected engine included.
+	Config map[string]map[string]string `json:"config"`
+	API    *apiSchema                   `json:"api"`
+	HTTP   manifestHTTP                 `json:"http"`
+}
+
+// manifestHTTP is the effective connection, retry and timeout setup.
+type manifestHTTP struct {
+	MaxConnsPerHost int               `json:"max_conns_per_host"`
+	RetryAttempts   int               `json:"retry_attempts"`
+	RetryBase       string            `json:"retry_base"`
+	RetryCap        string            `json:"retry_cap"`
+	RetryStatuses   []int             `json:"retry_statuses"`
+	Timeouts        map[string]string `json:"timeouts"`
+}
+
+// redacted replaces a secret setting's value in the manifest.
+const redacted = "[redacted]"
+
+// redactSetting returns the value of config.ini's key as the manifest
+// records it: tokens, passwords, keys and database DSNs are replaced, and
+// URLs, which may carry a webhook secret, are cut to their scheme and host.
+func redactSetting(key, value string) string {
+	name := strings.ToLower(key)
+	for _, secret := range []string{"token", "password", "secret", "dsn", "artifactkey"} {
+		if strings.Contains(name, secret) && !strings.HasSuffix(name, "command") && !strings.HasSuffix(name, "file") {
+			return redacted
+		}
+	}
+	if strings.HasSuffix(name, "url") {
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			return u.Scheme + "://" + u.Host + "/" + redacted
+		}
+		return redacted
+	}
+	return value
+}
+
+// writeManifest saves the run's manifest to path. fs supplies the flags
+// that were set on the command line.
+func (b *Bot) writeManifest(path string, fs *flag.FlagSet, jobs []*Job, sel docSelection) {
+	m := &Manifest{
+		RunID:      b.RunID,
+		Created:    time.Now(),
+		Version:    version,
+		GoVersion:  runtime.Version(),
+		Domain:     b.Domain,
+		Flags:      make(map[string]string),
+		Namespaces: b.Namespaces,
+		Jobs:       checkpointJobs(jobs),
+		Documents:  sel,
+		Data:       make(map[string]map[string]string),
+		Config:     make(map[string]map[string]string),
+		API:        api,
+		HTTP: manifestHTTP{
+			MaxConnsPerHost: httpClient.perHost,
+			RetryAttempts:   httpRetry.Attempts,
+			RetryBase:       httpRetry.Base.String(),
+			RetryCap:        httpRetry.Cap.String(),
+			RetryStatuses:   httpRetry.Statuses,
+			Timeouts:        make(map[string]string),
+		},
+	}
+	fs.Visit(func(f *flag.Flag) {
+		m.Flags[f.Name] = f.Value.String()
+	})
+	for _, sec := range b.data.Sections() {
+		if len(sec.Keys()) > 0 {
+			m.Data[sec.Name()] = sec.KeysHash()
+		}
+	}
+	for _, sec := range b.cfg.Sections() {
+		if len(sec.Keys()) == 0 {
+			continue
+		}
+		m.Config[sec.Name()] = make(map[string]string)
+		for _, key := range sec.Keys() {
+			m.Config[sec.Name()][key.Name()] = redactSetting(key.Name(), key.String())
+		}
+	}
+	for op, d := range opTimeouts {
+		m.HTTP.Timeouts[op] = d.String()
+	}
+	if raw, err := os.ReadFile("data.ini"); err == nil {
+		sum := sha256.Sum256(raw)
+		m.DataHash = hex.EncodeToString(sum[:])
+	}
+
+	data, _ := json.MarshalIndent(m, "", "  ")
+	if err := writeArtifact(path, data, 0o644); err != nil {
+		warn("manifest_failed", err)
+		return
+	}
+	say("manifest_written", path)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestManifestRedactsConfig(t *testing.T) {
+	o := newOrchard(t, nil)
+	sec := o.bot.cfg.Section("")
+	sec.Key("engine").SetValue("seed")
+	sec.Key("smtpPassword").SetValue("hunter2")
+	sec.Key("artifactKeyFile").SetValue("/etc/rearalice/key")
+	o.bot.cfg.Section("sink.hook").Key("url").SetValue("https://hooks.example.com/services/T0/B0/s3cr3t")
+	path := t.TempDir() + "/manifest.json"
+
+	o.bot.writeManifest(path, flag.NewFlagSet("edit", flag.ContinueOnError), []*Job{o.job}, docSelection{})
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "s3cr3t", `"test"`} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("manifest holds the secret %s:\n%s", secret, raw)
+		}
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"engine":          "seed",
+		"token":           redacted,
+		"smtpPassword":    redacted,
+		"artifactKeyFile": "/etc/rearalice/key",
+	} {
+		if got := m.Config[ini.DefaultSection][key]; got != want {
+			t.Errorf("config %s = %q, want %q", key, got, want)
+		}
+	}
+	if got, want := m.Config["sink.hook"]["url"], "https://hooks.example.com/"+redacted; got != want {
+		t.Errorf("sink url = %q, want %q", got, want)
+	}
+	if m.HTTP.MaxConnsPerHost != defaultConnsPerHost || m.HTTP.Timeouts["save"] == "" {
+		t.Errorf("http = %+v, want the effective limits", m.HTTP)
+	}
+}
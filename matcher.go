@@ -0,0 +1,354 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A LinkMatch is one reference to a job's old title found in a document:
+// a [[link]], or a template's [include(...)] when Include is set. Start and
+// End are byte offsets of the whole construct.
+type LinkMatch struct {
+	Start, End int
+	// Colon is the ":" a link may start with ([[:파일:A.png]]).
+	Colon string
+	// Display is the text after "|", empty when there is none.
+	Display string
+	// Include marks an [include(...)] macro; Sep is the "," or ")" that
+	// followed the title in it.
+	Include bool
+	Sep     string
+	// Params is what an XWiki link carries after the target ("||target=x").
+	Params string
+}
+
+// A LinkMatcher finds the references to one title in a document's text,
+// in order and without overlaps. Each Syntax has its own; namumark's
+// differ in how closely they read the markup, trading speed against
+// correctness, and data.ini's matcher chooses one.
+type LinkMatcher interface {
+	Match(text string) []LinkMatch
+}
+
+// linkMatcherKind is data.ini's matcher:
+//
+//	regex       the title exactly, found with a regular expression (default)
+//	normalized  like regex, but spaces and underscores in the title match
+//	            any run of either
+//	ast         a namumark scanner that also skips links in {{{literal}}}
+//	            blocks, ## comments and escaped brackets
+//	fast        the same matches as regex, found in one pass by searching
+//	            for the title itself; regex uses it for huge pages
+var linkMatcherKind = "regex"
+
+var linkMatcherKinds = []string{"regex", "normalized", "ast", "fast"}
+
+// hugePageBytes is the page size from which the regex matcher hands over
+// to the fast scanner.
+const hugePageBytes = 1 << 20
+
+// newLinkMatcher returns the matcher of the wiki's syntax for title (a
+// page, with or without a section anchor). include also matches
+// transclusions of title.
+func newLinkMatcher(title string, include bool) LinkMatcher {
+	return linkSyntax.Matcher(title, include)
+}
+
+// newNormalizedMatcher is the normalized matcher: spaces and underscores
+// in title match any run of either.
+func newNormalizedMatcher(title string, include bool) LinkMatcher {
+	var parts []string
+	for _, word := range strings.FieldsFunc(title, func(r rune) bool { return r == ' ' || r == '_' }) {
+		parts = append(parts, regexp.QuoteMeta(word))
+	}
+	return newRegexMatcher(strings.Join(parts, `[ _]+`), include)
+}
+
+// sizedMatcher uses the regex matcher for ordinary pages and the fast
+// scanner, which finds the same matches, for huge ones.
+type sizedMatcher struct {
+	small, huge LinkMatcher
+}
+
+func (s *sizedMatcher) Match(text string) []LinkMatch {
+	if len(text) >= hugePageBytes {
+		return s.huge.Match(text)
+	}
+	return s.small.Match(text)
+}
+
+// regexMatcher finds references with one regular expression built around
+// a pattern for the title.
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func newRegexMatcher(titlePattern string, include bool) *regexMatcher {
+	pattern := `\[\[[\t\f ]*(:?)[\t\f ]*` + titlePattern + `[\t\f ]*(?:\|([^\[\]]+))?\]\]`
+	if include {
+		pattern += `|\[include\([\t\f ]*` + titlePattern + `[\t\f ]*([,)])`
+	}
+	return &regexMatcher{re: regexp.MustCompile(pattern)}
+}
+
+func (r *regexMatcher) Match(text string) []LinkMatch {
+	var matches []LinkMatch
+	for _, m := range r.re.FindAllStringSubmatchIndex(text, -1) {
+		lm := LinkMatch{Start: m[0], End: m[1]}
+		if len(m) > 6 && m[6] >= 0 {
+			lm.Include, lm.Sep = true, text[m[6]:m[7]]
+		} else {
+			lm.Colon = text[m[2]:m[3]]
+			if m[4] >= 0 {
+				lm.Display = text[m[4]:m[5]]
+			}
+		}
+		matches = append(matches, lm)
+	}
+	return matches
+}
+
+// xwikiMatcher finds XWiki 2.x links: [[Space.Page]], [[label>>Space.Page]],
+// optionally with a doc: prefix and ||parameters. Colon holds the prefix
+// and Display the label. With include it also finds the include macro's
+// reference="Space.Page", up to the closing quote; the macro's other
+// parameters are left as they are.
+type xwikiMatcher struct {
+	re *regexp.Regexp
+}
+
+func newXWikiMatcher(title string, include bool) *xwikiMatcher {
+	quoted := regexp.QuoteMeta(title)
+	expr := `\[\[(?:([^\[\]]*?)>>)?[\t\f ]*((?:doc:)?)` + quoted + `[\t\f ]*(\|\|[^\[\]]*)?\]\]`
+	if include {
+		expr += `|\{\{include[\t\f ]+reference="` + quoted + `(")`
+	}
+	return &xwikiMatcher{re: regexp.MustCompile(expr)}
+}
+
+func (x *xwikiMatcher) Match(text string) []LinkMatch {
+	var matches []LinkMatch
+	for _, m := range x.re.FindAllStringSubmatchIndex(text, -1) {
+		if len(m) > 8 && m[8] >= 0 {
+			matches = append(matches, LinkMatch{Start: m[0], End: m[1], Include: true, Sep: text[m[8]:m[9]]})
+			continue
+		}
+		lm := LinkMatch{Start: m[0], End: m[1], Colon: text[m[4]:m[5]]}
+		if m[2] >= 0 {
+			lm.Display = text[m[2]:m[3]]
+		}
+		if m[6] >= 0 {
+			lm.Params = text[m[6]:m[7]]
+		}
+		matches = append(matches, lm)
+	}
+	return matches
+}
+
+// astMatcher scans namumark the way the engine reads it: text in literal
+// {{{...}}} blocks and ## comment lines is not markup, and a backslash
+// escapes the next character, so links there are left alone.
+type astMatcher struct {
+	title   string
+	include bool
+}
+
+func (a *astMatcher) Match(text string) []LinkMatch {
+	var matches []LinkMatch
+	scanNamumark(text, func(tok namuToken, i, end int) int {
+		switch tok {
+		case namuLink:
+			if m, ok := a.link(text[i+2 : end-2]); ok {
+				m.Start, m.End = i, end
+				matches = append(matches, m)
+			}
+		case namuInclude:
+			rest := text[i:]
+			n := strings.IndexAny(rest[9:], ",)")
+			if a.include && n >= 0 && strings.TrimSpace(rest[9:9+n]) == a.title {
+				matches = append(matches, LinkMatch{Start: i, End: i + 9 + n + 1, Include: true, Sep: rest[9+n : 9+n+1]})
+				return i + 9 + n + 1
+			}
+		}
+		return end
+	})
+	return matches
+}
+
+// A namuToken is a piece of namumark text scanNamumark hands over.
+type namuToken int
+
+const (
+	namuText    namuToken = iota // one byte of markup
+	namuLiteral                  // one byte of a literal {{{...}}} block
+	namuComment                  // a ## comment line, without its newline
+	namuLink                     // a whole [[...]]
+	namuInclude                  // the "[include(" opening an include macro
+)
+
+// scanNamumark walks text the way the engine reads it and calls visit for
+// each token at text[i:end]. The block braces and the backslash escapes
+// are not handed over. visit returns where to go on from, end or, to take
+// in more of the text, later; an include's arguments are scanned as
+// markup unless visit skips them.
+func scanNamumark(text string, visit func(tok namuToken, i, end int) int) {
+	// blocks holds, for each open {{{ block, whether it is literal.
+	var blocks []bool
+	literal := func() bool { return len(blocks) > 0 && blocks[len(blocks)-1] }
+	lineStart := true
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, "{{{"):
+			blocks = append(blocks, literal() || literalBlock(rest[3:]))
+			i += 3
+		case strings.HasPrefix(rest, "}}}") && len(blocks) > 0:
+			blocks = blocks[:len(blocks)-1]
+			i += 3
+		case literal():
+			i = visit(namuLiteral, i, i+1)
+		case rest[0] == '\\' && len(rest) > 1:
+			i += 2
+		case lineStart && strings.HasPrefix(rest, "##"):
+			end := len(text)
+			if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+				end = i + nl
+			}
+			i = visit(namuComment, i, end)
+		case strings.HasPrefix(rest, "[["):
+			end := strings.Index(rest, "]]")
+			if end < 0 {
+				i += 2
+				break
+			}
+			i = visit(namuLink, i, i+end+2)
+		case strings.HasPrefix(rest, "[include("):
+			i = visit(namuInclude, i, i+9)
+		default:
+			i = visit(namuText, i, i+1)
+		}
+		lineStart = i > 0 && text[i-1] == '\n'
+	}
+}
+
+// link reads the inside of a [[...]] and reports whether it targets the
+// matcher's title.
+func (a *astMatcher) link(inner string) (LinkMatch, bool) {
+	target, display, hasDisplay := strings.Cut(inner, "|")
+	if hasDisplay && display == "" {
+		return LinkMatch{}, false
+	}
+	target = strings.TrimSpace(target)
+	var m LinkMatch
+	if t, ok := strings.CutPrefix(target, ":"); ok {
+		m.Colon, target = ":", strings.TrimSpace(t)
+	}
+	if target != a.title || strings.ContainsAny(display, "[]") {
+		return LinkMatch{}, false
+	}
+	m.Display = display
+	return m, true
+}
+
+// literalBlock reports whether a {{{ block whose text starts with rest
+// is literal text. Blocks starting with "#!wiki", "#!folding", a size
+// ("+1", "-1") or a color ("#red ") hold markup; "#!syntax", "#!html" and
+// plain blocks do not.
+func literalBlock(rest string) bool {
+	switch {
+	case strings.HasPrefix(rest, "#!wiki"), strings.HasPrefix(rest, "#!folding"):
+		return false
+	case strings.HasPrefix(rest, "#!"):
+		return true
+	case len(rest) > 1 && (rest[0] == '+' || rest[0] == '-') && rest[1] >= '1' && rest[1] <= '5':
+		return false
+	case strings.HasPrefix(rest, "#"):
+		word, _, _ := strings.Cut(rest[1:], " ")
+		return word == "" || strings.ContainsAny(word, "\n}")
+	}
+	return true
+}
+
+// scanMatcher finds exactly what regexMatcher does for a literal title,
+// but searches for the title with strings.Index and checks the link syntax
+// around each occurrence, instead of trying the expression at every byte.
+type scanMatcher struct {
+	title   string
+	include bool
+}
+
+func (s *scanMatcher) Match(text string) []LinkMatch {
+	var matches []LinkMatch
+	if s.title == "" {
+		return nil
+	}
+	last := 0
+	for from := 0; ; {
+		i := strings.Index(text[from:], s.title)
+		if i < 0 {
+			return matches
+		}
+		at := from + i
+		from = at + 1
+		if m, ok := s.around(text, at, last); ok {
+			matches = append(matches, m)
+			last, from = m.End, m.End
+		}
+	}
+}
+
+// around checks for link or include syntax around the title at text[at:],
+// starting no earlier than min.
+func (s *scanMatcher) around(text string, at, min int) (LinkMatch, bool) {
+	end := at + len(s.title)
+	// Backwards: [\t\f ]* then an optional ":" then [\t\f ]* then "[[",
+	// or [\t\f ]* then "[include(".
+	p := skipBlanksBack(text, at, min)
+	if s.include && p-9 >= min && text[p-9:p] == "[include(" {
+		q := skipBlanks(text, end)
+		if q < len(text) && (text[q] == ',' || text[q] == ')') {
+			return LinkMatch{Start: p - 9, End: q + 1, Include: true, Sep: text[q : q+1]}, true
+		}
+	}
+	var m LinkMatch
+	if p > min && text[p-1] == ':' {
+		m.Colon = ":"
+		p = skipBlanksBack(text, p-1, min)
+	}
+	if p-2 < min || text[p-2:p] != "[[" {
+		return LinkMatch{}, false
+	}
+	m.Start = p - 2
+	// Forwards: [\t\f ]* then "]]", or "|" display "]]".
+	q := skipBlanks(text, end)
+	if strings.HasPrefix(text[q:], "]]") {
+		m.End = q + 2
+		return m, true
+	}
+	if !strings.HasPrefix(text[q:], "|") {
+		return LinkMatch{}, false
+	}
+	d := strings.IndexAny(text[q+1:], "[]")
+	if d <= 0 || !strings.HasPrefix(text[q+1+d:], "]]") {
+		return LinkMatch{}, false
+	}
+	m.Display = text[q+1 : q+1+d]
+	m.End = q + 1 + d + 2
+	return m, true
+}
+
+func isBlank(c byte) bool { return c == ' ' || c == '\t' || c == '\f' }
+
+func skipBlanks(text string, i int) int {
+	for i < len(text) && isBlank(text[i]) {
+		i++
+	}
+	return i
+}
+
+func skipBlanksBack(text string, i, min int) int {
+	for i > min && isBlank(text[i-1]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+var locale = "en"
+
+// humanOut receives the messages meant for people; it is moved to stderr
+// when stdout carries machine-readable output.
+var humanOut = stdout
+
+var catalog = map[string]map[string]string{
+	"en": {
+		"prompt_domain":          "Enter domain (e.g. theseed.io): ",
+		"prompt_token":           "Enter API token (empty to read anonymously): ",
+		"prompt_namespaces":      "Enter namespaces to search (comma-separated): ",
+		"prompt_log_template":    "Enter log template (use {old} and {new}): ",
+		"prompt_watch_document":  "Enter document to watch for open discussion: ",
+		"prompt_old_title":       "Enter old title: ",
+		"prompt_new_title":       "Enter new title: ",
+		"prompt_keep_text":       "Keep display text for bare links? (y/n): ",
+		"prompt_proceed":         "Proceed with editing? (y/n): ",
+		"prompt_revert":          "Revert them? (y/n): ",
+		"run_id":                 "Run ID: %s",
+		"api_detected":           "Wiki API: %s %s",
+		"engine_unknown":         "Check engine in config.ini: %v",
+		"timeout_invalid":        "Ignoring timeout %s = %q in config.ini: not a duration like 30s",
+		"api_probe_failed":       "Could not probe the wiki's API, assuming the seed engine's: %v",
+		"aborted":                "Aborted.",
+		"sandbox_mode":           "Sandbox mode: rewritten pages are saved under '%s'.",
+		"found_backlinks":        "Found %d backlinks to process.",
+		"namespace_count":        "  %s: %d",
+		"jobs_touch_documents":   "%d jobs touch %d distinct documents.",
+		"processed_backlinks":    "Processed %d backlinks.",
+		"backlink_fetch_failed":  "Error fetching backlinks in namespace '%s': %v",
+		"backend_ns_skipped":     "Skipping %d backlinks to %s outside the configured namespaces (%s). Add those namespaces to data.ini's namespaces to edit them.",
+		"perm_denied":            "Cannot edit %s due to insufficient permissions (%s).",
+		"process_failed":         "Failed to process %s (%s): %v",
+		"updated":                "Updated %s%s (%s) as '%s'",
+		"updated_links":          "Updated %s%s (%s) as '%s', %d links",
+		"remaining_summary":      "Old titles are still mentioned in %d documents: %d links left alone, %d in plain text, %d in template arguments, %d in literal text.",
+		"summary_links":          " (%d links)",
+		"account_switch":         "Account '%s' cannot edit (%v), switching account.",
+		"account_wait":           "Account '%s' cannot edit (%v), waiting a minute.",
+		"no_accounts_left":       "No usable accounts left. Stopping bot.",
+		"merged_concurrent_edit": "Merged bot rewrite with a concurrent edit of %s.",
+		"batch_load_failed":      "Failed to load batch: %v",
+		"discuss_check_failed":   "Error checking discuss: %v (failure %d of %d, retrying in %v)",
+		"discuss_watch_gave_up":  "Stopping: the discussion watch failed %d times in a row: %v",
+		"discuss_open_stop":      "Discuss on '%s' is normal. Stopping bot.",
+		"discuss_topic_invalid":  "discussTopic %q in data.ini is not a valid regular expression: %v",
+		"discuss_unsupported":    "watchDocument %s cannot be watched: the %s engine has no discussion API. Remove watchDocument from data.ini to run without the discussion kill switch.",
+		"discuss_paused":         "A discussion opened on '%s'. Editing is paused until it closes.",
+		"discuss_resumed":        "The discussion on '%s' closed. Resuming.",
+		"discuss_open_finish":    "A discussion opened on '%s'. Stopping after the current namespace.",
+		"namespace_done_stop":    "Finished namespace %s; stopping for the open discussion.",
+		"command_thread_failed":  "Error reading command thread: %v",
+		"command_stop":           "Stopped by %s via command thread.",
+		"command_bad_duration":   "Ignoring '%s' from %s: invalid duration.",
+		"command_slow":           "Edit delay set to %s by %s.",
+		"command_fast":           "Edit rate reset by %s.",
+		"notice_failed":          "Failed to post notice for %s: %v",
+		"notice_posted":          "Posted notice on the discussion of %s.",
+		"plan_found_backlinks":   "Found %d backlinks to plan.",
+		"fetch_failed":           "Failed to fetch %s (%d/%d): %v",
+		"plan_write_failed":      "Failed to write plan: %v",
+		"plan_written":           "Wrote plan with %d edits to %s. Review it, then run 'apply %s'.",
+		"plan_read_failed":       "Failed to read plan: %v",
+		"plan_parse_failed":      "Failed to parse plan: %v",
+		"plan_bad_signature":     "Plan signature does not match. Refusing to apply.",
+		"plan_wrong_domain":      "Plan was made for '%s', not '%s'. Refusing to apply.",
+		"plan_applying":          "Applying plan from run %s.",
+		"apply_failed":           "Failed to apply %s (%d/%d): %v",
+		"apply_updated":          "Updated %s (%d/%d) as '%s'",
+		"no_user":                "No user given. Set 'user' in config.ini or pass -user.",
+		"contribs_failed":        "Failed to list contributions: %v",
+		"invalid_flag":           "Invalid -%s: %v",
+		"rollback_found":         "Found %d documents edited by %s in run %s.",
+		"rollback_summary":       "Revert run %s",
+		"revert_failed":          "Failed to revert %s (%d/%d): %v",
+		"reverted":               "Reverted %s to r%d (%d/%d) as '%s'",
+		"notice_default_topic":   "Backlinks cleaned up: {old} → {new}",
+		"daemon_listening":       "Control API listening on %s.",
+		"daemon_failed":          "Control API stopped: %v",
+		"daemon_needs_token":     "Refusing to start the control API: %v. Set controlToken or listen on 127.0.0.1.",
+		"daemon_grpc_listening":  "gRPC control API listening on %s.",
+		"daemon_grpc_selfsigned": "gRPC control API uses a self-signed certificate, SHA-256 %s.",
+		"daemon_job_paused":      "Job %s paused for a higher-priority job.",
+		"daemon_queue_edited":    "Job %s queue: %s %d documents.",
+		"diag_listening":         "Diagnostics listening on %s.",
+		"diag_failed":            "Diagnostics server stopped: %v",
+		"report_summary":         "Edited %d, unchanged %d, skipped %d, failed %d, given up %d.",
+		"report_dead_letters":    "Documents given up after repeated failures:",
+		"report_filtered":        "Documents rejected by edit filters:",
+		"edit_filtered":          "Edit filter rejected %s (%s): %s",
+		"report_item":            "  %s: %s",
+		"report_write_failed":    "Failed to write report: %v",
+		"report_written":         "Wrote report to %s.",
+		"stats_links":            "Rewrote %d links.",
+		"stats_bucket":           "  %s links per page: %d pages",
+		"stats_namespace":        "  %s: %d edits",
+		"stats_timing":           "%d API calls, %v average latency, %v elapsed.",
+		"leftover_intro":         "Documents that run %s (%s) could not edit. Please fix their links by hand.",
+		"leftover_col_document":  "Document",
+		"leftover_col_reason":    "Reason",
+		"leftover_protected":     "Protected",
+		"leftover_filtered":      "Edit filter: %s",
+		"leftover_summary":       "List of documents left by run %s",
+		"status_intro":           "Status of run %s: %s (updated %s)",
+		"status_running":         "running",
+		"status_finished":        "finished",
+		"status_progress":        "Progress: %d of %d documents (%.1f%%)",
+		"status_processed":       "Processed: %d documents",
+		"status_last_edit":       "Last edit: %s",
+		"status_no_edit":         "none yet",
+		"status_summary":         "Status of run %s (%.0f%%)",
+		"status_failed":          "Could not update the status page %s: %v",
+		"leftover_posted":        "Listed %[2]d unedited documents on %[1]s.",
+		"leftover_failed":        "Failed to save the unedited document list to %s: %v",
+		"mail_subject_done":      "[micro-rearalice] Run %s finished",
+		"mail_subject_failed":    "[micro-rearalice] Run %s finished with failures",
+		"mail_intro":             "Run %[2]s on %[1]s has finished.",
+		"mail_sent":              "Mailed the report to %s.",
+		"mail_failed":            "Failed to mail the report: %v",
+		"mail_subject_aborted":   "[micro-rearalice] Run %s aborted",
+		"mail_subject_paused":    "[micro-rearalice] Run %s paused",
+		"mail_subject_resumed":   "[micro-rearalice] Run %s resumed",
+		"notify_rule_invalid":    "Ignoring notification rule %s: %v",
+		"notify_unknown_channel": "Notification rule %s names unknown channel %q.",
+		"notify_no_webhook":      "discordWebhook is not set in config.ini.",
+		"discord_failed":         "Failed to post to Discord: %v",
+		"sink_load_failed":       "Ignoring event sink %s: %v",
+		"sink_failed":            "Event sink error: %v",
+		"preview_fetch_failed":   "Failed to fetch %s: %v",
+		"preview_no_changes":     "%s has no links to rewrite.",
+		"preview_summary":        "%s: %d links would change. Summary: %s",
+		"sample_namespace":       "== %s: previewing %d of %d documents",
+		"safe_mode_preview":      "Safe mode: previewing the first %d documents before saving anything.",
+		"canary_start":           "Editing %d canary documents first.",
+		"usage_main":             "usage: micro-rearalice [command] [flags] [arguments]",
+		"usage_command":          "usage: micro-rearalice %s [flags] [arguments]",
+		"usage_more":             "Without a command the bot runs edit. 'help <command>' lists a command's flags.",
+		"help_unknown":           "No command %q; 'help' lists them.",
+		"cmd_edit":               "Rename links to a title across the wiki (the default).",
+		"cmd_plan":               "Work out the edits of a rename and save them as a plan to review.",
+		"cmd_apply":              "Save the edits of a reviewed plan or fetched directory.",
+		"cmd_preview":            "Show the diff a rename would make to one document.",
+		"cmd_estimate":           "Count what a rename would touch and how long it would take.",
+		"cmd_backlinks":          "List the documents linking to a title.",
+		"cmd_fetch":              "Download the documents linking to a title for offline work.",
+		"cmd_contribs":           "List a user's document edits.",
+		"cmd_rollback":           "Revert the edits of an earlier run.",
+		"cmd_daemon":             "Serve rename requests over HTTP.",
+		"cmd_update":             "Update the bot to the latest release.",
+		"cmd_runs":               "List the runs' directories, or clean old ones up.",
+		"run_dir_failed":         "Run directory: %v",
+		"anonymous_mode":         "No token is configured, so the wiki is read anonymously. Commands that edit, such as edit and apply, need a token in config.ini.",
+		"token_required":         "%s edits the wiki and needs a token in config.ini.",
+		"cmd_decrypt":            "Print encrypted run artifacts in the clear.",
+		"cmd_report":             "Compare two runs' results: report diff RUN-A RUN-B.",
+		"report_read_failed":     "Could not read the report of %s: %v",
+		"report_diff_header":     "%s → %s: %d documents changed, %d kept their status.",
+		"report_diff_group":      "%s → %s (%d):",
+		"report_diff_item":       "  %s",
+		"report_diff_absent":     "not in run",
+		"decrypt_usage":          "Usage: decrypt [-in-place] FILE...",
+		"backlinks_usage":        "Usage: backlinks [-namespace a,b] [-list] TITLE",
+		"fetch_usage":            "Usage: fetch [-o DIR] [-namespace a,b] TITLE",
+		"apply_usage":            "Usage: apply [-summary TEXT] PLAN.json|FETCHED-DIR",
+		"preview_usage":          "Usage: preview [-batch jobs.ini] DOCUMENT",
+		"report_diff_usage":      "Usage: report diff [-output text|json] RUN-A RUN-B",
+		"rollback_usage":         "Usage: rollback [-user NAME] RUN-ID",
+		"runs_usage":             "Usage: runs [list | clean [-older-than D] [-keep N] [-yes]]",
+		"completion_usage":       "Usage: completion bash|zsh|fish",
+		"decrypt_failed":         "Could not decrypt: %v",
+		"artifact_key_failed":    "Artifact key: %v",
+		"runs_none":              "No runs in %s.",
+		"runs_unfinished":        "unfinished",
+		"runs_finished":          "edited %d, failed %d",
+		"runs_nothing_to_clean":  "No runs to clean up.",
+		"runs_clean_item":        "  %s (started %s)",
+		"prompt_runs_clean":      "Remove these %d runs' directories? (y/n): ",
+		"runs_cleaned":           "Removed %d runs.",
+		"cmd_help":               "Show the commands, or one command's flags.",
+		"cmd_completion":         "Print the bash, zsh or fish completion script.",
+		"template_unknown":       "data.ini has no [template.%s] (templates: %s).",
+		"template_titles":        "Give a template both the old and the new title, or neither to be asked.",
+		"template_bad_value":     "Template %s sets %s badly: %v",
+		"template_applied":       "Using job template %s.",
+		"http_retry":             "Retrying %s (request %s) after try %d in %v.",
+		"retry_status_invalid":   "Ignoring the retry status %q, not an HTTP status code.",
+		"autotune_adjusted":      "Auto-tuning: %d backlink workers, %.1f edits per minute (p95 latency %v, %.0f%% errors).",
+		"prompt_canary":          "Check the canaries (%s) on the wiki. Go on with the rest? (y/n): ",
+		"canary_rejected":        "the operator rejected the canaries",
+		"canary_failed":          "Canary check failed (%v); stopping with %d documents unedited.",
+		"canary_passed":          "%d canaries verified; editing the rest.",
+		"prompt_safe_mode":       "Do the %d diffs above look right? Edit every document live? (y/n): ",
+		"stream_needs_queue":     "Safe mode and canaries need the full queue first; ignoring -stream.",
+		"backlinks_namespace":    "%s: %d",
+		"backlinks_flag":         "  %s: %d",
+		"backlinks_total":        "%[2]d backlinks to %[1]s in total.",
+		"redirect_found":         "Also fixing links to %s, which redirects to %s (depth %d).",
+		"pattern_expanded":       "%s matched %d documents.",
+		"pattern_failed":         "Failed to expand title pattern: %v",
+		"redirect_exists":        "%s has content; not creating a redirect.",
+		"redirect_created":       "Created redirect %s → %s.",
+		"redirect_failed":        "Failed to create a redirect at %s: %v",
+		"redirect_summary":       "Redirect to [[%s]] (run %s)",
+		"recent_edit_deferred":   "Deferred %s (%s): %v",
+		"needs_review":           "Held %s (%s) for review: %v",
+		"too_large":              "Skipped %s (%s): %v",
+		"skip_listed":            "Skipped %s (%s): %v",
+		"opted_out":              "%s (%s) opted out of bot edits; skipping.",
+		"skip_list_invalid":      "Ignoring the unreadable skip list %s: %v",
+		"skip_list_failed":       "Could not save the skip list: %v",
+		"document_timed_out":     "Gave up on %s (%s): %v",
+		"report_review":          "Documents held for manual review:",
+		"debug_http_failed":      "Cannot open the HTTP debug log: %v",
+		"update_current":         "Already up to date (%s).",
+		"update_available":       "Current version %s, latest release %s.",
+		"update_unsigned":        "This build has no update key; only the checksum is verified.",
+		"update_done":            "Updated to %s.",
+		"update_failed":          "Update failed: %v",
+		"document_gone":          "%s (%s) no longer exists; skipping.",
+		"report_gone":            "Documents deleted since they were listed:",
+		"docs_file_failed":       "Failed to read the document list: %v",
+		"docs_listed":            "%d documents from the document list.",
+		"docs_discovered":        "%d documents from backlinks.",
+		"docs_extra":             "%d extra documents added (%d already among them).",
+		"docs_excluded":          "%d documents excluded.",
+		"prompt_pick_namespaces": "Namespaces to process (comma-separated, empty for all: %s): ",
+		"prompt_pick_moves":      "Moves to fix links for (numbers, comma-separated, empty for the newest): ",
+		"move_log_item":          "%3d. %s → %s (%s, %s)",
+		"move_log_picked":        "Renaming links: %s → %s",
+		"move_log_failed":        "Could not read the move log: %v",
+		"move_log_empty":         "The move log is empty.",
+		"move_log_bad_pick":      "No move numbered %q.",
+		"move_log_no_move":       "%s was not moved in the latest %d moves.",
+		"move_log_needs_pick":    "-from-move-log with -yes needs -moves naming the old titles to take; refusing to guess from the newest move.",
+		"checkpoint_load_failed": "Failed to read the checkpoint: %v",
+		"checkpoint_failed":      "Failed to save the checkpoint: %v",
+		"resuming":               "Resuming run %s (checkpoint saved %s).",
+		"resume_sync":            "Run %s: %d still pending, %d fixed meanwhile, %d new, %d already processed.",
+		"time_up":                "Time is up; stopping with %d documents left.",
+		"metrics_push_failed":    "Failed to push metrics to %s: %v",
+		"skip_cache_invalid":     "Ignoring the unreadable skip cache %s: %v",
+		"skip_cache_failed":      "Failed to save the skip cache: %v",
+		"fetch_dir_failed":       "Cannot create the output directory: %v",
+		"fetch_write_failed":     "Failed to save a fetched document: %v",
+		"fetch_done":             "Saved %d documents in %s.",
+		"corpus_summary":         "Apply local edits (run %s)",
+		"corpus_read_failed":     "Failed to read %s: %v",
+		"corpus_changed":         "%d of %d documents were edited locally.",
+		"corpus_index_failed":    "Failed to read the directory's index.json: %v",
+		"corpus_wrong_domain":    "The documents were fetched from '%s', not '%s'. Refusing to apply.",
+		"estimate_documents":     "%d documents in %d namespaces link to the old title:",
+		"estimate_links":         "%d links in %d documents would change (%d have nothing to change, %d are protected).",
+		"estimate_duration":      "Editing %[2]d documents would take about %[1]s at the configured rate.",
+		"invalid_title":          "Invalid job: %v",
+		"namespace_inherited":    "New title '%s' has no namespace; using '%s' like the old title.",
+		"resume_hint":            "To continue, run again with -resume %s",
+		"manifest_written":       "Run manifest written to %s.",
+		"manifest_failed":        "Failed to write the run manifest: %v",
+		"protection_checked":     "Checked edit permission of %d/%d documents...",
+		"protection_split":       "%d documents are editable, %d are protected and set aside.",
+		"protected_out_failed":   "Failed to write the protected document list: %v",
+		"protected_written":      "Listed %d protected documents in %s.",
+		"report_protected":       "Protected documents left for a privileged account:",
+		"report_too_large":       "Documents skipped for their size (see maxPageBytes):",
+		"report_timed_out":       "Documents that took too long (see documentTimeout):",
+		"privileged_retry":       "%s is protected; retrying with the %s account.",
+		"namespaces_selected":    "Processing namespaces: %s",
+	},
+	"ko": {
+		"prompt_domain":          "도메인을 입력하세요 (예: theseed.io): ",
+		"prompt_token":           "API 토큰을 입력하세요 (비워 두면 익명으로 읽기만 합니다): ",
+		"prompt_namespaces":      "역링크를 탐색할 이름공간을 입력하세요 (쉼표로 구분): ",
+		"prompt_log_template":    "편집 요약 형식을 입력하세요 ({old}, {new} 사용 가능): ",
+		"prompt_watch_document":  "토론을 감시할 문서를 입력하세요: ",
+		"prompt_old_title":       "기존 표제어를 입력하세요: ",
+		"prompt_new_title":       "새 표제어를 입력하세요: ",
+		"prompt_keep_text":       "링크에 기존 표제어를 보이게 할까요? (y/n): ",
+		"prompt_proceed":         "편집을 시작할까요? (y/n): ",
+		"prompt_revert":          "되돌릴까요? (y/n): ",
+		"run_id":                 "실행 ID: %s",
+		"api_detected":           "위키 API: %s %s",
+		"engine_unknown":         "config.ini의 engine을 확인하세요: %v",
+		"timeout_invalid":        "config.ini의 시간 제한 %s = %q는 30s 같은 시간이 아니라 무시합니다",
+		"api_probe_failed":       "위키 API를 확인하지 못해 the seed 엔진의 API로 가정합니다: %v",
+		"aborted":                "중단했습니다.",
+		"sandbox_mode":           "연습장 모드: 치환 결과를 '%s' 아래에 저장합니다.",
+		"found_backlinks":        "처리할 역링크 %d개를 찾았습니다.",
+		"namespace_count":        "  %s: %d",
+		"jobs_touch_documents":   "작업 %d개가 서로 다른 문서 %d개를 편집합니다.",
+		"processed_backlinks":    "역링크 %d개를 처리했습니다.",
+		"backlink_fetch_failed":  "'%s' 이름공간의 역링크를 가져오지 못했습니다: %v",
+		"backend_ns_skipped":     "%[2]s 문서의 역링크 %[1]d개는 설정한 이름공간 밖(%[3]s)에 있어 건너뜁니다. 고치려면 data.ini의 namespaces에 그 이름공간을 추가하세요.",
+		"perm_denied":            "권한 문제로 %s 문서를 편집할 수 없습니다. (%s).",
+		"process_failed":         "%s 문서를 처리하지 못했습니다 (%s): %v",
+		"updated":                "%s%s 문서를 편집했습니다 (%s, 계정 '%s')",
+		"updated_links":          "%s%s 문서를 편집했습니다 (%s, 계정 '%s', 링크 %d개)",
+		"remaining_summary":      "문서 %d개에 기존 표제어가 남아 있습니다: 남겨 둔 링크 %d개, 본문 %d개, 틀 인자 %d개, 리터럴 %d개",
+		"summary_links":          " (링크 %d개)",
+		"account_switch":         "'%s' 계정으로 편집할 수 없어 (%v) 계정을 바꿉니다.",
+		"account_wait":           "'%s' 계정으로 편집할 수 없어 (%v) 1분 기다립니다.",
+		"no_accounts_left":       "사용할 수 있는 계정이 없습니다. 봇을 멈춥니다.",
+		"merged_concurrent_edit": "%s 문서의 다른 편집과 봇의 치환을 병합했습니다.",
+		"batch_load_failed":      "작업 파일을 읽지 못했습니다: %v",
+		"discuss_check_failed":   "토론을 확인하지 못했습니다: %v (%d/%d번째 실패, %v 뒤 다시 시도)",
+		"discuss_watch_gave_up":  "토론 감시가 %d번 연달아 실패해 멈춥니다: %v",
+		"discuss_open_stop":      "'%s' 문서에 토론이 열려 있습니다. 봇을 멈춥니다.",
+		"discuss_topic_invalid":  "data.ini의 discussTopic %q는 올바른 정규식이 아닙니다: %v",
+		"discuss_unsupported":    "%[2]s 엔진에는 토론 API가 없어 watchDocument %[1]s 문서를 감시할 수 없습니다. 토론 감시 없이 실행하려면 data.ini에서 watchDocument를 지우세요.",
+		"discuss_paused":         "'%s' 문서에 토론이 열려 토론이 닫힐 때까지 편집을 멈춥니다.",
+		"discuss_resumed":        "'%s' 문서의 토론이 닫혀 편집을 이어 갑니다.",
+		"discuss_open_finish":    "'%s' 문서에 토론이 열려 지금 이름공간까지만 마치고 멈춥니다.",
+		"namespace_done_stop":    "%s 이름공간을 마쳤습니다. 열린 토론 때문에 멈춥니다.",
+		"command_thread_failed":  "명령 스레드를 읽지 못했습니다: %v",
+		"command_stop":           "%s 님이 명령 스레드에서 봇을 멈췄습니다.",
+		"command_bad_duration":   "%[2]s 님의 '%[1]s' 명령을 무시합니다: 잘못된 시간입니다.",
+		"command_slow":           "%[2]s 님이 편집 간격을 %[1]s(으)로 바꿨습니다.",
+		"command_fast":           "%s 님이 편집 속도를 설정값으로 되돌렸습니다.",
+		"notice_failed":          "%s 문서의 알림을 올리지 못했습니다: %v",
+		"notice_posted":          "%s 문서의 토론에 알림을 올렸습니다.",
+		"plan_found_backlinks":   "계획할 역링크 %d개를 찾았습니다.",
+		"fetch_failed":           "%s 문서를 가져오지 못했습니다 (%d/%d): %v",
+		"plan_write_failed":      "계획 파일을 쓰지 못했습니다: %v",
+		"plan_written":           "편집 %d개를 담은 계획을 %s에 썼습니다. 검토한 뒤 'apply %s'를 실행하세요.",
+		"plan_read_failed":       "계획 파일을 읽지 못했습니다: %v",
+		"plan_parse_failed":      "계획 파일을 해석하지 못했습니다: %v",
+		"plan_bad_signature":     "계획 파일의 서명이 맞지 않아 적용하지 않습니다.",
+		"plan_wrong_domain":      "계획은 '%s'용으로 만들어졌습니다 ('%s' 아님). 적용하지 않습니다.",
+		"plan_applying":          "실행 %s의 계획을 적용합니다.",
+		"apply_failed":           "%s 문서에 적용하지 못했습니다 (%d/%d): %v",
+		"apply_updated":          "%s 문서를 편집했습니다 (%d/%d, 계정 '%s')",
+		"no_user":                "사용자가 지정되지 않았습니다. config.ini에 'user'를 적거나 -user 옵션을 주세요.",
+		"contribs_failed":        "기여 목록을 가져오지 못했습니다: %v",
+		"invalid_flag":           "-%s 값이 잘못되었습니다: %v",
+		"rollback_found":         "실행 %[3]s에서 %[2]s 계정이 편집한 문서 %[1]d개를 찾았습니다.",
+		"rollback_summary":       "실행 %s 되돌리기",
+		"revert_failed":          "%s 문서를 되돌리지 못했습니다 (%d/%d): %v",
+		"reverted":               "%s 문서를 r%d 판으로 되돌렸습니다 (%d/%d, 계정 '%s')",
+		"notice_default_topic":   "역링크 정리 완료: {old} → {new}",
+		"daemon_listening":       "%s에서 제어 API를 엽니다.",
+		"daemon_failed":          "제어 API가 멈췄습니다: %v",
+		"daemon_needs_token":     "제어 API를 열지 않습니다: %v. controlToken을 설정하거나 127.0.0.1에서 여세요.",
+		"daemon_grpc_listening":  "%s에서 gRPC 제어 API를 엽니다.",
+		"daemon_grpc_selfsigned": "gRPC 제어 API가 자체 서명 인증서를 씁니다. SHA-256 %s",
+		"daemon_job_paused":      "우선순위가 더 높은 작업 때문에 작업 %s을(를) 잠시 멈춥니다.",
+		"daemon_queue_edited":    "작업 %s 대기열: 문서 %[3]d개 %[2]s",
+		"diag_listening":         "%s에서 진단 서버를 엽니다.",
+		"diag_failed":            "진단 서버가 멈췄습니다: %v",
+		"report_summary":         "편집 %d, 변경 없음 %d, 건너뜀 %d, 실패 %d, 포기 %d.",
+		"report_dead_letters":    "여러 번 실패하여 포기한 문서:",
+		"report_filtered":        "편집 필터에 걸린 문서:",
+		"edit_filtered":          "%s 문서가 편집 필터에 걸렸습니다 (%s): %s",
+		"report_item":            "  %s: %s",
+		"report_write_failed":    "보고서를 쓰지 못했습니다: %v",
+		"report_written":         "보고서를 %s에 썼습니다.",
+		"stats_links":            "링크 %d개를 바꾸었습니다.",
+		"stats_bucket":           "  문서당 링크 %s개: 문서 %d개",
+		"stats_namespace":        "  %s: 편집 %d회",
+		"stats_timing":           "API 호출 %d회, 평균 응답 시간 %v, 걸린 시간 %v.",
+		"leftover_intro":         "실행 %s(%s)에서 편집하지 못한 문서입니다. 직접 링크를 고쳐 주세요.",
+		"leftover_col_document":  "문서",
+		"leftover_col_reason":    "사유",
+		"leftover_protected":     "보호됨",
+		"leftover_filtered":      "편집 필터: %s",
+		"leftover_summary":       "실행 %s에서 남은 문서 목록",
+		"status_intro":           "실행 %s 상태: %s (%s 갱신)",
+		"status_running":         "진행 중",
+		"status_finished":        "끝남",
+		"status_progress":        "진행: 문서 %d/%d개 (%.1f%%)",
+		"status_processed":       "처리: 문서 %d개",
+		"status_last_edit":       "마지막 편집: %s",
+		"status_no_edit":         "아직 없음",
+		"status_summary":         "실행 %s 상태 (%.0f%%)",
+		"status_failed":          "상태 문서 %s를 갱신하지 못했습니다: %v",
+		"leftover_posted":        "편집하지 못한 문서 %[2]d개를 %[1]s에 올렸습니다.",
+		"leftover_failed":        "편집하지 못한 문서 목록을 %s에 저장하지 못했습니다: %v",
+		"mail_subject_done":      "[micro-rearalice] 실행 %s 완료",
+		"mail_subject_failed":    "[micro-rearalice] 실행 %s 완료 (실패 있음)",
+		"mail_intro":             "%s에서 실행 %s이 끝났습니다.",
+		"mail_sent":              "보고서를 %s에 메일로 보냈습니다.",
+		"mail_failed":            "보고서를 메일로 보내지 못했습니다: %v",
+		"mail_subject_aborted":   "[micro-rearalice] 실행 %s 중단",
+		"mail_subject_paused":    "[micro-rearalice] 실행 %s 일시 정지",
+		"mail_subject_resumed":   "[micro-rearalice] 실행 %s 재개",
+		"notify_rule_invalid":    "알림 규칙 %s을 무시합니다: %v",
+		"notify_unknown_channel": "알림 규칙 %s에 알 수 없는 채널 %q가 있습니다.",
+		"notify_no_webhook":      "config.ini에 discordWebhook이 없습니다.",
+		"discord_failed":         "디스코드에 알리지 못했습니다: %v",
+		"sink_load_failed":       "이벤트 출력 %s을 무시합니다: %v",
+		"sink_failed":            "이벤트 출력 오류: %v",
+		"preview_fetch_failed":   "%s 문서를 가져오지 못했습니다: %v",
+		"preview_no_changes":     "%s 문서에는 바꿀 링크가 없습니다.",
+		"preview_summary":        "%s: 링크 %d개가 바뀝니다. 편집 요약: %s",
+		"sample_namespace":       "== %s: 문서 %[3]d개 중 %[2]d개 미리 보기",
+		"safe_mode_preview":      "안전 모드: 저장하기 전에 처음 %d개 문서를 미리 봅니다.",
+		"canary_start":           "카나리아 문서 %d개를 먼저 편집합니다.",
+		"usage_main":             "사용법: micro-rearalice [명령] [옵션] [인자]",
+		"usage_command":          "사용법: micro-rearalice %s [옵션] [인자]",
+		"usage_more":             "명령을 주지 않으면 edit을 실행합니다. 'help <명령>'으로 명령의 옵션을 봅니다.",
+		"help_unknown":           "%q 명령은 없습니다. 'help'로 목록을 보세요.",
+		"cmd_edit":               "위키 전체에서 표제어로 가는 링크를 바꿉니다 (기본 명령).",
+		"cmd_plan":               "이름 변경의 편집을 미리 계산해 검토할 계획으로 저장합니다.",
+		"cmd_apply":              "검토한 계획이나 받아 둔 디렉터리의 편집을 저장합니다.",
+		"cmd_preview":            "문서 하나에 생길 차이를 보여 줍니다.",
+		"cmd_estimate":           "이름 변경이 건드릴 문서와 걸릴 시간을 추정합니다.",
+		"cmd_backlinks":          "표제어로 링크하는 문서를 나열합니다.",
+		"cmd_fetch":              "표제어로 링크하는 문서를 내려받습니다.",
+		"cmd_contribs":           "사용자의 문서 편집 기록을 봅니다.",
+		"cmd_rollback":           "이전 실행의 편집을 되돌립니다.",
+		"cmd_daemon":             "HTTP로 이름 변경 요청을 받아 처리합니다.",
+		"cmd_update":             "봇을 최신 릴리스로 업데이트합니다.",
+		"cmd_runs":               "실행별 디렉터리를 나열하거나 오래된 것을 지웁니다.",
+		"run_dir_failed":         "실행 디렉터리: %v",
+		"anonymous_mode":         "토큰이 설정되지 않아 위키를 익명으로 읽습니다. edit, apply처럼 편집하는 명령은 config.ini에 토큰이 있어야 합니다.",
+		"token_required":         "%s 명령은 위키를 편집하므로 config.ini에 토큰이 있어야 합니다.",
+		"cmd_decrypt":            "암호화된 실행 기록을 복호화해 출력합니다.",
+		"cmd_report":             "두 실행의 결과를 비교합니다: report diff 실행A 실행B",
+		"report_read_failed":     "%s의 보고서를 읽을 수 없습니다: %v",
+		"report_diff_header":     "%s → %s: 문서 %d개의 상태가 바뀌었고 %d개는 그대로입니다.",
+		"report_diff_group":      "%s → %s (%d개):",
+		"report_diff_item":       "  %s",
+		"report_diff_absent":     "실행에 없음",
+		"decrypt_usage":          "사용법: decrypt [-in-place] 파일...",
+		"backlinks_usage":        "사용법: backlinks [-namespace a,b] [-list] 제목",
+		"fetch_usage":            "사용법: fetch [-o 디렉터리] [-namespace a,b] 제목",
+		"apply_usage":            "사용법: apply [-summary 요약] 계획.json|내려받은-디렉터리",
+		"preview_usage":          "사용법: preview [-batch jobs.ini] 문서",
+		"report_diff_usage":      "사용법: report diff [-output text|json] 실행A 실행B",
+		"rollback_usage":         "사용법: rollback [-user 이름] 실행-ID",
+		"runs_usage":             "사용법: runs [list | clean [-older-than 기간] [-keep 개수] [-yes]]",
+		"completion_usage":       "사용법: completion bash|zsh|fish",
+		"decrypt_failed":         "복호화할 수 없습니다: %v",
+		"artifact_key_failed":    "기록 암호화 키: %v",
+		"runs_none":              "%s에 실행 기록이 없습니다.",
+		"runs_unfinished":        "끝나지 않음",
+		"runs_finished":          "편집 %d, 실패 %d",
+		"runs_nothing_to_clean":  "지울 실행이 없습니다.",
+		"runs_clean_item":        "  %s (%s 시작)",
+		"prompt_runs_clean":      "이 실행 %d개의 디렉터리를 지울까요? (y/n): ",
+		"runs_cleaned":           "실행 %d개를 지웠습니다.",
+		"cmd_help":               "명령 목록이나 명령 하나의 옵션을 보여 줍니다.",
+		"cmd_completion":         "bash, zsh, fish 자동 완성 스크립트를 출력합니다.",
+		"template_unknown":       "data.ini에 [template.%s] 구역이 없습니다 (있는 틀: %s).",
+		"template_titles":        "작업 틀에는 기존 표제어와 새 표제어를 모두 주거나, 물어보도록 둘 다 비우세요.",
+		"template_bad_value":     "작업 틀 %s의 %s 값이 잘못되었습니다: %v",
+		"template_applied":       "작업 틀 %s을(를) 씁니다.",
+		"http_retry":             "%s 요청(%s)이 %d번째에 실패해 %v 뒤 다시 보냅니다.",
+		"retry_status_invalid":   "재시도 상태 코드 %q는 HTTP 상태 코드가 아니므로 무시합니다.",
+		"autotune_adjusted":      "자동 조정: 역링크 작업자 %d개, 분당 편집 %.1f회 (p95 지연 %v, 오류 %.0f%%)",
+		"prompt_canary":          "위키에서 카나리아 문서(%s)를 확인하세요. 나머지도 편집할까요? (y/n): ",
+		"canary_rejected":        "운영자가 카나리아 문서를 거부함",
+		"canary_failed":          "카나리아 확인에 실패했습니다 (%v). 문서 %d개를 편집하지 않고 멈춥니다.",
+		"canary_passed":          "카나리아 %d개를 확인했습니다. 나머지를 편집합니다.",
+		"prompt_safe_mode":       "위의 차이 %d개가 맞나요? 모든 문서를 실제로 편집할까요? (y/n): ",
+		"stream_needs_queue":     "안전 모드와 카나리아 문서는 먼저 전체 목록이 필요하므로 -stream을 무시합니다.",
+		"backlinks_namespace":    "%s: %d",
+		"backlinks_flag":         "  %s: %d",
+		"backlinks_total":        "%s의 역링크는 모두 %d개입니다.",
+		"redirect_found":         "%s 문서가 %s 문서로 넘겨주므로 이 문서로의 링크도 고칩니다. (깊이 %d)",
+		"pattern_expanded":       "%s에 맞는 문서 %d개를 찾았습니다.",
+		"pattern_failed":         "표제어 패턴을 펼치지 못했습니다: %v",
+		"redirect_exists":        "%s 문서에 내용이 있어 넘겨주기를 만들지 않습니다.",
+		"redirect_created":       "넘겨주기를 만들었습니다: %s → %s",
+		"redirect_failed":        "%s에 넘겨주기를 만들지 못했습니다: %v",
+		"redirect_summary":       "[[%s]](으)로 넘겨주기 (실행 %s)",
+		"recent_edit_deferred":   "%s 문서를 나중에 다시 시도합니다 (%s): %v",
+		"needs_review":           "%s 문서(%s)는 직접 검토해야 합니다: %v",
+		"too_large":              "%s 문서(%s)는 너무 커서 건너뜁니다: %v",
+		"skip_listed":            "%s 문서(%s)를 건너뜁니다: %v",
+		"opted_out":              "%s 문서(%s)는 봇 편집을 거부해 건너뜁니다.",
+		"skip_list_invalid":      "건너뛸 문서 목록 %s를 읽지 못해 무시합니다: %v",
+		"skip_list_failed":       "건너뛸 문서 목록을 저장하지 못했습니다: %v",
+		"document_timed_out":     "%s 문서(%s)는 시간이 너무 오래 걸려 건너뜁니다: %v",
+		"report_review":          "직접 검토가 필요한 문서:",
+		"debug_http_failed":      "HTTP 디버그 기록 파일을 열 수 없습니다: %v",
+		"update_current":         "이미 최신 버전입니다 (%s).",
+		"update_available":       "현재 버전 %s, 최신 릴리스 %s.",
+		"update_unsigned":        "이 빌드에는 업데이트 서명 키가 없어 체크섬만 확인합니다.",
+		"update_done":            "%s 버전으로 업데이트했습니다.",
+		"update_failed":          "업데이트하지 못했습니다: %v",
+		"document_gone":          "%s 문서(%s)가 없어져 건너뜁니다.",
+		"report_gone":            "목록을 만든 뒤 삭제된 문서:",
+		"docs_file_failed":       "문서 목록을 읽지 못했습니다: %v",
+		"docs_listed":            "문서 목록에서 %d개.",
+		"docs_discovered":        "역링크에서 %d개.",
+		"docs_extra":             "추가 목록에서 %d개를 더했습니다 (%d개는 이미 있음).",
+		"docs_excluded":          "제외 목록으로 %d개를 뺐습니다.",
+		"prompt_pick_namespaces": "처리할 이름공간을 입력하세요 (쉼표로 구분, 비우면 전부: %s): ",
+		"prompt_pick_moves":      "링크를 고칠 이동의 번호를 입력하세요 (쉼표로 구분, 비우면 가장 최근 것): ",
+		"move_log_item":          "%3d. %s → %s (%s, %s)",
+		"move_log_picked":        "링크를 바꿉니다: %s → %s",
+		"move_log_failed":        "이동 기록을 읽지 못했습니다: %v",
+		"move_log_empty":         "이동 기록이 비어 있습니다.",
+		"move_log_bad_pick":      "%q번 이동은 없습니다.",
+		"move_log_no_move":       "최근 이동 %[2]d건에 %[1]s 문서의 이동이 없습니다.",
+		"move_log_needs_pick":    "-from-move-log를 -yes와 함께 쓰려면 -moves로 가져올 옛 표제어를 적어야 합니다. 가장 최근 이동을 짐작해 쓰지 않습니다.",
+		"checkpoint_load_failed": "체크포인트를 읽지 못했습니다: %v",
+		"checkpoint_failed":      "체크포인트를 저장하지 못했습니다: %v",
+		"resuming":               "실행 %s을(를) 이어서 합니다 (체크포인트 저장 시각 %s).",
+		"resume_sync":            "실행 %s: 남은 문서 %d개, 그사이 고쳐진 문서 %d개, 새 문서 %d개, 이미 처리한 문서 %d개.",
+		"time_up":                "정해진 시간이 지나 문서 %d개를 남기고 멈춥니다.",
+		"metrics_push_failed":    "%s에 지표를 보내지 못했습니다: %v",
+		"skip_cache_invalid":     "건너뛰기 캐시 %s를 읽을 수 없어 무시합니다: %v",
+		"skip_cache_failed":      "건너뛰기 캐시를 저장하지 못했습니다: %v",
+		"fetch_dir_failed":       "저장할 디렉터리를 만들 수 없습니다: %v",
+		"fetch_write_failed":     "가져온 문서를 저장하지 못했습니다: %v",
+		"fetch_done":             "문서 %d개를 %s에 저장했습니다.",
+		"corpus_summary":         "로컬 편집 반영 (실행 %s)",
+		"corpus_read_failed":     "%s 파일을 읽지 못했습니다: %v",
+		"corpus_changed":         "문서 %[2]d개 중 %[1]d개가 로컬에서 바뀌었습니다.",
+		"corpus_index_failed":    "디렉터리의 index.json을 읽지 못했습니다: %v",
+		"corpus_wrong_domain":    "문서를 '%s'에서 받았습니다 ('%s' 아님). 적용하지 않습니다.",
+		"estimate_documents":     "이름공간 %[2]d개의 문서 %[1]d개가 기존 표제어를 가리킵니다:",
+		"estimate_links":         "문서 %[2]d개에서 링크 %[1]d개가 바뀝니다 (바꿀 것이 없는 문서 %[3]d개, 보호된 문서 %[4]d개).",
+		"estimate_duration":      "설정된 속도로 문서 %[2]d개를 편집하면 약 %[1]s 걸립니다.",
+		"invalid_title":          "잘못된 작업: %v",
+		"namespace_inherited":    "새 표제어 '%s'에 이름공간이 없어 기존 표제어처럼 '%s'(으)로 합니다.",
+		"resume_hint":            "이어서 하려면 -resume %s 옵션을 주어 다시 실행하세요.",
+		"manifest_written":       "실행 명세를 %s에 저장했습니다.",
+		"manifest_failed":        "실행 명세를 저장하지 못했습니다: %v",
+		"protection_checked":     "문서 %d/%d개의 편집 권한을 확인했습니다...",
+		"protection_split":       "편집할 수 있는 문서 %d개, 보호된 문서 %d개는 따로 뺐습니다.",
+		"protected_out_failed":   "보호된 문서 목록을 저장하지 못했습니다: %v",
+		"protected_written":      "보호된 문서 %d개를 %s에 적었습니다.",
+		"report_protected":       "권한 있는 계정이 처리할 보호된 문서:",
+		"report_too_large":       "너무 커서 건너뛴 문서(maxPageBytes 참고):",
+		"report_timed_out":       "시간이 너무 오래 걸린 문서(documentTimeout 참고):",
+		"privileged_retry":       "%s 문서는 보호되어 있어 %s 계정으로 다시 시도합니다.",
+		"namespaces_selected":    "처리할 이름공간: %s",
+	},
+}
+
+// initLocale picks the message language from config.ini's locale key,
+// falling back to the LANG environment variable.
+func initLocale() {
+	lang := os.Getenv("LANG")
+	if cfg, err := ini.Load("config.ini"); err == nil {
+		if l := cfg.Section("").Key("locale").String(); l != "" {
+			lang = l
+		}
+	}
+	lang, _, _ = strings.Cut(strings.ToLower(lang), "_")
+	if _, ok := catalog[lang]; ok {
+		locale = lang
+	}
+}
+
+func msg(key string, args ...any) string {
+	format, ok := catalog[locale][key]
+	if !ok {
+		format = catalog["en"][key]
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// say prints a catalog message on its own line to humanOut.
+func say(key string, args ...any) {
+	fmt.Fprintln(humanOut, msg(key, args...))
+}
+
+// warn prints a catalog message on its own line to stderr.
+func warn(key string, args ...any) {
+	fmt.Fprintln(stderr, msg(key, args...))
+}
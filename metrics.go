@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metric is one final run figure, pushed as a gauge.
+type metric struct {
+	name   string
+	status string
+	value  float64
+}
+
+// runMetrics lists the figures of the finished run: documents by status,
+// rewritten links, API calls and latency, and run duration.
+func (b *Bot) runMetrics() []metric {
+	counts := b.report.counts()
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var ms []metric
+	for _, status := range statuses {
+		ms = append(ms, metric{"documents", status, float64(counts[status])})
+	}
+	s := b.report.Stats
+	return append(ms,
+		metric{"links_rewritten", "", float64(s.Links)},
+		metric{"api_calls", "", float64(s.APICalls)},
+		metric{"api_latency_avg_seconds", "", s.AvgLatency.Seconds()},
+		metric{"run_duration_seconds", "", s.WallClock.Seconds()},
+		metric{"last_run_timestamp_seconds", "", float64(b.report.Finished.Unix())},
+	)
+}
+
+// pushMetrics sends the run's final metrics to config.ini's pushgateway
+// (a Prometheus Pushgateway URL) and statsd (host:port), for one-shot runs
+// that nothing scrapes.
+func (b *Bot) pushMetrics() {
+	sec := b.cfg.Section("")
+	gateway, statsd := sec.Key("pushgateway").String(), sec.Key("statsd").String()
+	if gateway == "" && statsd == "" || b.report.Stats == nil {
+		return
+	}
+	ms := b.runMetrics()
+	if gateway != "" {
+		if err := pushGateway(gateway, b.Domain, ms); err != nil {
+			warn("metrics_push_failed", "pushgateway", err)
+		}
+	}
+	if statsd != "" {
+		if err := pushStatsD(statsd, ms); err != nil {
+			warn("metrics_push_failed", "statsd", err)
+		}
+	}
+}
+
+// pushGateway replaces the metrics of the micro_rearalice job, grouped by
+// wiki domain, in the Pushgateway at base.
+func pushGateway(base, domain string, ms []metric) error {
+	var buf bytes.Buffer
+	typed := make(map[string]bool)
+	for _, m := range ms {
+		name := "rearalice_" + m.name
+		if !typed[name] {
+			fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+			typed[name] = true
+		}
+		if m.status != "" {
+			fmt.Fprintf(&buf, "%s{status=%q} %g\n", name, m.status, m.value)
+		} else {
+			fmt.Fprintf(&buf, "%s %g\n", name, m.value)
+		}
+	}
+	u := strings.TrimRight(base, "/") + "/metrics/job/micro_rearalice/domain/" + url.PathEscape(domain)
+	req, err := http.NewRequest(http.MethodPut, u, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// pushStatsD sends the metrics as StatsD gauges over UDP to addr.
+func pushStatsD(addr string, ms []metric) error {
+	conn, err := net.DialTimeout("udp", addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var buf bytes.Buffer
+	for _, m := range ms {
+		name := "rearalice." + m.name
+		if m.status != "" {
+			name += "." + m.status
+		}
+		fmt.Fprintf(&buf, "%s:%g|g\n", name, m.value)
+	}
+	_, err = conn.Write(buf.Bytes())
+	return err
+}
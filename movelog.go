@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// moveLogSize is how many of the latest moves -from-move-log offers.
+const moveLogSize = 20
+
+// moveLogJobs lists the wiki's latest page moves and builds a job for each
+// one the operator picks by number, so the titles are copied from the wiki
+// rather than typed. picks, when given, are the old titles whose latest
+// moves to take without asking; listing numbers are not accepted there,
+// as a move made meanwhile would shift them onto another page.
+func (b *Bot) moveLogJobs(picks []string, yes bool) []*Job {
+	moves, err := getMoves(context.Background(), b.Domain, b.Accounts.Current().Token, moveLogSize)
+	if err != nil {
+		warn("move_log_failed", err)
+		os.Exit(1)
+	}
+	if len(moves) == 0 {
+		warn("move_log_empty")
+		os.Exit(1)
+	}
+	for i, m := range moves {
+		say("move_log_item", i+1, m.From, m.To, m.Author, time.Unix(m.Date, 0).Format(time.DateTime))
+	}
+	chosen := []Move{moves[0]}
+	if len(picks) > 0 {
+		chosen = nil
+		for _, from := range picks {
+			i := slices.IndexFunc(moves, func(m Move) bool { return m.From == from })
+			if i < 0 {
+				warn("move_log_no_move", from, len(moves))
+				os.Exit(1)
+			}
+			chosen = append(chosen, moves[i])
+		}
+	} else if !yes {
+		if answer := prompt(msg("prompt_pick_moves")); answer != "" {
+			chosen = nil
+			for _, field := range parseList(answer) {
+				n, err := strconv.Atoi(field)
+				if err != nil || n < 1 || n > len(moves) {
+					warn("move_log_bad_pick", field)
+					os.Exit(1)
+				}
+				chosen = append(chosen, moves[n-1])
+			}
+		}
+	}
+	keepText := !yes && strings.ToLower(prompt(msg("prompt_keep_text"))) == "y"
+	var jobs []*Job
+	for _, m := range chosen {
+		say("move_log_picked", m.From, m.To)
+		jobs = append(jobs, newJob(m.From, m.To, keepText, b.LogTemplate))
+	}
+	return jobs
+}
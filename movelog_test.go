@@ -0,0 +1,17 @@
+package main
+
+import "testing"
+
+func TestMoveLogJobs(t *testing.T) {
+	o := newOrchard(t, map[string]string{"사과": "과일", "배": "과일", "과수원": "[[사과]] [[배]]"})
+	o.srv.Move("사과", "사과(과일)")
+	o.srv.Move("배", "배(과일)")
+
+	// 배 moved last, but only the move asked for is taken.
+	jobs := o.bot.moveLogJobs([]string{"사과"}, true)
+	if len(jobs) != 1 || jobs[0].OldTitle != "사과" || jobs[0].NewTitle != "사과(과일)" {
+		t.Fatalf("moveLogJobs = %v, want the move 사과 → 사과(과일)", jobs)
+	}
+	o.bot.editQueue(jobs, docSelection{}, "", true, "")
+	o.checkPages(t, map[string]string{"과수원": "[[사과(과일)]] [[배]]"})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// defaultNamespaces are the namespaces every the seed wiki has. Wikis with
+// more can list them all in data.ini's allNamespaces.
+var defaultNamespaces = []string{"문서", "틀", "분류", "파일", "사용자", "특수기능", "휴지통"}
+
+// discoverNamespaces counts the jobs' backlinks in every known namespace
+// and lets the operator choose which to process, replacing the bot's
+// namespace list for this run. With yes, every namespace that has
+// backlinks is chosen.
+func (b *Bot) discoverNamespaces(jobs []*Job, yes bool) {
+	all := parseList(b.data.Section("").Key("allNamespaces").String())
+	if len(all) == 0 {
+		all = defaultNamespaces
+	}
+	var found []string
+	for _, ns := range all {
+		n := 0
+		for _, job := range jobs {
+			err := streamBacklinks(context.Background(), b.Domain, b.Accounts.Current().Token, job.Page(), ns, func(docs []string) error {
+				n += len(docs)
+				return nil
+			})
+			if err != nil {
+				say("backlink_fetch_failed", ns, err)
+			}
+		}
+		if n > 0 {
+			say("namespace_count", ns, n)
+			found = append(found, ns)
+		}
+	}
+	chosen := found
+	if !yes && len(found) > 0 {
+		if answer := prompt(msg("prompt_pick_namespaces", strings.Join(found, ", "))); answer != "" {
+			chosen = parseList(answer)
+		}
+	}
+	b.Namespaces = chosen
+	say("namespaces_selected", strings.Join(chosen, ", "))
+}
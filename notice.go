@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// postNotice announces a finished rename on the old title's discussion
+// page, following data.ini's noticeTopic and noticeTemplate. When
+// noticeThread holds a thread slug the notice is posted there as a reply
+// instead of opening a new thread.
+func (b *Bot) postNotice(job *Job, edited int) {
+	sec := b.data.Section("")
+	tpl := sec.Key("noticeTemplate").String()
+	if tpl == "" || job.Via != "" {
+		return
+	}
+	r := strings.NewReplacer(
+		"{old}", job.OldTitle,
+		"{new}", job.NewTitle,
+		"{run}", b.RunID,
+		"{count}", strconv.Itoa(edited),
+	)
+	text := r.Replace(tpl)
+	topic := r.Replace(sec.Key("noticeTopic").MustString(msg("notice_default_topic")))
+
+	_, err := b.withAccount(context.Background(), func(account Account) error {
+		if slug := sec.Key("noticeThread").String(); slug != "" {
+			return replyThread(context.Background(), b.Domain, account.Token, slug, text)
+		}
+		_, err := createThread(context.Background(), b.Domain, account.Token, job.Page(), topic, text)
+		return err
+	})
+	if err != nil {
+		say("notice_failed", job.OldTitle, err)
+		return
+	}
+	say("notice_posted", job.OldTitle)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// A notifyRule sends a message to its channels when one of its events
+// happens and all of its conditions hold. Rules are [notify.NAME]
+// sections in config.ini:
+//
+//	[notify.failures]
+//	event   = finish
+//	channel = discord
+//	when    = failed > 10
+//
+// Events are finish, abort, pause and resume (see discussAction); channels
+// are email and discord. when holds
+// conditions joined by "and", each comparing a report count (edited,
+// unchanged, skipped, filtered, failed, dead) with a number.
+type notifyRule struct {
+	Name     string
+	Events   []string
+	Channels []string
+	When     []condition
+}
+
+type condition struct {
+	Metric string
+	Op     string
+	Value  int
+}
+
+func (c condition) holds(counts map[string]int) bool {
+	n := counts[c.Metric]
+	switch c.Op {
+	case ">":
+		return n > c.Value
+	case ">=":
+		return n >= c.Value
+	case "<":
+		return n < c.Value
+	case "<=":
+		return n <= c.Value
+	case "==":
+		return n == c.Value
+	case "!=":
+		return n != c.Value
+	}
+	return false
+}
+
+func parseConditions(s string) ([]condition, error) {
+	var conds []condition
+	for _, part := range strings.Split(s, " and ") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("bad condition %q", part)
+		}
+		v, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("bad condition %q", part)
+		}
+		c := condition{Metric: fields[0], Op: fields[1], Value: v}
+		if !c.validOp() {
+			return nil, fmt.Errorf("bad operator in %q", part)
+		}
+		conds = append(conds, c)
+	}
+	return conds, nil
+}
+
+func (c condition) validOp() bool {
+	switch c.Op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+// loadNotifyRules reads the [notify.NAME] sections of cfg. Without any,
+// the run report is emailed at the end of every run and when one is
+// aborted.
+func loadNotifyRules(cfg *ini.File) []notifyRule {
+	var rules []notifyRule
+	for _, sec := range cfg.Sections() {
+		name, ok := strings.CutPrefix(sec.Name(), "notify.")
+		if !ok {
+			continue
+		}
+		when, err := parseConditions(sec.Key("when").String())
+		if err != nil {
+			warn("notify_rule_invalid", name, err)
+			continue
+		}
+		rules = append(rules, notifyRule{
+			Name:     name,
+			Events:   parseList(sec.Key("event").MustString("finish")),
+			Channels: parseList(sec.Key("channel").String()),
+			When:     when,
+		})
+	}
+	if len(rules) == 0 {
+		rules = []notifyRule{{Name: "default", Events: []string{"finish", "abort"}, Channels: []string{"email"}}}
+	}
+	return rules
+}
+
+func (r notifyRule) matches(event string, counts map[string]int) bool {
+	found := false
+	for _, e := range r.Events {
+		found = found || e == event
+	}
+	if !found {
+		return false
+	}
+	for _, c := range r.When {
+		if !c.holds(counts) {
+			return false
+		}
+	}
+	return true
+}
+
+// notify runs the notification rules for event. reason explains an abort
+// and is empty otherwise. Each channel is notified at most once per event.
+func (b *Bot) notify(event, reason string) {
+	counts := b.report.counts()
+	sent := make(map[string]bool)
+	for _, rule := range b.notifyRules {
+		if !rule.matches(event, counts) {
+			continue
+		}
+		for _, ch := range rule.Channels {
+			if sent[ch] {
+				continue
+			}
+			sent[ch] = true
+			subject, body := b.notifyMessage(event, reason, counts)
+			switch ch {
+			case "email":
+				b.mailReport(subject, body)
+			case "discord":
+				b.postDiscord(subject + "\n" + body)
+			default:
+				warn("notify_unknown_channel", rule.Name, ch)
+			}
+		}
+	}
+}
+
+func (b *Bot) notifyMessage(event, reason string, counts map[string]int) (string, string) {
+	subjectKey := "mail_subject_done"
+	switch {
+	case event == "abort":
+		subjectKey = "mail_subject_aborted"
+	case event == "pause":
+		subjectKey = "mail_subject_paused"
+	case event == "resume":
+		subjectKey = "mail_subject_resumed"
+	case counts[statusFailed]+counts[statusDead] > 0:
+		subjectKey = "mail_subject_failed"
+	}
+	var body strings.Builder
+	if reason != "" {
+		fmt.Fprintln(&body, reason)
+	} else {
+		fmt.Fprintln(&body, msg("mail_intro", b.Domain, b.RunID))
+	}
+	fmt.Fprintln(&body, msg("report_summary", counts[statusEdited], counts[statusUnchanged], counts[statusSkipped], counts[statusFailed], counts[statusDead]))
+	if s := b.report.Stats; s != nil {
+		fmt.Fprintln(&body, msg("stats_links", s.Links))
+		fmt.Fprintln(&body, msg("stats_timing", s.APICalls, s.AvgLatency.Round(time.Millisecond), s.WallClock.Round(time.Second)))
+	}
+	for _, res := range b.report.withStatus(statusDead) {
+		fmt.Fprintln(&body, msg("report_item", res.Document, res.Error))
+	}
+	return msg(subjectKey, b.RunID), body.String()
+}
+
+// postDiscord sends text to the Discord webhook in config.ini's
+// discordWebhook.
+func (b *Bot) postDiscord(text string) {
+	hook := b.cfg.Section("").Key("discordWebhook").String()
+	if hook == "" {
+		warn("notify_no_webhook")
+		return
+	}
+	// Discord rejects messages longer than 2000 characters.
+	if r := []rune(text); len(r) > 2000 {
+		text = string(r[:1997]) + "..."
+	}
+	data, _ := json.Marshal(map[string]string{"content": text})
+	resp, err := httpClient.Post(hook, "application/json", bytes.NewReader(data))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+	}
+	if err != nil {
+		warn("discord_failed", err)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestDefaultNotifyRule(t *testing.T) {
+	rules := loadNotifyRules(ini.Empty())
+	for _, event := range []string{"finish", "abort"} {
+		if len(rules) != 1 || !rules[0].matches(event, nil) {
+			t.Errorf("default rules %+v do not mail on %s", rules, event)
+		}
+	}
+}
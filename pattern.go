@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// expandPatterns replaces every job whose old title contains "*" with one
+// job per existing title matching it. Each "*" in the new title takes the
+// text matched by the "*" at the same position in the old title, so
+// "Old Series/Episode *" → "New Series/Episode *" renames every episode.
+func (b *Bot) expandPatterns(jobs []*Job) ([]*Job, error) {
+	var expanded []*Job
+	for _, job := range jobs {
+		if !strings.Contains(job.OldTitle, "*") {
+			expanded = append(expanded, job)
+			continue
+		}
+		n := strings.Count(job.OldTitle, "*")
+		if strings.Count(job.NewTitle, "*") != n {
+			return nil, fmt.Errorf("%q and %q must contain the same number of '*'", job.OldTitle, job.NewTitle)
+		}
+		re := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(job.OldTitle), `\*`, "(.*)") + "$")
+		titles, err := b.titlesMatching(job.OldTitle)
+		if err != nil {
+			return nil, err
+		}
+		matched := 0
+		for _, title := range titles {
+			m := re.FindStringSubmatch(title)
+			if m == nil {
+				continue
+			}
+			parts := strings.Split(job.NewTitle, "*")
+			var sb strings.Builder
+			for i, part := range parts {
+				sb.WriteString(part)
+				if i < n {
+					sb.WriteString(m[i+1])
+				}
+			}
+			expanded = append(expanded, newJob(title, sb.String(), job.KeepText, b.LogTemplate).inherit(job))
+			matched++
+		}
+		say("pattern_expanded", job.OldTitle, matched)
+	}
+	return expanded, nil
+}
+
+// titlesMatching lists the titles sharing pattern's literal prefix, the
+// text before its first "*".
+func (b *Bot) titlesMatching(pattern string) ([]string, error) {
+	prefix, _, _ := strings.Cut(pattern, "*")
+	ns, rest, hasNS := strings.Cut(prefix, ":")
+	if !hasNS {
+		ns, rest = namespaceOf(prefix), prefix
+	}
+	titles, err := getTitlesByPrefix(context.Background(), b.Domain, b.Accounts.Current().Token, ns, rest)
+	if err != nil {
+		return nil, err
+	}
+	if hasNS {
+		for i, t := range titles {
+			titles[i] = ns + ":" + t
+		}
+	}
+	return titles, nil
+}
+
+// expandSubpages adds a job moving each existing subpage of a job's old
+// title under the new title ("Old/Sub" → "New/Sub").
+func (b *Bot) expandSubpages(jobs []*Job) ([]*Job, error) {
+	expanded := jobs
+	for _, job := range jobs {
+		if job.Via != "" {
+			continue
+		}
+		pattern := newJob(job.OldTitle+"/*", job.NewTitle+"/*", job.KeepText, b.LogTemplate).inherit(job)
+		subs, err := b.expandPatterns([]*Job{pattern})
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range subs {
+			sub.Via = job.OldTitle
+		}
+		expanded = append(expanded, subs...)
+	}
+	return expanded, nil
+}
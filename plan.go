@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+type Plan struct {
+	RunID     string      `json:"run_id"`
+	Domain    string      `json:"domain"`
+	OldTitle  string      `json:"old_title"`
+	NewTitle  string      `json:"new_title"`
+	Created   time.Time   `json:"created"`
+	Entries   []PlanEntry `json:"entries"`
+	Signature string      `json:"signature,omitempty"`
+}
+
+type PlanEntry struct {
+	Document string    `json:"document"`
+	BaseHash string    `json:"base_rev"`
+	Fetched  time.Time `json:"fetched"`
+	Text     string    `json:"text"`
+	Summary  string    `json:"summary"`
+	Diff     string    `json:"diff"`
+}
+
+func runPlan(fs *flag.FlagSet) func() {
+	out := fs.String("o", "plan.json", "file to write the plan to")
+	return func() {
+
+		bot := loadBot()
+		job := promptJob(bot.LogTemplate)
+		docs, _ := bot.collectBacklinks(job.Page())
+		say("plan_found_backlinks", len(docs))
+
+		plan := &Plan{RunID: bot.RunID, Domain: bot.Domain, OldTitle: job.OldTitle, NewTitle: job.NewTitle, Created: time.Now()}
+		for idx, doc := range docs {
+			var page *Page
+			_, err := bot.withAccount(context.Background(), func(account Account) (err error) {
+				page, err = getPageContent(context.Background(), bot.Domain, account.Token, doc)
+				return err
+			})
+			if err != nil {
+				say("fetch_failed", doc, idx+1, len(docs), err)
+				continue
+			}
+			res := job.Rewrite(page.Text)
+			if res.Changes == 0 {
+				continue
+			}
+			entry := PlanEntry{
+				Document: doc,
+				BaseHash: page.Hash,
+				Fetched:  page.Fetched,
+				Text:     res.Text,
+				Summary:  job.Summary(doc, res),
+				Diff:     lineDiff(page.Text, res.Text),
+			}
+			plan.Entries = append(plan.Entries, entry)
+			fmt.Fprintf(stdout, "=== %s\n%s", doc, entry.Diff)
+		}
+
+		plan.Signature = plan.sign(bot.planKey())
+		data, _ := json.MarshalIndent(plan, "", "  ")
+		if err := writeArtifact(*out, data, 0o600); err != nil {
+			warn("plan_write_failed", err)
+			os.Exit(1)
+		}
+		say("plan_written", len(plan.Entries), *out, *out)
+	}
+}
+
+func runApply(fs *flag.FlagSet) func() {
+	summary := fs.String("summary", "", "edit summary when applying a fetched directory (defaults to one naming the run)")
+	return func() {
+		if fs.NArg() != 1 {
+			warn("apply_usage")
+			os.Exit(2)
+		}
+
+		bot := loadBot()
+		bot.requireToken("apply")
+		if info, err := os.Stat(fs.Arg(0)); err == nil && info.IsDir() {
+			bot.applyCorpus(fs.Arg(0), *summary)
+			return
+		}
+		data, err := readArtifact(fs.Arg(0))
+		if err != nil {
+			warn("plan_read_failed", err)
+			os.Exit(1)
+		}
+		var plan Plan
+		if err := json.Unmarshal(data, &plan); err != nil {
+			warn("plan_parse_failed", err)
+			os.Exit(1)
+		}
+		if !hmac.Equal([]byte(plan.Signature), []byte(plan.sign(bot.planKey()))) {
+			warn("plan_bad_signature")
+			os.Exit(1)
+		}
+		if plan.Domain != bot.Domain {
+			warn("plan_wrong_domain", plan.Domain, bot.Domain)
+			os.Exit(1)
+		}
+		bot.watchDiscuss()
+		say("plan_applying", plan.RunID)
+
+		total := len(plan.Entries)
+		for idx, entry := range plan.Entries {
+			account, err := bot.withAccount(context.Background(), func(account Account) error {
+				page, err := getPageContent(context.Background(), bot.Domain, account.Token, entry.Document)
+				if err != nil {
+					return err
+				}
+				if page.Hash != entry.BaseHash {
+					return fmt.Errorf("%w (planned from revision fetched at %s)", ErrPageChanged, entry.Fetched.Format(time.DateTime))
+				}
+				_, err = updatePageContent(context.Background(), bot.Domain, account.Token, entry.Document, entry.Text, page.Token, entry.Summary)
+				return err
+			})
+			if err != nil {
+				say("apply_failed", entry.Document, idx+1, total, err)
+				continue
+			}
+			say("apply_updated", entry.Document, idx+1, total, account.Name)
+			bot.limiter.Wait()
+		}
+	}
+}
+
+func (p *Plan) sign(key []byte) string {
+	unsigned := *p
+	unsigned.Signature = ""
+	data, _ := json.Marshal(unsigned)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// planKey returns the HMAC key used to sign plans, generating and saving
+// one to config.ini on first use.
+func (b *Bot) planKey() []byte {
+	key := b.cfg.Section("").Key("planKey")
+	if key.String() == "" {
+		buf := make([]byte, 32)
+		rand.Read(buf)
+		key.SetValue(hex.EncodeToString(buf))
+		b.cfg.SaveTo("config.ini")
+	}
+	return []byte(key.String())
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
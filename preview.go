@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runPreview rewrites one document in memory and prints the diff, without
+// editing anything.
+func runPreview(fs *flag.FlagSet) func() {
+	batch := fs.String("batch", "", "ini file listing the rename jobs to apply")
+	return func() {
+		if fs.NArg() != 1 {
+			warn("preview_usage")
+			os.Exit(2)
+		}
+		doc := fs.Arg(0)
+
+		bot := loadBot()
+		var jobs []*Job
+		if *batch != "" {
+			var err error
+			if jobs, err = loadBatch(*batch, bot.LogTemplate); err != nil {
+				warn("batch_load_failed", err)
+				os.Exit(1)
+			}
+		} else {
+			jobs = []*Job{promptJob(bot.LogTemplate)}
+		}
+
+		if err := bot.previewDocument(doc, jobs); err != nil {
+			warn("preview_fetch_failed", doc, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// previewDocument fetches doc, applies jobs in memory and prints the
+// summary and diff.
+func (b *Bot) previewDocument(doc string, jobs []*Job) error {
+	var page *Page
+	_, err := b.withAccount(context.Background(), func(account Account) (err error) {
+		page, err = getPageContent(context.Background(), b.Domain, account.Token, doc)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	text, summary, links, _ := rewriteAll(jobs, doc, page.Text)
+	if links == 0 {
+		say("preview_no_changes", doc)
+		return nil
+	}
+	printBlock(humanOut, func(w io.Writer) {
+		fmt.Fprintln(w, msg("preview_summary", doc, links, summary))
+		fmt.Fprint(w, lineDiff(page.Text, text))
+	})
+	return nil
+}
+
+// confirmSafeMode previews the first b.safeMode documents of the queue
+// and asks the operator to confirm their diffs before the run goes live.
+// Nothing is saved before the answer; the previewed documents are then
+// edited along with the rest.
+func (b *Bot) confirmSafeMode(docs []string, docJobs map[string][]*Job) bool {
+	n := min(b.safeMode, len(docs))
+	say("safe_mode_preview", n)
+	for _, doc := range docs[:n] {
+		if err := b.previewDocument(doc, docJobs[doc]); err != nil {
+			warn("preview_fetch_failed", doc, err)
+		}
+	}
+	return strings.ToLower(prompt(msg("prompt_safe_mode", n))) == "y"
+}
+
+// previewSample previews up to n randomly chosen documents from each
+// namespace of the run, as a check before editing everything.
+func (b *Bot) previewSample(jobs []*Job, sel docSelection, n int) {
+	docs, docJobs, _ := b.resolveDocuments(jobs, sel)
+	byNamespace := make(map[string][]string)
+	for _, doc := range docs {
+		ns := namespaceOf(doc)
+		byNamespace[ns] = append(byNamespace[ns], doc)
+	}
+	namespaces := make([]string, 0, len(byNamespace))
+	for ns := range byNamespace {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		list := byNamespace[ns]
+		rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+		say("sample_namespace", ns, min(n, len(list)), len(list))
+		for _, doc := range list[:min(n, len(list))] {
+			if err := b.previewDocument(doc, docJobs[doc]); err != nil {
+				warn("preview_fetch_failed", doc, err)
+			}
+		}
+	}
+}
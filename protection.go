@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// splitProtected checks up front which documents the bot's account may
+// edit, counting the privileged account when it handles protected
+// documents. Protected ones are taken out of the queue and recorded in the
+// report as protected, and listed one per line in b.protectedOut when set,
+// so a privileged account can edit them with -docs-file.
+func (b *Bot) splitProtected(docs []string) []string {
+	var editable, protected []string
+	for idx, doc := range docs {
+		_, err := b.withAccountFor(context.Background(), doc, func(account Account) error {
+			_, err := getPageContent(context.Background(), b.Domain, account.Token, doc)
+			return err
+		})
+		if errors.Is(err, ErrPermDenied) {
+			protected = append(protected, doc)
+			b.report.record(doc, statusProtected, b.Accounts.Current().Name, err)
+			if b.checkpoint != nil {
+				b.checkpoint.markDone(doc)
+			}
+		} else {
+			editable = append(editable, doc)
+		}
+		if (idx+1)%100 == 0 {
+			say("protection_checked", idx+1, len(docs))
+		}
+	}
+	say("protection_split", len(editable), len(protected))
+	if b.protectedOut != "" && len(protected) > 0 {
+		if err := writeArtifact(b.protectedOut, []byte(strings.Join(protected, "\n")+"\n"), 0o644); err != nil {
+			warn("protected_out_failed", err)
+		} else {
+			say("protected_written", len(protected), b.protectedOut)
+		}
+	}
+	return editable
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// expandRedirects adds a job for every redirect that leads to a job's old
+// title, following redirects to redirects up to depth levels, so pages
+// linking through them are pointed straight at the new title. A title is
+// visited once, which also stops redirect cycles.
+func (b *Bot) expandRedirects(jobs []*Job, depth int) []*Job {
+	if depth <= 0 {
+		return jobs
+	}
+	visited := make(map[string]bool)
+	for _, job := range jobs {
+		visited[job.Page()] = true
+	}
+	expanded := jobs
+	for _, job := range jobs {
+		_, anchor, _ := strings.Cut(job.OldTitle, "#")
+		frontier := []string{job.Page()}
+		for d := 1; d <= depth && len(frontier) > 0; d++ {
+			var next []string
+			for _, title := range frontier {
+				for _, r := range b.redirectsTo(title) {
+					if visited[r] || r == job.NewTitle {
+						continue
+					}
+					visited[r] = true
+					next = append(next, r)
+					old := r
+					if anchor != "" {
+						old += "#" + anchor
+					}
+					derived := newJob(old, job.NewTitle, job.KeepText, b.LogTemplate).inherit(job)
+					derived.Via = title
+					expanded = append(expanded, derived)
+					say("redirect_found", r, title, d)
+				}
+			}
+			frontier = next
+		}
+	}
+	return expanded
+}
+
+// redirectsTo lists the documents in the bot's namespaces that redirect
+// to title.
+func (b *Bot) redirectsTo(title string) []string {
+	var redirects []string
+	for _, ns := range b.Namespaces {
+		err := listBacklinks(context.Background(), b.Domain, b.Accounts.Current().Token, title, ns, func(page []Backlink) error {
+			for _, bl := range page {
+				if bl.Flags == "redirect" {
+					redirects = append(redirects, bl.Document)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			say("backlink_fetch_failed", ns, err)
+		}
+	}
+	return redirects
+}
+
+// createRedirects saves "#redirect New" to each requested job's old title
+// when that page is empty or missing; namumark redirects take the bare
+// title, not a link. Pages with content are left alone.
+func (b *Bot) createRedirects(jobs []*Job) {
+	for _, job := range jobs {
+		if job.Via != "" || strings.Contains(job.OldTitle, "#") {
+			continue
+		}
+		_, err := b.withAccount(context.Background(), func(account Account) error {
+			page, err := getPageContent(context.Background(), b.Domain, account.Token, job.OldTitle)
+			if errors.Is(err, ErrGone) {
+				page, err = &Page{Title: job.OldTitle}, nil
+			}
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(page.Text) != "" {
+				say("redirect_exists", job.OldTitle)
+				return nil
+			}
+			_, err = updatePageContent(context.Background(), b.Domain, account.Token, job.OldTitle,
+				"#redirect "+job.NewTitle, page.Token, msg("redirect_summary", job.NewTitle, b.RunID))
+			if err == nil {
+				say("redirect_created", job.OldTitle, job.NewTitle)
+			}
+			return err
+		})
+		if err != nil {
+			say("redirect_failed", job.OldTitle, err)
+		}
+	}
+}
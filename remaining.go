@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Mentions counts where a title is still mentioned in a document, by why
+// the bot left it: links it was told not to touch (sections, conditions,
+// keepText), plain text, template arguments and literal text ({{{...}}}
+// blocks and ## comments).
+type Mentions struct {
+	Linked   int `json:"linked"`
+	Text     int `json:"text"`
+	Template int `json:"template"`
+	Code     int `json:"code"`
+}
+
+func (m Mentions) total() int { return m.Linked + m.Text + m.Template + m.Code }
+
+func (m *Mentions) add(o Mentions) {
+	m.Linked += o.Linked
+	m.Text += o.Text
+	m.Template += o.Template
+	m.Code += o.Code
+}
+
+// countMentions scans namumark text for title with scanNamumark, as
+// astMatcher does. Links and includes of other pages don't count, even
+// when their target contains title, but their arguments do.
+func countMentions(text, title string) Mentions {
+	var m Mentions
+	if title == "" {
+		return m
+	}
+	scanNamumark(text, func(tok namuToken, i, end int) int {
+		rest := text[i:]
+		switch tok {
+		case namuLiteral, namuText:
+			if !strings.HasPrefix(rest, title) {
+				return end
+			}
+			if tok == namuLiteral {
+				m.Code++
+			} else {
+				m.Text++
+			}
+			return i + len(title)
+		case namuComment:
+			m.Code += strings.Count(text[i:end], title)
+		case namuLink:
+			target, _, _ := strings.Cut(text[i+2:end-2], "|")
+			target, _, _ = strings.Cut(target, "#")
+			if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(target), ":")) == title {
+				m.Linked++
+			}
+		case namuInclude:
+			n := strings.Index(rest, ")]")
+			if n < 0 {
+				return end
+			}
+			name, args, _ := strings.Cut(rest[9:n], ",")
+			if strings.TrimSpace(name) == title {
+				m.Linked++
+			}
+			m.Template += strings.Count(args, title)
+			return i + n + 2
+		}
+		return end
+	})
+	return m
+}
+
+// A RemainingLog collects, for -remaining, the old titles' mentions left
+// in every document once the run is done with it, so people know what
+// cleanup remains and where.
+type RemainingLog struct {
+	path string
+	mu   sync.Mutex
+	docs map[string]Mentions
+}
+
+func newRemainingLog(path string) *RemainingLog {
+	return &RemainingLog{path: path, docs: make(map[string]Mentions)}
+}
+
+// note counts the jobs' old titles in doc's final text.
+func (l *RemainingLog) note(doc string, jobs []*Job, text string) {
+	if l == nil {
+		return
+	}
+	var m Mentions
+	for _, job := range jobs {
+		m.add(countMentions(text, job.OldTitle))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if m.total() == 0 {
+		delete(l.docs, doc)
+		return
+	}
+	l.docs[doc] = m
+}
+
+// finish prints the mentions left by category and writes one CSV row per
+// document, the documents with the most mentions first.
+func (l *RemainingLog) finish() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var sum Mentions
+	docs := make([]string, 0, len(l.docs))
+	for doc, m := range l.docs {
+		sum.add(m)
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		a, b := l.docs[docs[i]].total(), l.docs[docs[j]].total()
+		return a > b || a == b && docs[i] < docs[j]
+	})
+	say("remaining_summary", len(docs), sum.Linked, sum.Text, sum.Template, sum.Code)
+
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"document", "namespace", "linked", "text", "template", "code", "total"})
+	for _, doc := range docs {
+		m := l.docs[doc]
+		w.Write([]string{doc, namespaceOf(doc), strconv.Itoa(m.Linked), strconv.Itoa(m.Text), strconv.Itoa(m.Template), strconv.Itoa(m.Code), strconv.Itoa(m.total())})
+	}
+	w.Flush()
+	err := w.Error()
+	if err == nil {
+		err = writeArtifact(l.path, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		warn("report_write_failed", err)
+		return
+	}
+	say("report_written", l.path)
+}
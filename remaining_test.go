@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCountMentions(t *testing.T) {
+	text := "[[사과(과일)]]과 [[사과#역사|사과]]는 사과이다.\n" +
+		"[include(틀:과일, 이름=사과)]\n" +
+		"{{{#!syntax go\n사과 := 1}}} {{{#!wiki 사과}}}\n" +
+		"## 사과 주석"
+	want := Mentions{Linked: 1, Text: 2, Template: 1, Code: 2}
+	if got := countMentions(text, "사과"); got != want {
+		t.Errorf("countMentions = %+v, want %+v", got, want)
+	}
+}
+func TestEditQueueReportsRemainingMentions(t *testing.T) {
+	o := newOrchard(t, map[string]string{
+		"과수원": "[[사과]]를 기른다. 사과는 맛있다.",
+		"요리":  "{{{[[사과]]}}}",
+	})
+	path := t.TempDir() + "/remaining.csv"
+	o.bot.remaining = newRemainingLog(path)
+
+	o.run()
+	o.bot.remaining.finish()
+	if got, want := o.bot.remaining.docs["과수원"], (Mentions{Text: 1}); got != want {
+		t.Errorf("과수원 mentions = %+v, want %+v", got, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "과수원,문서,0,1,0,0,1") {
+		t.Errorf("remaining CSV lacks 과수원's row:\n%s", data)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	statusEdited    = "edited"
+	statusUnchanged = "unchanged"
+	statusSkipped   = "skipped"
+	statusFiltered  = "filtered"
+	statusReview    = "review"
+	statusGone      = "gone"
+	statusProtected = "protected"
+	statusTooLarge  = "too_large"
+	statusTimedOut  = "timed_out"
+	statusFailed    = "failed"
+	statusDead      = "dead"
+)
+
+type DocResult struct {
+	Document string `json:"document"`
+	Status   string `json:"status"`
+	Account  string `json:"account,omitempty"`
+	Attempts int    `json:"attempts"`
+	Links    int    `json:"links,omitempty"`
+	Rev      int    `json:"rev,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type Report struct {
+	RunID       string      `json:"run_id"`
+	Started     time.Time   `json:"started"`
+	Finished    time.Time   `json:"finished"`
+	Results     []DocResult `json:"results"`
+	DeadLetters []string    `json:"dead_letters,omitempty"`
+	Stats       *RunStats   `json:"stats,omitempty"`
+
+	mu    sync.Mutex
+	index map[string]int
+}
+
+func newReport(runID string) *Report {
+	return &Report{RunID: runID, Started: time.Now(), index: make(map[string]int)}
+}
+
+// record stores the outcome of one attempt at doc.
+func (r *Report) record(doc, status, account string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.index[doc]
+	if !ok {
+		i = len(r.Results)
+		r.index[doc] = i
+		r.Results = append(r.Results, DocResult{Document: doc})
+	}
+	res := &r.Results[i]
+	res.Status = status
+	res.Account = account
+	res.Attempts++
+	res.Error = ""
+	if err != nil {
+		res.Error = err.Error()
+	}
+}
+
+// setEdit stores the links changed and the saved revision of an edited doc.
+func (r *Report) setEdit(doc string, links, rev int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := &r.Results[r.index[doc]]
+	res.Links = links
+	res.Rev = rev
+}
+
+// result returns doc's result so far.
+func (r *Report) result(doc string) (DocResult, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.index[doc]; ok {
+		return r.Results[i], true
+	}
+	return DocResult{}, false
+}
+
+func (r *Report) attempts(doc string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.index[doc]; ok {
+		return r.Results[i].Attempts
+	}
+	return 0
+}
+
+func (r *Report) markDead(doc string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Results[r.index[doc]].Status = statusDead
+	r.DeadLetters = append(r.DeadLetters, doc)
+}
+
+func (r *Report) counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int)
+	for _, res := range r.Results {
+		counts[res.Status]++
+	}
+	return counts
+}
+
+// reportGroups are the statuses finish lists documents for after the
+// summary, in order, with the heading of each list.
+var reportGroups = []struct {
+	status, heading string
+}{
+	{statusFiltered, "report_filtered"},
+	{statusGone, "report_gone"},
+	{statusProtected, "report_protected"},
+	{statusTooLarge, "report_too_large"},
+	{statusTimedOut, "report_timed_out"},
+	{statusReview, "report_review"},
+	{statusDead, "report_dead_letters"},
+}
+
+// finish prints the run summary and, when path is set, saves the report
+// there as JSON. csvPath likewise saves the per-document results as CSV.
+func (r *Report) finish(path, csvPath string) {
+	r.Finished = time.Now()
+	counts := r.counts()
+	say("report_summary", counts[statusEdited], counts[statusUnchanged], counts[statusSkipped], counts[statusFailed], counts[statusDead])
+	for _, group := range reportGroups {
+		if counts[group.status] == 0 {
+			continue
+		}
+		say(group.heading)
+		for _, res := range r.withStatus(group.status) {
+			say("report_item", res.Document, res.Error)
+		}
+	}
+	r.Stats = r.stats()
+	r.Stats.print()
+	if csvPath != "" {
+		if err := r.writeCSV(csvPath); err != nil {
+			warn("report_write_failed", err)
+		} else {
+			say("report_written", csvPath)
+		}
+	}
+	if path == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(r, "", "  ")
+	if err := writeArtifact(path, data, 0o644); err != nil {
+		warn("report_write_failed", err)
+		return
+	}
+	say("report_written", path)
+}
+
+func (r *Report) withStatus(status string) []DocResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []DocResult
+	for _, res := range r.Results {
+		if res.Status == status {
+			list = append(list, res)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Document < list[j].Document })
+	return list
+}
+
+// writeCSV saves one row per document for review in a spreadsheet.
+func (r *Report) writeCSV(path string) error {
+	var buf bytes.Buffer
+	// A byte order mark makes spreadsheet programs read the file as UTF-8.
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"document", "namespace", "links", "status", "revision", "error"})
+	r.mu.Lock()
+	for _, res := range r.Results {
+		rev := ""
+		if res.Rev > 0 {
+			rev = strconv.Itoa(res.Rev)
+		}
+		w.Write([]string{res.Document, namespaceOf(res.Document), strconv.Itoa(res.Links), res.Status, rev, res.Error})
+	}
+	r.mu.Unlock()
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeArtifact(path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/ini.v1"
+)
+
+// reportChange is a document whose status differs between two runs. An
+// empty From or To means the run did not touch the document.
+type reportChange struct {
+	Document string `json:"document"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReportDiff compares run B's results with run A's.
+type ReportDiff struct {
+	A       string         `json:"run_a"`
+	B       string         `json:"run_b"`
+	CountsA map[string]int `json:"counts_a"`
+	CountsB map[string]int `json:"counts_b"`
+	Same    int            `json:"same"`
+	Changes []reportChange `json:"changes"`
+}
+
+// diffReports lists the documents whose status differs between a and b,
+// in a's order and then b's.
+func diffReports(a, b *Report) *ReportDiff {
+	d := &ReportDiff{A: a.RunID, B: b.RunID, CountsA: make(map[string]int), CountsB: make(map[string]int)}
+	inB := make(map[string]DocResult)
+	for _, res := range b.Results {
+		inB[res.Document] = res
+		d.CountsB[res.Status]++
+	}
+	inA := make(map[string]bool)
+	for _, res := range a.Results {
+		inA[res.Document] = true
+		d.CountsA[res.Status]++
+		other, ok := inB[res.Document]
+		switch {
+		case !ok:
+			d.Changes = append(d.Changes, reportChange{Document: res.Document, From: res.Status})
+		case other.Status == res.Status:
+			d.Same++
+		default:
+			d.Changes = append(d.Changes, reportChange{Document: res.Document, From: res.Status, To: other.Status, Error: other.Error})
+		}
+	}
+	for _, res := range b.Results {
+		if !inA[res.Document] {
+			d.Changes = append(d.Changes, reportChange{Document: res.Document, To: res.Status, Error: res.Error})
+		}
+	}
+	return d
+}
+
+// transitions groups the changes by from and to status, most common first.
+func (d *ReportDiff) transitions() [][]reportChange {
+	groups := make(map[[2]string][]reportChange)
+	var keys [][2]string
+	for _, c := range d.Changes {
+		k := [2]string{c.From, c.To}
+		if groups[k] == nil {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], c)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return len(groups[keys[i]]) > len(groups[keys[j]]) })
+	var out [][]reportChange
+	for _, k := range keys {
+		out = append(out, groups[k])
+	}
+	return out
+}
+
+// reportPath resolves a run argument of report diff: a report file, a run
+// directory, or the ID of a run under runsDir.
+func reportPath(data *ini.File, arg string) string {
+	if _, err := os.Stat(arg); err == nil && !isDir(arg) {
+		return arg
+	}
+	if isDir(arg) {
+		return filepath.Join(arg, "report.json")
+	}
+	return filepath.Join(runsRoot(data), arg, "report.json")
+}
+
+// runReport compares two runs' results: report diff <run-a> <run-b>.
+func runReport(fs *flag.FlagSet) func() {
+	output := fs.String("output", "text", "output format: text or json")
+	return func() {
+		action := fs.Arg(0)
+		if fs.NArg() > 0 {
+			fs.Parse(fs.Args()[1:])
+		}
+		if action != "diff" || fs.NArg() != 2 {
+			warn("report_diff_usage")
+			os.Exit(2)
+		}
+		data, err := ini.Load("data.ini")
+		if err != nil {
+			data = ini.Empty()
+		}
+		if cfg, err := ini.Load("config.ini"); err == nil {
+			loadArtifactKey(cfg)
+		}
+		var reports [2]*Report
+		for i, arg := range fs.Args() {
+			reports[i] = &Report{}
+			if err := readJSON(reportPath(data, arg), reports[i]); err != nil {
+				warn("report_read_failed", arg, err)
+				os.Exit(1)
+			}
+			if reports[i].RunID == "" {
+				reports[i].RunID = arg
+			}
+		}
+		d := diffReports(reports[0], reports[1])
+
+		if *output == "json" {
+			out, _ := json.MarshalIndent(d, "", "  ")
+			fmt.Fprintf(stdout, "%s\n", out)
+			return
+		}
+		say("report_diff_header", d.A, d.B, len(d.Changes), d.Same)
+		statuses := make(map[string]bool)
+		for s := range d.CountsA {
+			statuses[s] = true
+		}
+		for s := range d.CountsB {
+			statuses[s] = true
+		}
+		var names []string
+		for s := range statuses {
+			names = append(names, s)
+		}
+		sort.Strings(names)
+		for _, s := range names {
+			fmt.Fprintf(stdout, "  %-10s %5d → %5d (%+d)\n", s, d.CountsA[s], d.CountsB[s], d.CountsB[s]-d.CountsA[s])
+		}
+		for _, group := range d.transitions() {
+			say("report_diff_group", statusLabel(group[0].From), statusLabel(group[0].To), len(group))
+			for _, c := range group {
+				if c.Error != "" {
+					say("report_item", c.Document, c.Error)
+				} else {
+					say("report_diff_item", c.Document)
+				}
+			}
+		}
+	}
+}
+
+// statusLabel is status, or a note that the run did not touch the
+// document when it is empty.
+func statusLabel(status string) string {
+	if status == "" {
+		return msg("report_diff_absent")
+	}
+	return status
+}
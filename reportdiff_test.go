@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestReportDiff(t *testing.T) {
+	a := &Report{RunID: "a", Results: []DocResult{
+		{Document: "과수원", Status: statusEdited},
+		{Document: "사과나무", Status: statusProtected, Error: "protected"},
+		{Document: "배", Status: statusFailed},
+	}}
+	b := &Report{RunID: "b", Results: []DocResult{
+		{Document: "과수원", Status: statusEdited},
+		{Document: "사과나무", Status: statusEdited},
+		{Document: "복숭아", Status: statusSkipped},
+	}}
+	d := diffReports(a, b)
+	want := []reportChange{
+		{Document: "사과나무", From: statusProtected, To: statusEdited},
+		{Document: "배", From: statusFailed},
+		{Document: "복숭아", To: statusSkipped},
+	}
+	if d.Same != 1 || !slices.Equal(d.Changes, want) {
+		t.Errorf("diffReports = %d same, %+v; want 1 same, %+v", d.Same, d.Changes, want)
+	}
+	if d.CountsA[statusEdited] != 1 || d.CountsB[statusEdited] != 2 {
+		t.Errorf("counts = %v, %v", d.CountsA, d.CountsB)
+	}
+
+	root := t.TempDir()
+	data := ini.Empty()
+	data.Section("").Key("runsDir").SetValue(root)
+	if got, want := reportPath(data, "20240101-run"), root+"/20240101-run/report.json"; got != want {
+		t.Errorf("reportPath = %s, want %s", got, want)
+	}
+}
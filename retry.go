@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// retryPolicy says how doRequest retries a request that failed in
+// transit or got one of Statuses back: up to Attempts tries in all,
+// waiting Base, then twice that, up to Cap, or as long as a Retry-After
+// header asks within Cap. Timeouts are not retried; the document is.
+// Neither are writeOps.
+type retryPolicy struct {
+	Attempts int
+	Base     time.Duration
+	Cap      time.Duration
+	Statuses []int
+}
+
+var httpRetry = retryPolicy{
+	Attempts: 3,
+	Base:     time.Second,
+	Cap:      30 * time.Second,
+	Statuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// writeOps are the operations that change the wiki. One that failed may
+// still have gone through, so doRequest never repeats it: a second save
+// could land over an edit made since, and a second post duplicates the
+// thread or comment. A failed save is retried with its document, which is
+// fetched again first.
+var writeOps = map[string]bool{"save": true, "create_thread": true, "reply_thread": true}
+
+// loadRetryPolicy reads config.ini's [retry] section: attempts, base, cap
+// and statuses (comma-separated HTTP status codes).
+func loadRetryPolicy(cfg *ini.File) {
+	sec := cfg.Section("retry")
+	httpRetry.Attempts = max(1, sec.Key("attempts").MustInt(httpRetry.Attempts))
+	httpRetry.Base = sec.Key("base").MustDuration(httpRetry.Base)
+	httpRetry.Cap = sec.Key("cap").MustDuration(httpRetry.Cap)
+	if sec.HasKey("statuses") {
+		httpRetry.Statuses = nil
+		for _, s := range parseList(sec.Key("statuses").String()) {
+			code, err := strconv.Atoi(s)
+			if err != nil {
+				warn("retry_status_invalid", s)
+				continue
+			}
+			httpRetry.Statuses = append(httpRetry.Statuses, code)
+		}
+	}
+}
+
+// wait returns how long to wait before try attempt+1 after resp and err,
+// and false when the request should not be tried again.
+func (p retryPolicy) wait(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= p.Attempts {
+		return 0, false
+	}
+	switch {
+	case err != nil:
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return 0, false
+		}
+	case !slices.Contains(p.Statuses, resp.StatusCode):
+		return 0, false
+	}
+	d := min(p.Base<<(attempt-1), p.Cap)
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			d = min(time.Duration(secs)*time.Second, p.Cap)
+		}
+	}
+	return d, true
+}
+
+// sleepCtx waits for d unless ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// discard drains and closes a response that is being retried, so its
+// connection can be reused.
+func discard(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+}
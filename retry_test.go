@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDoRequestRetries(t *testing.T) {
+	policy := httpRetry
+	policy.Base = time.Millisecond
+	setForTest(t, &httpRetry, policy)
+	o := newOrchard(t, nil)
+
+	for _, tc := range []struct {
+		attempts int
+		fail     int
+		status   int
+		ok       bool
+	}{
+		{attempts: policy.Attempts, fail: 2, status: http.StatusServiceUnavailable, ok: true},
+		// 500 is not among the retried statuses.
+		{attempts: policy.Attempts, fail: 1, status: http.StatusInternalServerError, ok: false},
+		{attempts: 2, fail: 2, status: http.StatusServiceUnavailable, ok: false},
+	} {
+		httpRetry.Attempts = tc.attempts
+		o.srv.Fail(tc.fail, tc.status)
+		page, err := getPageContent(context.Background(), o.bot.Domain, "test", "과수원")
+		if ok := err == nil && page.Text == "[[사과]]"; ok != tc.ok {
+			t.Errorf("getPageContent after %d × %d with %d attempts = %v, %v; want success %v", tc.fail, tc.status, tc.attempts, page, err, tc.ok)
+		}
+	}
+}
+
+func TestDoRequestDoesNotRetryWrites(t *testing.T) {
+	policy := httpRetry
+	policy.Base = time.Millisecond
+	setForTest(t, &httpRetry, policy)
+	o := newOrchard(t, nil)
+
+	page, err := getPageContent(context.Background(), o.bot.Domain, "test", "과수원")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.srv.Fail(1, http.StatusServiceUnavailable)
+	if _, err := updatePageContent(context.Background(), o.bot.Domain, "test", "과수원", "[[사과(과일)]]", page.Token, "사과 → 사과(과일)"); err == nil {
+		t.Error("a save answered 503 was retried")
+	}
+	o.checkPages(t, map[string]string{"과수원": "[[사과]]"})
+}
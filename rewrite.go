@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/ini.v1"
+)
+
+type Job struct {
+	OldTitle string
+	NewTitle string
+	KeepText bool
+	LogEntry string
+	// Via is set on jobs added automatically rather than asked for: it is
+	// the title OldTitle redirects to, or the parent page of a subpage.
+	Via string
+	// Sections, when set, limits the job to links under headings matching
+	// one of these names; "*" matches any text.
+	Sections []string
+	// When holds further conditions a link must meet to be rewritten.
+	When      Conditions
+	matcher   LinkMatcher
+	sectionRe *regexp.Regexp
+}
+
+// Conditions narrow a job to the links whose surroundings show they mean
+// the renamed page, for old titles that are ambiguous.
+type Conditions struct {
+	// Near lists phrases of which one must appear within Within
+	// characters (50 when unset) before or after the link.
+	Near   []string `json:"near,omitempty"`
+	Within int      `json:"within,omitempty"`
+	// NoQuotes skips links in quote blocks (lines starting with ">").
+	NoQuotes bool `json:"no_quotes,omitempty"`
+}
+
+// match reports whether the link at text[start:end] meets c.
+func (c Conditions) match(text string, start, end int) bool {
+	if c.NoQuotes {
+		lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+		if strings.HasPrefix(strings.TrimLeft(text[lineStart:start], " \t"), ">") {
+			return false
+		}
+	}
+	if len(c.Near) == 0 {
+		return true
+	}
+	within := c.Within
+	if within <= 0 {
+		within = 50
+	}
+	// A rune is at most utf8.UTFMax bytes, so only that much of the page
+	// around the link needs decoding.
+	before := []rune(text[max(0, start-within*utf8.UTFMax):start])
+	before = before[max(0, len(before)-within):]
+	after := []rune(text[end:min(len(text), end+within*utf8.UTFMax)])
+	after = after[:min(len(after), within)]
+	window := string(before) + text[start:end] + string(after)
+	for _, phrase := range c.Near {
+		if strings.Contains(window, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+type RewriteResult struct {
+	Text     string
+	Changes  int
+	Sections []string
+}
+
+var headingRe = regexp.MustCompile(`(?m)^(=+)#?[\t\f ]*(.+?)[\t\f ]*#?(=+)[\t\f ]*$`)
+
+// newJob builds a rename job. When oldTitle has a section anchor
+// ("Page#Old section") the job retargets only links to that section, and a
+// newTitle of just "#New section" keeps the page. Links may start with ":"
+// ("[[:파일:A.png]]"), and a template's job also retargets
+// [include(틀:Old ...)] macros. XWiki includes any page, so there every
+// job retargets {{include reference="Old"}} macros.
+func newJob(oldTitle, newTitle string, keepText bool, logTemplate string) *Job {
+	page, _, anchored := strings.Cut(oldTitle, "#")
+	_, xwiki := linkSyntax.(xwikiSyntax)
+	if anchored && strings.HasPrefix(newTitle, "#") {
+		newTitle = page + newTitle
+	}
+	logEntry := strings.ReplaceAll(logTemplate, "{old}", oldTitle)
+	logEntry = strings.ReplaceAll(logEntry, "{new}", newTitle)
+	return &Job{
+		OldTitle: oldTitle,
+		NewTitle: newTitle,
+		KeepText: keepText,
+		LogEntry: logEntry,
+		matcher:  newLinkMatcher(oldTitle, !anchored && (namespaceOf(page) == "틀" || xwiki)),
+	}
+}
+
+// Page returns the document the job's links point to: OldTitle without
+// its section anchor.
+func (j *Job) Page() string {
+	page, _, _ := strings.Cut(j.OldTitle, "#")
+	return page
+}
+
+// displayCollapse is data.ini's collapseDisplay: how loosely display text
+// must match the new title for [[New|text]] to collapse to [[New]].
+//
+//	exact   only identical text
+//	space   also ignoring surrounding and repeated whitespace (default)
+//	fold    also ignoring letter case
+var displayCollapse = "space"
+
+func normalizeDisplay(s string) string {
+	switch displayCollapse {
+	case "exact":
+		return s
+	case "fold":
+		return strings.ToLower(strings.Join(strings.Fields(s), " "))
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// inherit gives a job derived from parent the same section limits and
+// conditions.
+func (j *Job) inherit(parent *Job) *Job {
+	j.When = parent.When
+	return j.limitSections(parent.Sections)
+}
+
+// limitSections restricts the job to the sections named by patterns.
+func (j *Job) limitSections(patterns []string) *Job {
+	j.Sections = patterns
+	j.sectionRe = nil
+	if len(patterns) > 0 {
+		alts := make([]string, len(patterns))
+		for i, p := range patterns {
+			alts[i] = strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSpace(p)), `\*`, ".*")
+		}
+		j.sectionRe = regexp.MustCompile("^(?:" + strings.Join(alts, "|") + ")$")
+	}
+	return j
+}
+
+// replace builds the new link. In the file and category namespaces the
+// text after "|" holds parameters or a sort key rather than display text,
+// so it is kept as it is and keepText does not apply.
+func (j *Job) replace(m LinkMatch) string {
+	if m.Include {
+		return linkSyntax.Include(j.NewTitle, m.Sep)
+	}
+	display := m.Display
+	switch namespaceOf(j.Page()) {
+	case "파일", "분류":
+		return linkSyntax.Link(m.Colon, j.NewTitle, display, m.Params)
+	}
+	if display != "" && normalizeDisplay(display) == normalizeDisplay(j.NewTitle) {
+		display = ""
+	}
+	if display == "" && j.KeepText {
+		display = j.OldTitle
+	}
+	return linkSyntax.Link(m.Colon, j.NewTitle, display, m.Params)
+}
+
+func (j *Job) Rewrite(text string) RewriteResult {
+	headings := findHeadings(text)
+	var b strings.Builder
+	var res RewriteResult
+	seen := make(map[string]bool)
+	last := 0
+	for _, m := range j.matcher.Match(text) {
+		section := sectionAt(text, headings, m.Start)
+		if j.sectionRe != nil && !j.sectionRe.MatchString(section) || !j.When.match(text, m.Start, m.End) {
+			continue
+		}
+		repl := j.replace(m)
+		b.WriteString(text[last:m.Start])
+		b.WriteString(repl)
+		last = m.End
+		if repl == text[m.Start:m.End] {
+			continue
+		}
+		res.Changes++
+		if !seen[section] {
+			seen[section] = true
+			res.Sections = append(res.Sections, section)
+		}
+	}
+	b.WriteString(text[last:])
+	res.Text = b.String()
+	return res
+}
+
+// findHeadings returns headingRe's submatch indexes for every heading of
+// text. Only lines starting with "=" are handed to the expression, which
+// on huge pages otherwise costs more than the link matching itself.
+func findHeadings(text string) [][]int {
+	var headings [][]int
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		if text[start] == '=' {
+			if m := headingRe.FindStringSubmatchIndex(text[start:end]); m != nil {
+				for i := range m {
+					m[i] += start
+				}
+				headings = append(headings, m)
+			}
+		}
+		start = end + 1
+	}
+	return headings
+}
+
+func sectionAt(text string, headings [][]int, pos int) string {
+	i := sort.Search(len(headings), func(i int) bool { return headings[i][0] > pos })
+	if i == 0 {
+		return ""
+	}
+	h := headings[i-1]
+	return text[h[4]:h[5]]
+}
+
+// Summary expands the per-document placeholders of the log template:
+// {doc}, {links} (number of rewritten links) and {sections} (headings of
+// the sections that were touched, comma-separated).
+func (j *Job) Summary(doc string, res RewriteResult) string {
+	var sections []string
+	for _, s := range res.Sections {
+		if s != "" {
+			sections = append(sections, s)
+		}
+	}
+	r := strings.NewReplacer(
+		"{doc}", doc,
+		"{links}", strconv.Itoa(res.Changes),
+		"{sections}", strings.Join(sections, ", "),
+	)
+	return r.Replace(j.LogEntry)
+}
+
+// batchLogTemplate is data.ini's batchLogTemplate, the edit summary used
+// when several jobs change one document. Besides {run}, {doc} and {links}
+// it expands {renames} to the "old → new" pairs applied. Without it the
+// jobs' own summaries are joined with " / ", followed by the link count
+// when summaryLinkCount is on.
+var batchLogTemplate string
+
+// summaryLinkCount is data.ini's summaryLinkCount: append the number of
+// links changed to every edit summary and progress line, so patrollers can
+// size up an edit without opening the diff.
+var summaryLinkCount bool
+
+// rewriteAll applies every job to text in turn and combines the summaries
+// of the jobs that changed something into one edit summary. It also
+// returns the number of links changed and the jobs that changed them.
+func rewriteAll(jobs []*Job, doc, text string) (string, string, int, []*Job) {
+	var summaries, renames []string
+	var changed []*Job
+	links := 0
+	for _, job := range jobs {
+		res := job.Rewrite(text)
+		if res.Changes == 0 {
+			continue
+		}
+		text = res.Text
+		links += res.Changes
+		changed = append(changed, job)
+		summaries = append(summaries, job.Summary(doc, res))
+		renames = append(renames, job.OldTitle+" → "+job.NewTitle)
+	}
+	if len(summaries) > 1 && batchLogTemplate != "" {
+		return text, strings.NewReplacer(
+			"{doc}", doc,
+			"{links}", strconv.Itoa(links),
+			"{renames}", strings.Join(renames, ", "),
+		).Replace(batchLogTemplate), links, changed
+	}
+	summary := strings.Join(summaries, " / ")
+	if summaryLinkCount && links > 0 {
+		summary += msg("summary_links", links)
+	}
+	return text, summary, links, changed
+}
+
+// loadBatch reads rename jobs from an ini file with one [job.NAME] section
+// per rename, each holding old, new and the optional keepText, sections
+// (comma-separated heading names), near (comma-separated phrases), within
+// and noQuotes keys (see Conditions).
+func loadBatch(path, logTemplate string) ([]*Job, error) {
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	for _, sec := range cfg.Sections() {
+		if !strings.HasPrefix(sec.Name(), "job.") {
+			continue
+		}
+		oldTitle := sec.Key("old").String()
+		newTitle := sec.Key("new").String()
+		if oldTitle == "" || newTitle == "" {
+			return nil, fmt.Errorf("section [%s] needs both old and new", sec.Name())
+		}
+		jobs = append(jobs, jobFromSection(sec, oldTitle, newTitle, logTemplate))
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no [job.*] sections in %s", path)
+	}
+	return jobs, nil
+}
+
+// jobFromSection builds the job renaming oldTitle to newTitle with the
+// keepText, sections, near, within and noQuotes keys of sec.
+func jobFromSection(sec *ini.Section, oldTitle, newTitle, logTemplate string) *Job {
+	job := newJob(oldTitle, newTitle, sec.Key("keepText").MustBool(false), logTemplate)
+	job.When = Conditions{
+		Near:     parseList(sec.Key("near").String()),
+		Within:   sec.Key("within").MustInt(0),
+		NoQuotes: sec.Key("noQuotes").MustBool(false),
+	}
+	return job.limitSections(parseList(sec.Key("sections").String()))
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"micro-rearalice/internal/fakeseed"
+)
+
+func TestRewriteFixtures(t *testing.T) {
+	defer func(kind string) { linkMatcherKind = kind }(linkMatcherKind)
+	for _, kind := range linkMatcherKinds {
+		linkMatcherKind = kind
+		for _, f := range fakeseed.Fixtures {
+			t.Run(kind+"/"+f.Name, func(t *testing.T) {
+				job := newJob(f.Old, f.New, f.KeepText, "").limitSections(f.Sections)
+				job.When = Conditions{Near: f.Near, NoQuotes: f.NoQuotes}
+				if got := job.Rewrite(f.Input).Text; got != f.Want {
+					t.Errorf("Rewrite(%q)\n got: %q\nwant: %q", f.Input, got, f.Want)
+				}
+			})
+		}
+	}
+}
+
+func TestASTMatcherSkipsLiteralText(t *testing.T) {
+	defer func(kind string) { linkMatcherKind = kind }(linkMatcherKind)
+	linkMatcherKind = "ast"
+	job := newJob("사과", "사과(과일)", false, "")
+	in := "{{{[[사과]]}}} \\[[사과]] {{{#!wiki [[사과]]}}}\n## [[사과]]\n[[사과]]"
+	want := "{{{[[사과]]}}} \\[[사과]] {{{#!wiki [[사과(과일)]]}}}\n## [[사과]]\n[[사과(과일)]]"
+	if got := job.Rewrite(in).Text; got != want {
+		t.Errorf("Rewrite(%q)\n got: %q\nwant: %q", in, got, want)
+	}
+}
+
+func TestXWikiLinks(t *testing.T) {
+	setForTest[Syntax](t, &linkSyntax, xwikiSyntax{})
+	job := newJob("Main.Apple", "Main.Fruit", false, "")
+	in := "[[Main.Apple]] [[사과>>doc:Main.Apple||target=\"_blank\"]] [[Main.Fruit>>Main.Apple]] [[Main.Apples]]\n" +
+		"{{include reference=\"Main.Apple\" context=\"new\"/}} {{include reference=\"Main.Apples\"/}}"
+	want := "[[Main.Fruit]] [[사과>>doc:Main.Fruit||target=\"_blank\"]] [[Main.Fruit]] [[Main.Apples]]\n" +
+		"{{include reference=\"Main.Fruit\" context=\"new\"/}} {{include reference=\"Main.Apples\"/}}"
+	if got := job.Rewrite(in).Text; got != want {
+		t.Errorf("Rewrite(%q)\n got: %q\nwant: %q", in, got, want)
+	}
+}
+
+// hugeList builds a list page of about size bytes, in the shape of the
+// multi-megabyte index pages: headings, plain items and links to title.
+func hugeList(title string, size int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		if i%200 == 0 {
+			fmt.Fprintf(&b, "== 목록 %d ==\n", i/200)
+		}
+		switch i % 7 {
+		case 0:
+			fmt.Fprintf(&b, " * [[%s]] 항목 %d\n", title, i)
+		case 3:
+			fmt.Fprintf(&b, " * [[ : %s |보기 %d]]\n", title, i)
+		case 5:
+			fmt.Fprintf(&b, " * [[%s 외전]] [include(%s, 번호=%d)]\n", title, title, i)
+		default:
+			fmt.Fprintf(&b, " * [[문서 %d]] 설명 %d\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func TestScanMatcherMatchesRegex(t *testing.T) {
+	text := hugeList("사과", 256<<10) + "[[사과|[x]]] [[사과|]] [[사과]"
+	for _, include := range []bool{false, true} {
+		want := newRegexMatcher(regexp.QuoteMeta("사과"), include).Match(text)
+		got := (&scanMatcher{title: "사과", include: include}).Match(text)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("include=%v: scanner found %d matches, regex %d", include, len(got), len(want))
+		}
+	}
+}
+
+func TestFindHeadings(t *testing.T) {
+	text := hugeList("사과", 64<<10) + "\n=== 끝 ===\r\n== 마지막 ==\n"
+	if got, want := findHeadings(text), headingRe.FindAllStringSubmatchIndex(text, -1); !reflect.DeepEqual(got, want) {
+		t.Errorf("findHeadings found %d headings, headingRe %d", len(got), len(want))
+	}
+}
+
+func BenchmarkRewrite(b *testing.B) {
+	defer func(kind string) { linkMatcherKind = kind }(linkMatcherKind)
+	text := hugeList("사과", 4<<20)
+	for _, kind := range linkMatcherKinds {
+		linkMatcherKind = kind
+		job := newJob("사과", "사과(과일)", false, "")
+		b.Run(kind, func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			for i := 0; i < b.N; i++ {
+				job.Rewrite(text)
+			}
+		})
+	}
+}
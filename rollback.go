@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+func runRollback(fs *flag.FlagSet) func() {
+	user := fs.String("user", "", "account whose edits to revert (defaults to the configured user)")
+	yes := fs.Bool("yes", false, "revert without asking for confirmation")
+	return func() {
+		if fs.NArg() != 1 {
+			warn("rollback_usage")
+			os.Exit(2)
+		}
+		runID := fs.Arg(0)
+
+		bot := loadBot()
+		bot.requireToken("rollback")
+		if *user == "" {
+			*user = bot.Accounts.Current().User
+		}
+		if *user == "" {
+			warn("no_user")
+			os.Exit(2)
+		}
+
+		// Contributions are listed newest first, so the first edit seen for a
+		// document is the run's last one and the last seen is its first.
+		var docs []string
+		first := make(map[string]Contribution)
+		last := make(map[string]Contribution)
+		err := listContributions(bot.Domain, bot.Accounts.Current().Token, *user, contribFilter{Summary: runID}, func(c Contribution) bool {
+			if _, ok := last[c.Document]; !ok {
+				docs = append(docs, c.Document)
+				last[c.Document] = c
+			}
+			first[c.Document] = c
+			return true
+		})
+		if err != nil {
+			warn("contribs_failed", err)
+			os.Exit(1)
+		}
+		say("rollback_found", len(docs), *user, runID)
+		if len(docs) == 0 {
+			return
+		}
+		if !*yes && strings.ToLower(prompt(msg("prompt_revert"))) != "y" {
+			say("aborted")
+			return
+		}
+
+		bot.watchDiscuss()
+		summary := msg("rollback_summary", runID)
+		for idx, doc := range docs {
+			account, err := bot.withAccount(context.Background(), func(account Account) error {
+				return revertDocument(bot.Domain, account.Token, doc, first[doc].Rev-1, last[doc].Rev, summary)
+			})
+			if err != nil {
+				say("revert_failed", doc, idx+1, len(docs), err)
+				continue
+			}
+			say("reverted", doc, first[doc].Rev-1, idx+1, len(docs), account.Name)
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// revertDocument restores doc to revision rev, but only while the run's
+// last revision botRev is still the latest one.
+func revertDocument(domain, token, doc string, rev, botRev int, summary string) error {
+	page, err := getPageContent(context.Background(), domain, token, doc)
+	if err != nil {
+		return err
+	}
+	botText, err := getRawRevision(context.Background(), domain, token, doc, botRev)
+	if err != nil {
+		return err
+	}
+	if hashText(botText) != page.Hash {
+		return fmt.Errorf("%w after r%d", ErrPageChanged, botRev)
+	}
+	text, err := getRawRevision(context.Background(), domain, token, doc, rev)
+	if err != nil {
+		return err
+	}
+	_, err = updatePageContent(context.Background(), domain, token, doc, text, page.Token, summary)
+	return err
+}
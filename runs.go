@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Every edit run keeps its artifacts — manifest, report, event log and
+// checkpoint — in a directory of its own named by the run ID, under
+// data.ini's runsDir, rather than next to the binary. The runs command
+// lists and removes them.
+
+// runsRoot returns data.ini's runsDir.
+func runsRoot(data *ini.File) string {
+	return data.Section("").Key("runsDir").MustString("runs")
+}
+
+// runPath returns the path of the run artifact name, creating the run's
+// directory on first use.
+func (b *Bot) runPath(name string) string {
+	dir := filepath.Join(runsRoot(b.data), b.RunID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		warn("run_dir_failed", err)
+		os.Exit(1)
+	}
+	return filepath.Join(dir, name)
+}
+
+// resumePath resolves -resume: a checkpoint file, or the ID of a run whose
+// directory holds one.
+func resumePath(data *ini.File, arg string) string {
+	if _, err := os.Stat(arg); err == nil && !isDir(arg) {
+		return arg
+	}
+	return filepath.Join(runsRoot(data), arg, "checkpoint.json")
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// runInfo summarises a run directory for runs list.
+type runInfo struct {
+	ID       string
+	Dir      string
+	Started  time.Time
+	Finished time.Time
+	Jobs     []string
+	Counts   map[string]int
+	Size     int64
+}
+
+func readRuns(root string) ([]runInfo, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []runInfo
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		r := runInfo{ID: e.Name(), Dir: filepath.Join(root, e.Name())}
+		if info, err := e.Info(); err == nil {
+			r.Started = info.ModTime()
+		}
+		var m Manifest
+		if readJSON(filepath.Join(r.Dir, "manifest.json"), &m) == nil {
+			r.Started = m.Created
+			for _, j := range m.Jobs {
+				r.Jobs = append(r.Jobs, j.Old+" → "+j.New)
+			}
+		}
+		var rep Report
+		if readJSON(filepath.Join(r.Dir, "report.json"), &rep) == nil {
+			r.Finished = rep.Finished
+			r.Counts = make(map[string]int)
+			for _, res := range rep.Results {
+				r.Counts[res.Status]++
+			}
+		}
+		filepath.WalkDir(r.Dir, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					r.Size += info.Size()
+				}
+			}
+			return nil
+		})
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	return runs, nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := readArtifact(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// runRuns lists the run directories, newest first, or removes old ones.
+func runRuns(fs *flag.FlagSet) func() {
+	olderThan := fs.Duration("older-than", 30*24*time.Hour, "with clean, remove runs started longer ago than this")
+	keep := fs.Int("keep", 10, "with clean, always keep this many of the newest runs")
+	yes := fs.Bool("yes", false, "with clean, remove without asking")
+	return func() {
+		action := fs.Arg(0)
+		if fs.NArg() > 0 {
+			fs.Parse(fs.Args()[1:])
+		}
+		data, err := ini.Load("data.ini")
+		if err != nil {
+			data = ini.Empty()
+		}
+		if cfg, err := ini.Load("config.ini"); err == nil {
+			loadArtifactKey(cfg)
+		}
+		root := runsRoot(data)
+		runs, err := readRuns(root)
+		if err != nil {
+			warn("run_dir_failed", err)
+			os.Exit(1)
+		}
+
+		switch action {
+		case "list", "":
+			if len(runs) == 0 {
+				say("runs_none", root)
+				return
+			}
+			for _, r := range runs {
+				state := msg("runs_unfinished")
+				if !r.Finished.IsZero() {
+					state = msg("runs_finished", r.Counts[statusEdited], r.Counts[statusFailed]+r.Counts[statusDead])
+				}
+				fmt.Printf("%s  %s  %-24s %6.1f MB  %s\n", r.ID, r.Started.Format(time.DateTime), state, float64(r.Size)/(1<<20), strings.Join(r.Jobs, ", "))
+			}
+		case "clean":
+			var old []runInfo
+			for i, r := range runs {
+				if i >= *keep && time.Since(r.Started) > *olderThan {
+					old = append(old, r)
+				}
+			}
+			if len(old) == 0 {
+				say("runs_nothing_to_clean")
+				return
+			}
+			for _, r := range old {
+				say("runs_clean_item", r.ID, r.Started.Format(time.DateTime))
+			}
+			if !*yes && strings.ToLower(prompt(msg("prompt_runs_clean", len(old)))) != "y" {
+				say("aborted")
+				return
+			}
+			for _, r := range old {
+				if err := os.RemoveAll(r.Dir); err != nil {
+					warn("run_dir_failed", err)
+				}
+			}
+			say("runs_cleaned", len(old))
+		default:
+			warn("runs_usage")
+			os.Exit(2)
+		}
+	}
+}
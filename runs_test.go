@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestRunDirectories(t *testing.T) {
+	o := newOrchard(t, nil)
+	root := t.TempDir()
+	o.bot.data.Section("").Key("runsDir").SetValue(root)
+
+	checkpoint := o.bot.runPath("checkpoint.json")
+	o.bot.checkpoint = newCheckpoint(checkpoint, o.bot.RunID, o.bot.Namespaces, nil)
+	o.run()
+	o.bot.report.finish(o.bot.runPath("report.json"), "")
+	if got := resumePath(o.bot.data, o.bot.RunID); got != checkpoint {
+		t.Errorf("resumePath(%s) = %s, want %s", o.bot.RunID, got, checkpoint)
+	}
+
+	runs, err := readRuns(root)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("readRuns = %v, %v; want the one run", runs, err)
+	}
+	if r := runs[0]; r.ID != o.bot.RunID || r.Finished.IsZero() || r.Counts[statusEdited] != 1 {
+		t.Errorf("run = %+v, want %s finished with 1 edit", r, o.bot.RunID)
+	}
+}
+
+func TestResumeKeepsRunID(t *testing.T) {
+	o := newOrchard(t, nil)
+	o.bot.data.Section("").Key("runsDir").SetValue(t.TempDir())
+	o.bot.logTemplate = "{old} → {new} ({run})"
+	o.bot.useRunID("new-run")
+
+	path := o.bot.runPath("checkpoint.json")
+	newCheckpoint(path, "first-run", o.bot.Namespaces, []*Job{o.job}).save(nil, true)
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := o.bot.resume(cp)
+	o.bot.editQueue(jobs, docSelection{}, "", true, "")
+
+	edits := o.srv.Edits()
+	if len(edits) == 0 {
+		t.Fatal("resumed run saved nothing")
+	}
+	for _, e := range edits {
+		if e.Log != "사과 → 사과(과일) (first-run)" {
+			t.Errorf("summary of %s = %q, want the checkpoint's run ID", e.Title, e.Log)
+		}
+	}
+	if o.bot.report.RunID != "first-run" {
+		t.Errorf("report run ID = %q, want first-run", o.bot.report.RunID)
+	}
+}
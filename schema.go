@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// apiSchema describes where a wiki's API endpoints live and what its JSON
+// fields are called. The seed engine's forks agree on the shape of the API
+// but not on every name, so requests are built and responses read through
+// the schema rather than fixed strings.
+type apiSchema struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Endpoints maps an endpoint (edit, backlink, titles, history, raw,
+	// discuss, thread, contribution, moves) to its path; the document title
+	// or other argument is appended to it.
+	Endpoints map[string]string `json:"endpoints"`
+	// Fields maps the field names the bot uses to the wiki's names for
+	// them, in a request or at the top level of a response.
+	Fields map[string]string `json:"fields"`
+	// Features lists the optional endpoints the wiki serves. An empty list
+	// means all of them.
+	Features []string `json:"features"`
+}
+
+// seedSchema is the API of the seed engine itself, assumed when the wiki
+// does not describe its own.
+var seedSchema = apiSchema{
+	Name: "seed",
+	Endpoints: map[string]string{
+		"edit":         "/api/edit/",
+		"backlink":     "/api/backlink/",
+		"titles":       "/api/titles",
+		"history":      "/api/history/",
+		"raw":          "/api/raw/",
+		"discuss":      "/api/discuss/",
+		"thread":       "/api/thread/",
+		"contribution": "/api/contribution/author/",
+		"moves":        "/api/moves",
+		"version":      "/api/version",
+	},
+}
+
+// api is the schema of the configured wiki, set by detectSchema.
+var api = &seedSchema
+
+// url returns the URL of endpoint on domain with rest appended.
+func (s *apiSchema) url(domain, endpoint, rest string) string {
+	path, ok := s.Endpoints[endpoint]
+	if !ok {
+		path = seedSchema.Endpoints[endpoint]
+	}
+	return "https://" + domain + path + rest
+}
+
+// has reports whether the wiki serves the optional feature.
+func (s *apiSchema) has(feature string) bool {
+	return len(s.Features) == 0 || slices.Contains(s.Features, feature)
+}
+
+// decode unmarshals a response body into v, first renaming the wiki's
+// field names back to the bot's.
+func (s *apiSchema) decode(body []byte, v any) error {
+	if len(s.Fields) == 0 {
+		return json.Unmarshal(body, v)
+	}
+	back := make(map[string]string, len(s.Fields))
+	for ours, theirs := range s.Fields {
+		back[theirs] = ours
+	}
+	return json.Unmarshal(renameFields(body, back), v)
+}
+
+// encode renames the fields of a request payload to the wiki's names.
+func (s *apiSchema) encode(payload map[string]string) map[string]string {
+	if len(s.Fields) == 0 {
+		return payload
+	}
+	out := make(map[string]string, len(payload))
+	for k, v := range payload {
+		if name, ok := s.Fields[k]; ok {
+			k = name
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// renameFields renames the keys of a response object, or of each object
+// in a response list. Values are left undecoded, so nested keys that
+// happen to share a wiki's field name are untouched and a large page is
+// not parsed twice.
+func renameFields(body []byte, names map[string]string) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) == nil {
+		out := make(map[string]json.RawMessage, len(fields))
+		for k, val := range fields {
+			if name, ok := names[k]; ok {
+				k = name
+			}
+			out[k] = val
+		}
+		data, _ := json.Marshal(out)
+		return data
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(body, &list) == nil {
+		for i := range list {
+			list[i] = renameFields(list[i], names)
+		}
+		data, _ := json.Marshal(list)
+		return data
+	}
+	return body
+}
+
+// engineSchemas are the built-in profiles for engines whose API differs
+// from the seed engine's, chosen with config.ini's engine.
+var engineSchemas = map[string]apiSchema{
+	"seed": seedSchema,
+	"opennamu": {
+		Name: "opennamu",
+		Endpoints: map[string]string{
+			"edit":     "/api/edit/",
+			"backlink": "/api/xref/",
+			"raw":      "/api/raw/",
+			"history":  "/api/history/",
+		},
+		Fields:   map[string]string{"text": "data"},
+		Features: []string{"backlink", "raw", "history"},
+	},
+}
+
+// loadEngine returns the profile named by config.ini's engine: a built-in
+// one, or an [engine.NAME] section describing another fork with
+// endpoint.*, field.* and features keys. Engines with their own backend
+// (see wikiBackends) also set backend and linkSyntax.
+func loadEngine(cfg *ini.File) (*apiSchema, error) {
+	name := strings.ToLower(cfg.Section("").Key("engine").MustString("seed"))
+	backend, linkSyntax = nil, namumark{}
+	if b, ok := wikiBackends[name]; ok {
+		backend, linkSyntax = b.backend, b.syntax
+		if x, ok := backend.(xWiki); ok {
+			x.wiki = cfg.Section("").Key("xwikiWiki").MustString(x.wiki)
+			backend = x
+		}
+		return &apiSchema{Name: name, Features: []string{"backlink"}}, nil
+	}
+	if sec, err := cfg.GetSection("engine." + name); err == nil {
+		schema := apiSchema{Name: name, Endpoints: map[string]string{}, Fields: map[string]string{}}
+		for _, key := range sec.Keys() {
+			switch k := key.Name(); {
+			case strings.HasPrefix(k, "endpoint."):
+				schema.Endpoints[strings.TrimPrefix(k, "endpoint.")] = key.String()
+			case strings.HasPrefix(k, "field."):
+				schema.Fields[strings.TrimPrefix(k, "field.")] = key.String()
+			case k == "features":
+				schema.Features = parseList(key.String())
+			}
+		}
+		return &schema, nil
+	}
+	schema, ok := engineSchemas[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q", name)
+	}
+	return &schema, nil
+}
+
+// detectSchema asks the wiki to describe its API at /api/version, starting
+// from the configured engine's profile. Wikis without that endpoint keep
+// the profile as it is; a reply names the engine's version and overrides
+// endpoints, fields and features where the wiki differs.
+func detectSchema(base *apiSchema, domain, token string) *apiSchema {
+	resp, err := doRequest(context.Background(), "version", "GET", base.url(domain, "version", ""), token, nil)
+	if err != nil {
+		warn("api_probe_failed", err)
+		return base
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return base
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var found apiSchema
+	if err := json.Unmarshal(body, &found); err != nil {
+		warn("api_probe_failed", err)
+		return base
+	}
+	schema := *base
+	schema.Endpoints = maps.Clone(base.Endpoints)
+	maps.Copy(schema.Endpoints, found.Endpoints)
+	if found.Name != "" {
+		schema.Name = found.Name
+	}
+	schema.Version = found.Version
+	if found.Fields != nil {
+		schema.Fields = found.Fields
+	}
+	if found.Features != nil {
+		schema.Features = found.Features
+	}
+	return &schema
+}
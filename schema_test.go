@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestEditQueueAdaptsToFields(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		detect func(t *testing.T, o orchard) *apiSchema
+	}{{
+		name: "detected",
+		detect: func(t *testing.T, o orchard) *apiSchema {
+			schema := detectSchema(&seedSchema, o.srv.Domain(), "test")
+			if schema.Name != "fakeseed" {
+				t.Fatalf("detected %q, want fakeseed", schema.Name)
+			}
+			return schema
+		},
+	}, {
+		name: "engine profile",
+		detect: func(t *testing.T, o orchard) *apiSchema {
+			cfg, _ := ini.Load([]byte("engine = myfork\n[engine.myfork]\nfield.text = content\nfield.token = edit_token\n"))
+			schema, err := loadEngine(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return schema
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newOrchard(t, nil)
+			o.srv.Fields = map[string]string{"text": "content", "token": "edit_token"}
+			setForTest(t, &api, tc.detect(t, o))
+
+			o.run()
+			o.checkPages(t, map[string]string{"과수원": "[[사과(과일)]]"})
+		})
+	}
+}
+
+func TestRenameFields(t *testing.T) {
+	names := map[string]string{"data": "text"}
+	for body, want := range map[string]string{
+		`{"data":"[[사과]]","meta":{"data":1}}`:          `{"meta":{"data":1},"text":"[[사과]]"}`,
+		`[{"data":"a"},{"data":"b","x":[{"data":2}]}]`: `[{"text":"a"},{"text":"b","x":[{"data":2}]}]`,
+		`"data"`: `"data"`,
+	} {
+		if got := string(renameFields([]byte(body), names)); got != want {
+			t.Errorf("renameFields(%s) = %s, want %s", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// loadEventSinks adds the sinks configured as [sink.NAME] sections in
+// config.ini. type selects the sink:
+//
+//	file      append JSON lines to path
+//	webhook   POST each event as JSON to url
+//	database  insert each event with database/sql, using driver and dsn
+//
+// The database sink needs the driver to be linked into the binary; none
+// is included by default.
+func loadEventSinks(cfg *ini.File) {
+	for _, sec := range cfg.Sections() {
+		name, ok := strings.CutPrefix(sec.Name(), "sink.")
+		if !ok {
+			continue
+		}
+		var sink EventSink
+		var err error
+		switch typ := sec.Key("type").String(); typ {
+		case "file":
+			sink, err = newFileSink(sec.Key("path").String())
+		case "webhook":
+			sink = newWebhookSink(sec.Key("url").String())
+		case "database":
+			sink, err = newDBSink(sec.Key("driver").String(), sec.Key("dsn").String(),
+				sec.Key("query").MustString("INSERT INTO rearalice_events (time, run, type, document, account, reason) VALUES (?, ?, ?, ?, ?, ?)"))
+		default:
+			err = fmt.Errorf("unknown type %q", typ)
+		}
+		if err != nil {
+			warn("sink_load_failed", name, err)
+			continue
+		}
+		addEventSink(sink)
+	}
+}
+
+func newFileSink(path string) (EventSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	w := newLineSealer(f)
+	return &jsonSink{enc: json.NewEncoder(w), c: w}, nil
+}
+
+// webhookSink posts events from a background goroutine so a slow receiver
+// does not hold up editing. Events are dropped when the buffer is full.
+type webhookSink struct {
+	url    string
+	events chan Event
+	done   chan struct{}
+}
+
+func newWebhookSink(url string) *webhookSink {
+	s := &webhookSink{url: url, events: make(chan Event, 256), done: make(chan struct{})}
+	go s.run()
+	return s
+}
+
+func (s *webhookSink) Write(ev Event) error {
+	select {
+	case s.events <- ev:
+		return nil
+	default:
+		return fmt.Errorf("webhook %s: buffer full, event dropped", s.url)
+	}
+}
+
+func (s *webhookSink) run() {
+	defer close(s.done)
+	for ev := range s.events {
+		data, _ := json.Marshal(ev)
+		resp, err := httpClient.Post(s.url, "application/json", bytes.NewReader(data))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("status %s", resp.Status)
+			}
+		}
+		if err != nil {
+			warn("sink_failed", fmt.Errorf("webhook %s: %w", s.url, err))
+		}
+	}
+}
+
+func (s *webhookSink) Close() error {
+	close(s.events)
+	<-s.done
+	return nil
+}
+
+type dbSink struct {
+	db    *sql.DB
+	query string
+}
+
+func newDBSink(driver, dsn, query string) (*dbSink, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &dbSink{db: db, query: query}, nil
+}
+
+func (s *dbSink) Write(ev Event) error {
+	_, err := s.db.Exec(s.query, ev.Time, ev.Run, ev.Type, ev.Document, ev.Account, ev.Reason)
+	return err
+}
+
+func (s *dbSink) Close() error { return s.db.Close() }
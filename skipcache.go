@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A SkipCache remembers, across runs, the documents a job set found
+// nothing to change in, with the hash of their text at the time. A rerun
+// still fetches each document, since someone may have linked the old title
+// again, but is spared the rewrite and edit when the text hashes the same.
+// Entries not confirmed within maxAge are dropped.
+type SkipCache struct {
+	Entries map[string]skipEntry `json:"entries"`
+
+	path   string
+	maxAge time.Duration
+	mu     sync.Mutex
+}
+
+type skipEntry struct {
+	Hash    string    `json:"hash"`
+	Checked time.Time `json:"checked"`
+}
+
+// errCached is returned for documents skipped on the cache's word.
+var errCached = fmt.Errorf("%w (cached)", errUnchanged)
+
+func loadSkipCache(path string, maxAge time.Duration) *SkipCache {
+	c := &SkipCache{Entries: make(map[string]skipEntry), path: path, maxAge: maxAge}
+	data, err := readArtifact(path)
+	if err == nil {
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		warn("skip_cache_invalid", path, err)
+		c.Entries = make(map[string]skipEntry)
+	}
+	return c
+}
+
+// key identifies doc under the jobs applied to it, so a cache entry only
+// counts for reruns of the same renames with the same limits.
+func (c *SkipCache) key(jobs []*Job, doc string) string {
+	if c == nil {
+		return ""
+	}
+	sigs := make([]string, len(jobs))
+	for i, job := range jobs {
+		data, _ := json.Marshal(checkpointJobs([]*Job{job}))
+		sigs[i] = string(data)
+	}
+	sort.Strings(sigs)
+	data, _ := json.Marshal(append(sigs, doc))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sameHash reports whether key was found unchanged within maxAge with
+// text hashing to hash, and if so renews the entry.
+func (c *SkipCache) sameHash(key, hash string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.Entries[key]
+	if !ok || e.Hash != hash || time.Since(e.Checked) >= c.maxAge {
+		return false
+	}
+	c.Entries[key] = skipEntry{Hash: hash, Checked: time.Now()}
+	return true
+}
+
+func (c *SkipCache) store(key, hash string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries[key] = skipEntry{Hash: hash, Checked: time.Now()}
+}
+
+// forget drops key, once the document has been edited.
+func (c *SkipCache) forget(key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.Entries, key)
+}
+
+func (c *SkipCache) save() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	for key, e := range c.Entries {
+		if time.Since(e.Checked) >= c.maxAge {
+			delete(c.Entries, key)
+		}
+	}
+	data, _ := json.Marshal(c)
+	c.mu.Unlock()
+	tmp := c.path + ".tmp"
+	err := writeArtifact(tmp, data, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, c.path)
+	}
+	if err != nil {
+		warn("skip_cache_failed", err)
+	}
+}
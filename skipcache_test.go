@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkipCacheRefetches(t *testing.T) {
+	// The ast matcher leaves the link in 요리's literal block alone.
+	setForTest(t, &linkMatcherKind, "ast")
+	o := newOrchard(t, map[string]string{"과수원": "[[사과]]", "요리": "{{{[[사과]]}}}"})
+	path := t.TempDir() + "/cache.json"
+	o.bot.skipCache = loadSkipCache(path, time.Hour)
+	o.run()
+	o.bot.skipCache.save()
+
+	// 요리 was cached as unchanged; someone links the old title there again.
+	o.srv.SetPage("요리", "{{{[[사과]]}}} [[사과]]")
+	bot := newTestBot(t, o.srv)
+	bot.skipCache = loadSkipCache(path, time.Hour)
+	bot.editQueue([]*Job{newJob("사과", "사과(과일)", false, bot.LogTemplate)}, docSelection{}, "", true, "")
+	o.checkPages(t, map[string]string{"요리": "{{{[[사과]]}}} [[사과(과일)]]"})
+}
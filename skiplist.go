@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A SkipList remembers, across runs and regardless of the jobs, documents
+// the bot can never fix: ones it is permanently denied editing and ones
+// carrying data.ini's optOutMarker. Listed documents are skipped without
+// being fetched until their entry is older than maxAge, when they are
+// tried once more.
+type SkipList struct {
+	Entries map[string]skipListEntry `json:"entries"`
+
+	path   string
+	maxAge time.Duration
+	mu     sync.Mutex
+}
+
+type skipListEntry struct {
+	Reason string    `json:"reason"`
+	Added  time.Time `json:"added"`
+}
+
+var (
+	ErrOptedOut   = errors.New("document opted out of bot edits")
+	ErrSkipListed = errors.New("on the skip list")
+)
+
+// optOutMarker is data.ini's optOutMarker: documents containing it are
+// never edited.
+var optOutMarker string
+
+func loadSkipList(path string, maxAge time.Duration) *SkipList {
+	l := &SkipList{Entries: make(map[string]skipListEntry), path: path, maxAge: maxAge}
+	data, err := readArtifact(path)
+	if err == nil {
+		err = json.Unmarshal(data, l)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		warn("skip_list_invalid", path, err)
+		l.Entries = make(map[string]skipListEntry)
+	}
+	return l
+}
+
+// check returns ErrSkipListed, with the recorded reason, for a listed doc.
+func (l *SkipList) check(doc string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.Entries[doc]
+	if !ok || l.maxAge > 0 && time.Since(e.Added) > l.maxAge {
+		return nil
+	}
+	return fmt.Errorf("%w since %s: %s", ErrSkipListed, e.Added.Format(time.DateOnly), e.Reason)
+}
+
+// note lists doc when err means it can never be fixed, and drops it from
+// the list once it was edited.
+func (l *SkipList) note(doc string, err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case err == nil:
+		delete(l.Entries, doc)
+	case errors.Is(err, ErrPermDenied), errors.Is(err, ErrOptedOut):
+		l.Entries[doc] = skipListEntry{Reason: err.Error(), Added: time.Now()}
+	}
+}
+
+func (l *SkipList) save() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	data, _ := json.MarshalIndent(l, "", "  ")
+	l.mu.Unlock()
+	tmp := l.path + ".tmp"
+	err := writeArtifact(tmp, data, 0o644)
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+	if err != nil {
+		warn("skip_list_failed", err)
+	}
+}
+
+// optedOut returns ErrOptedOut when text carries the opt-out marker.
+func optedOut(text string) error {
+	if optOutMarker != "" && strings.Contains(text, optOutMarker) {
+		return fmt.Errorf("%w (%s)", ErrOptedOut, optOutMarker)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEditQueueRemembersSkippedDocuments(t *testing.T) {
+	setForTest(t, &optOutMarker, "{{봇 거부}}")
+	o := newOrchard(t, map[string]string{
+		"과수원": "[[사과]]",
+		"농장":  "[[사과]]",
+		"시장":  "{{봇 거부}} [[사과]]",
+	})
+	o.srv.Protect("농장")
+	path := t.TempDir() + "/skiplist.json"
+
+	o.bot.skipList = loadSkipList(path, time.Hour)
+	o.run()
+	o.bot.skipList.save()
+	o.checkPages(t, map[string]string{"시장": "{{봇 거부}} [[사과]]"})
+
+	bot := newTestBot(t, o.srv)
+	bot.skipList = loadSkipList(path, time.Hour)
+	for _, doc := range []string{"농장", "시장"} {
+		if err := bot.skipList.check(doc); !errors.Is(err, ErrSkipListed) {
+			t.Errorf("check(%s) = %v, want ErrSkipListed", doc, err)
+		}
+	}
+	if err := bot.skipList.check("과수원"); err != nil {
+		t.Errorf("check(과수원) = %v, want nil", err)
+	}
+	bot.editQueue([]*Job{newJob("사과", "사과(열매)", false, bot.LogTemplate)}, docSelection{}, "", true, "")
+	if counts := bot.report.counts(); counts[statusSkipped] != 2 || counts[statusProtected] != 0 {
+		t.Errorf("report counts = %v, want 2 skipped and none protected", counts)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiLatency accumulates the time spent in wiki API calls for the run
+// statistics.
+var apiLatency struct {
+	mu    sync.Mutex
+	calls int
+	total time.Duration
+}
+
+func recordLatency(d time.Duration) {
+	apiLatency.mu.Lock()
+	apiLatency.calls++
+	apiLatency.total += d
+	apiLatency.mu.Unlock()
+}
+
+type NamespaceCount struct {
+	Namespace string `json:"namespace"`
+	Edits     int    `json:"edits"`
+}
+
+// RunStats summarises a finished run.
+type RunStats struct {
+	Links         int              `json:"links"`
+	LinksPerPage  map[string]int   `json:"links_per_page"`
+	TopNamespaces []NamespaceCount `json:"top_namespaces"`
+	APICalls      int              `json:"api_calls"`
+	AvgLatency    time.Duration    `json:"avg_latency_ns"`
+	WallClock     time.Duration    `json:"wall_clock_ns"`
+}
+
+// linkBuckets are the ranges of the links-per-page distribution.
+var linkBuckets = []struct {
+	label string
+	max   int
+}{{"1", 1}, {"2-5", 5}, {"6-10", 10}, {"11+", int(^uint(0) >> 1)}}
+
+// namespaceOf returns the namespace prefix of title, or "문서" for the main
+// namespace.
+func namespaceOf(title string) string {
+	if ns, _, ok := strings.Cut(title, ":"); ok {
+		return ns
+	}
+	return "문서"
+}
+
+func (r *Report) stats() *RunStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &RunStats{LinksPerPage: make(map[string]int), WallClock: r.Finished.Sub(r.Started)}
+	byNamespace := make(map[string]int)
+	for _, res := range r.Results {
+		if res.Status != statusEdited {
+			continue
+		}
+		s.Links += res.Links
+		byNamespace[namespaceOf(res.Document)]++
+		for _, b := range linkBuckets {
+			if res.Links <= b.max {
+				s.LinksPerPage[b.label]++
+				break
+			}
+		}
+	}
+	for ns, n := range byNamespace {
+		s.TopNamespaces = append(s.TopNamespaces, NamespaceCount{ns, n})
+	}
+	sort.Slice(s.TopNamespaces, func(i, j int) bool {
+		a, b := s.TopNamespaces[i], s.TopNamespaces[j]
+		return a.Edits > b.Edits || a.Edits == b.Edits && a.Namespace < b.Namespace
+	})
+	if len(s.TopNamespaces) > 5 {
+		s.TopNamespaces = s.TopNamespaces[:5]
+	}
+	apiLatency.mu.Lock()
+	s.APICalls = apiLatency.calls
+	if apiLatency.calls > 0 {
+		s.AvgLatency = apiLatency.total / time.Duration(apiLatency.calls)
+	}
+	apiLatency.mu.Unlock()
+	return s
+}
+
+func (s *RunStats) print() {
+	say("stats_links", s.Links)
+	for _, b := range linkBuckets {
+		if n := s.LinksPerPage[b.label]; n > 0 {
+			say("stats_bucket", b.label, n)
+		}
+	}
+	for _, ns := range s.TopNamespaces {
+		say("stats_namespace", ns.Namespace, ns.Edits)
+	}
+	say("stats_timing", s.APICalls, s.AvgLatency.Round(time.Millisecond), s.WallClock.Round(time.Second))
+}
@@ -0,0 +1,75 @@
+package main
+
+import "regexp"
+
+// A Syntax reads and writes one engine's link markup. The rewrite logic in
+// Job decides what a new link should say; the syntax decides how it is
+// spelled, so supporting a wiki whose pipe or anchor syntax differs means
+// adding a Syntax rather than touching the rewrite.
+type Syntax interface {
+	// Matcher returns a matcher for the links to title. include also
+	// matches transclusions of it.
+	Matcher(title string, include bool) LinkMatcher
+	// Link writes a link to target. prefix is what preceded the target in
+	// the old link (namumark's ":", XWiki's "doc:"), display the visible
+	// text, "" for none, and params whatever followed the target.
+	Link(prefix, target, display, params string) string
+	// Include writes a transclusion of target, ended by sep.
+	Include(target, sep string) string
+}
+
+// linkSyntax is the markup the wiki's pages are written in. It follows
+// config.ini's engine: DokuWiki's links are close enough to namumark's to
+// share it.
+var linkSyntax Syntax = namumark{}
+
+// namumark is the seed engine's markup: [[target|display]] and
+// [include(target, ...)].
+type namumark struct{}
+
+// Matcher returns the matcher data.ini's matcher chooses (see
+// linkMatcherKind).
+func (namumark) Matcher(title string, include bool) LinkMatcher {
+	switch linkMatcherKind {
+	case "normalized":
+		return newNormalizedMatcher(title, include)
+	case "ast":
+		return &astMatcher{title: title, include: include}
+	case "fast":
+		return &scanMatcher{title: title, include: include}
+	}
+	return &sizedMatcher{
+		small: newRegexMatcher(regexp.QuoteMeta(title), include),
+		huge:  &scanMatcher{title: title, include: include},
+	}
+}
+
+func (namumark) Link(prefix, target, display, params string) string {
+	if display != "" {
+		return "[[" + prefix + target + "|" + display + "]]"
+	}
+	return "[[" + prefix + target + "]]"
+}
+
+func (namumark) Include(target, sep string) string {
+	return "[include(" + target + sep
+}
+
+// xwikiSyntax is XWiki 2.x markup: [[label>>Space.Page||params]] and
+// {{include reference="Space.Page"/}}.
+type xwikiSyntax struct{}
+
+func (xwikiSyntax) Matcher(title string, include bool) LinkMatcher {
+	return newXWikiMatcher(title, include)
+}
+
+func (xwikiSyntax) Link(prefix, target, display, params string) string {
+	if display != "" {
+		return "[[" + display + ">>" + prefix + target + params + "]]"
+	}
+	return "[[" + prefix + target + params + "]]"
+}
+
+func (xwikiSyntax) Include(target, sep string) string {
+	return `{{include reference="` + target + sep
+}
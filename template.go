@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// A jobTemplate is a data.ini [template.NAME] section: the settings of a
+// recurring kind of rename, so only the old and new titles are needed to
+// run another one. Keys named like edit's flags (skip-recent, sections,
+// exclude-docs, ...) set those flags unless they are given on the command
+// line; namespaces, logTemplate, editsPerMinute, editBurst and editJitter
+// replace data.ini's; keepText, near, within and noQuotes are the job's,
+// as in a -batch file.
+type jobTemplate struct {
+	sec      *ini.Section
+	old, new string
+}
+
+// applyTemplate loads the template name and applies its run settings;
+// titles holds the old and new titles when given on the command line.
+func (b *Bot) applyTemplate(name string, fs *flag.FlagSet, titles []string) {
+	sec, err := b.data.GetSection("template." + name)
+	if err != nil {
+		var names []string
+		for _, s := range b.data.Sections() {
+			if n, ok := strings.CutPrefix(s.Name(), "template."); ok {
+				names = append(names, n)
+			}
+		}
+		warn("template_unknown", name, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	if len(titles) != 0 && len(titles) != 2 {
+		warn("template_titles")
+		os.Exit(2)
+	}
+	t := &jobTemplate{sec: sec}
+	if len(titles) == 2 {
+		t.old, t.new = titles[0], titles[1]
+	}
+	b.template = t
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, key := range sec.Keys() {
+		if fs.Lookup(key.Name()) == nil || set[key.Name()] {
+			continue
+		}
+		if err := fs.Set(key.Name(), key.String()); err != nil {
+			warn("template_bad_value", name, key.Name(), err)
+			os.Exit(2)
+		}
+	}
+	if sec.HasKey("namespaces") {
+		b.Namespaces = parseList(sec.Key("namespaces").String())
+	}
+	if sec.HasKey("logTemplate") {
+		b.logTemplate = sec.Key("logTemplate").String()
+		b.LogTemplate = strings.ReplaceAll(b.logTemplate, "{run}", b.RunID)
+	}
+	if slices.ContainsFunc([]string{"editsPerMinute", "editBurst", "editJitter"}, sec.HasKey) {
+		data := b.data.Section("")
+		rate := sec.Key("editsPerMinute").MustFloat64(data.Key("editsPerMinute").MustFloat64(60))
+		b.limiter.configure(
+			rate,
+			sec.Key("editBurst").MustInt(data.Key("editBurst").MustInt(1)),
+			sec.Key("editJitter").MustDuration(data.Key("editJitter").MustDuration(0)),
+		)
+		tuner.setBase(data, rate)
+	}
+	say("template_applied", name)
+}
+
+// job returns the template's job, asking for the titles not given.
+func (t *jobTemplate) job(logTemplate string) *Job {
+	if t.old == "" {
+		t.old = prompt(msg("prompt_old_title"))
+		t.new = prompt(msg("prompt_new_title"))
+	}
+	if t.old == "" || t.new == "" {
+		warn("template_titles")
+		os.Exit(2)
+	}
+	return jobFromSection(t.sec, t.old, t.new, logTemplate)
+}
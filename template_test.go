@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	o := newOrchard(t, nil)
+	sec := o.bot.data.Section("template.정리")
+	sec.Key("namespaces").SetValue("틀, 분류")
+	sec.Key("logTemplate").SetValue("정리: {old} → {new}")
+	sec.Key("skip-recent").SetValue("30m")
+	sec.Key("sample").SetValue("3")
+	sec.Key("keepText").SetValue("true")
+
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	skipRecent := fs.Duration("skip-recent", 0, "")
+	sample := fs.Int("sample", 0, "")
+	fs.Parse([]string{"-sample", "1", "사과", "사과(과일)"})
+	o.bot.applyTemplate("정리", fs, fs.Args())
+
+	if *skipRecent != 30*time.Minute || *sample != 1 {
+		t.Errorf("flags = %v, %d; want the template's skip-recent and the command line's sample", *skipRecent, *sample)
+	}
+	if !slices.Equal(o.bot.Namespaces, []string{"틀", "분류"}) {
+		t.Errorf("namespaces = %v", o.bot.Namespaces)
+	}
+	job := o.bot.template.job(o.bot.LogTemplate)
+	if job.OldTitle != "사과" || job.NewTitle != "사과(과일)" || !job.KeepText || job.LogEntry != "정리: 사과 → 사과(과일)" {
+		t.Errorf("job = %+v", job)
+	}
+}
+
+func TestApplyTemplateRetunes(t *testing.T) {
+	o := newOrchard(t, nil)
+	data := o.bot.data.Section("")
+	data.Key("autoTune").SetValue("true")
+	data.Key("maxEditsPerMinute").SetValue("120")
+	setForTest(t, &tuner, loadAutoTuner(data, o.bot.limiter, o.bot.workers))
+	o.bot.data.Section("template.느리게").Key("editsPerMinute").SetValue("10")
+
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	o.bot.applyTemplate("느리게", fs, nil)
+	if tuner.rate != 10 || tuner.minRate != 2.5 || tuner.maxRate != 120 {
+		t.Errorf("tuner at %.1f/min within %.1f–%.1f, want 10 within 2.5–120", tuner.rate, tuner.minRate, tuner.maxRate)
+	}
+}
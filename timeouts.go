@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// opTimeouts bounds each kind of API request, keyed by the operation
+// names doRequest is given. A discussion poll should fail fast while a
+// multi-megabyte page may take a while, so one global timeout suits
+// neither. config.ini's [timeouts] section overrides them, and its
+// default key covers the operations not listed.
+var opTimeouts = map[string]time.Duration{
+	"backlinks":   30 * time.Second,
+	"get_content": 60 * time.Second,
+	"save":        60 * time.Second,
+	"discuss":     10 * time.Second,
+	"version":     10 * time.Second,
+	"default":     30 * time.Second,
+}
+
+func loadTimeouts(cfg *ini.File) {
+	sec := cfg.Section("timeouts")
+	for _, key := range sec.Keys() {
+		if d, err := time.ParseDuration(key.String()); err == nil {
+			opTimeouts[key.Name()] = d
+		} else {
+			warn("timeout_invalid", key.Name(), key.String())
+		}
+	}
+}
+
+// opTimeout returns the timeout of op; 0 means none.
+func opTimeout(op string) time.Duration {
+	if d, ok := opTimeouts[op]; ok {
+		return d
+	}
+	return opTimeouts["default"]
+}
+
+// withOpTimeout bounds ctx by op's timeout. The returned cancel must run
+// once the response body has been read, not when the request returns.
+func withOpTimeout(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	if d := opTimeout(op); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
+
+// cancelOnClose releases a request's timeout when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
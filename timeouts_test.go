@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEditQueueTimeouts(t *testing.T) {
+	t.Run("content fetch", func(t *testing.T) {
+		old := opTimeouts["get_content"]
+		opTimeouts["get_content"] = time.Nanosecond
+		t.Cleanup(func() { opTimeouts["get_content"] = old })
+		o := newOrchard(t, nil)
+
+		o.run()
+		o.checkCounts(t, map[string]int{statusEdited: 0, statusDead: 1})
+		o.checkPages(t, orchardPages)
+	})
+	t.Run("document", func(t *testing.T) {
+		o := newOrchard(t, nil)
+		o.bot.docTimeout = time.Nanosecond
+
+		o.run()
+		o.checkCounts(t, map[string]int{statusTimedOut: 1})
+		if n := o.bot.report.attempts("과수원"); n != 1 {
+			t.Errorf("%d attempts, want 1 without retries", n)
+		}
+	})
+}
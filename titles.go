@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// maxTitleBytes is the longest title the seed engine accepts, in UTF-8
+// bytes.
+const maxTitleBytes = 255
+
+// titleForbidden are characters the seed engine does not allow in titles;
+// they would be read as link or markup syntax.
+const titleForbidden = "[]|{}<>"
+
+// validateTitle checks title (without any section anchor) against the seed
+// engine's title rules.
+func validateTitle(title string) error {
+	switch {
+	case strings.TrimSpace(title) == "":
+		return fmt.Errorf("title is empty")
+	case strings.TrimSpace(title) != title:
+		return fmt.Errorf("%q starts or ends with whitespace; remove it", title)
+	case len(title) > maxTitleBytes:
+		return fmt.Errorf("%q is %d bytes long; titles are limited to %d", title, len(title), maxTitleBytes)
+	}
+	if i := strings.IndexAny(title, titleForbidden); i >= 0 {
+		return fmt.Errorf("%q contains %q, which titles cannot contain", title, title[i])
+	}
+	if i := strings.IndexFunc(title, unicode.IsControl); i >= 0 {
+		return fmt.Errorf("%q contains a control character at byte %d", title, i)
+	}
+	if ns, rest, ok := strings.Cut(title, ":"); ok && slices.Contains(defaultNamespaces, ns) && strings.TrimSpace(rest) == "" {
+		return fmt.Errorf("%q names only the %s namespace; add the page name after ':'", title, ns)
+	}
+	return nil
+}
+
+// backlinkKinds lists the backlink flags that refer to title in a way the
+// bot rewrites: plain links, plus includes of templates and uses of files.
+func backlinkKinds(title string) []string {
+	page, _, _ := strings.Cut(title, "#")
+	switch namespaceOf(page) {
+	case "틀":
+		return []string{"link", "include"}
+	case "파일":
+		return []string{"link", "file"}
+	}
+	return []string{"link"}
+}
+
+// inheritNamespace puts a new title without a namespace prefix into the
+// old title's namespace, since "틀:Old" → "New" almost always means
+// "틀:New". A leading ":" ("틀:Old" → ":New") keeps the new title in the
+// main namespace.
+func inheritNamespace(oldTitle, newTitle string) string {
+	if title, ok := strings.CutPrefix(newTitle, ":"); ok {
+		return title
+	}
+	oldNS, _, oldOK := strings.Cut(oldTitle, ":")
+	if !oldOK || !slices.Contains(defaultNamespaces, oldNS) || strings.HasPrefix(newTitle, "#") {
+		return newTitle
+	}
+	if newNS, _, ok := strings.Cut(newTitle, ":"); ok && slices.Contains(defaultNamespaces, newNS) {
+		return newTitle
+	}
+	return oldNS + ":" + newTitle
+}
+
+// inheritNamespaces applies inheritNamespace to jobs, saying which new
+// titles it moved into a namespace when announce is set.
+func inheritNamespaces(jobs []*Job, logTemplate string, announce bool) {
+	for i, job := range jobs {
+		title := inheritNamespace(job.OldTitle, job.NewTitle)
+		if title == job.NewTitle {
+			continue
+		}
+		if announce && !strings.HasPrefix(job.NewTitle, ":") {
+			say("namespace_inherited", job.NewTitle, title)
+		}
+		jobs[i] = newJob(job.OldTitle, title, job.KeepText, logTemplate).inherit(job)
+	}
+}
+
+// validateJob checks both titles of job and that the rename is one the
+// engine can hold: pages cannot move into the special namespace, nor into
+// or out of the file namespace.
+func validateJob(job *Job) []error {
+	var errs []error
+	oldPage, _, _ := strings.Cut(job.OldTitle, "#")
+	newPage, _, _ := strings.Cut(job.NewTitle, "#")
+	for _, t := range []struct{ which, title string }{{"old", oldPage}, {"new", newPage}} {
+		if err := validateTitle(t.title); err != nil {
+			errs = append(errs, fmt.Errorf("%s title: %w", t.which, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	oldNS, newNS := namespaceOf(oldPage), namespaceOf(newPage)
+	switch {
+	case job.OldTitle == job.NewTitle:
+		errs = append(errs, fmt.Errorf("%q: old and new titles are the same", job.OldTitle))
+	case newNS == "특수기능":
+		errs = append(errs, fmt.Errorf("%q: special pages (특수기능:) cannot be link targets to rename to", job.NewTitle))
+	case (oldNS == "파일") != (newNS == "파일"):
+		errs = append(errs, fmt.Errorf("%q → %q: file pages (파일:) cannot move to or from another namespace", job.OldTitle, job.NewTitle))
+	}
+	return errs
+}
+
+// validateJobs reports every invalid job and exits when there is any, so a
+// bad title fails the run up front instead of once per page.
+func validateJobs(jobs []*Job) {
+	failed := false
+	for _, job := range jobs {
+		for _, err := range validateJob(job) {
+			warn("invalid_title", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
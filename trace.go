@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Spans are exported in the OpenTelemetry protocol's JSON encoding
+// (OTLP/HTTP), so any OpenTelemetry collector can receive them without
+// the bot depending on the SDK. Tracing is off unless config.ini sets
+// otlpEndpoint, e.g. http://localhost:4318/v1/traces.
+
+type span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	attrs    map[string]string
+}
+
+type spanKey struct{}
+
+type tracer struct {
+	endpoint string
+	service  string
+
+	mu      sync.Mutex
+	pending []map[string]any
+}
+
+var activeTracer *tracer
+
+func initTracing(endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	t := &tracer{endpoint: endpoint, service: "micro-rearalice"}
+	activeTracer = t
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			t.flush()
+		}
+	}()
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// startSpan begins a span named name as a child of the span in ctx, if
+// any. attrs are key/value pairs. It returns a nil span when tracing is
+// off; all span methods accept a nil receiver.
+func startSpan(ctx context.Context, name string, attrs ...string) (context.Context, *span) {
+	if activeTracer == nil {
+		return ctx, nil
+	}
+	s := &span{spanID: randomHex(8), name: name, start: time.Now(), attrs: make(map[string]string)}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.attrs[attrs[i]] = attrs[i+1]
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *span) set(key, value string) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+	var attrs []map[string]any
+	for k, v := range s.attrs {
+		attrs = append(attrs, map[string]any{"key": k, "value": map[string]string{"stringValue": v}})
+	}
+	status := map[string]any{"code": 1}
+	if err != nil {
+		status = map[string]any{"code": 2, "message": err.Error()}
+	}
+	activeTracer.add(map[string]any{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"parentSpanId":      s.parentID,
+		"name":              s.name,
+		"kind":              3,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(time.Now().UnixNano(), 10),
+		"attributes":        attrs,
+		"status":            status,
+	})
+}
+
+func (t *tracer) add(s map[string]any) {
+	t.mu.Lock()
+	t.pending = append(t.pending, s)
+	full := len(t.pending) >= 512
+	t.mu.Unlock()
+	if full {
+		go t.flush()
+	}
+}
+
+// flush sends the finished spans to the collector. Export failures drop
+// the batch rather than slowing the run down.
+func (t *tracer) flush() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	body := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []any{
+				map[string]any{"key": "service.name", "value": map[string]string{"stringValue": t.service}},
+			}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": t.service},
+				"spans": spans,
+			}},
+		}},
+	}
+	data, _ := json.Marshal(body)
+	resp, err := httpClient.Post(t.endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+func shutdownTracing() {
+	if activeTracer != nil {
+		activeTracer.flush()
+	}
+}
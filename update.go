@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// version and updatePublicKey are set at release build time with
+// -ldflags "-X main.version=... -X main.updatePublicKey=...". The key is
+// the base64 ed25519 public key that signs each release's checksums.txt.
+var (
+	version         = "dev"
+	updatePublicKey = ""
+)
+
+const releaseRepo = "Hoto-Cocoa/Micro-RearAlice"
+
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// runUpdate replaces the running binary with the latest release for this
+// platform, after checking it against the release's checksums.txt and,
+// in release builds, that file's signature.
+func runUpdate(fs *flag.FlagSet) func() {
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	return func() {
+
+		rel, err := latestRelease()
+		if err != nil {
+			warn("update_failed", err)
+			os.Exit(1)
+		}
+		if rel.TagName == version {
+			say("update_current", version)
+			return
+		}
+		say("update_available", version, rel.TagName)
+		if *check {
+			return
+		}
+
+		name := fmt.Sprintf("micro-rearalice-%s-%s", runtime.GOOS, runtime.GOARCH)
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+		binURL, sumsURL := rel.asset(name), rel.asset("checksums.txt")
+		if binURL == "" || sumsURL == "" {
+			warn("update_failed", fmt.Errorf("release %s has no %s or checksums.txt", rel.TagName, name))
+			os.Exit(1)
+		}
+		sums, err := download(sumsURL)
+		if err == nil {
+			err = verifySignature(rel, sums)
+		}
+		var bin []byte
+		if err == nil {
+			bin, err = download(binURL)
+		}
+		if err == nil {
+			err = verifyChecksum(sums, name, bin)
+		}
+		if err == nil {
+			err = replaceExecutable(bin)
+		}
+		if err != nil {
+			warn("update_failed", err)
+			os.Exit(1)
+		}
+		say("update_done", rel.TagName)
+	}
+}
+
+func latestRelease() (*release, error) {
+	data, err := download("https://api.github.com/repos/" + releaseRepo + "/releases/latest")
+	if err != nil {
+		return nil, err
+	}
+	var rel release
+	if err := json.Unmarshal(data, &rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: status %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifySignature checks checksums.txt.sig against updatePublicKey. Builds
+// without a key (made from source) can only check the checksum.
+func verifySignature(rel *release, sums []byte) error {
+	if updatePublicKey == "" {
+		warn("update_unsigned")
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("bad built-in update key")
+	}
+	sigURL := rel.asset("checksums.txt.sig")
+	if sigURL == "" {
+		return errors.New("release is not signed")
+	}
+	sig, err := download(sigURL)
+	if err != nil {
+		return err
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = decoded
+	}
+	if !ed25519.Verify(key, sums, sig) {
+		return errors.New("checksums.txt signature does not match")
+	}
+	return nil
+}
+
+func verifyChecksum(sums []byte, name string, bin []byte) error {
+	got := sha256.Sum256(bin)
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			if fields[0] != hex.EncodeToString(got[:]) {
+				return fmt.Errorf("checksum of %s does not match", name)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+// replaceExecutable swaps the running binary for bin. The old binary is
+// moved aside first, which also works on Windows where a running
+// executable cannot be overwritten.
+func replaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, bin, 0o755); err != nil {
+		return err
+	}
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	os.Remove(old)
+	return nil
+}